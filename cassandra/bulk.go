@@ -2,8 +2,10 @@ package cassandra
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -31,17 +33,43 @@ type BatchItem struct {
 }
 
 type Bulk struct {
-	tracer              otelTrace.Tracer
-	session             Session
+	tracer  otelTrace.Tracer
+	session Session
+	// sessionFactory builds a replacement session once the current one has
+	// lost every connection. Nil disables rebuilding.
+	sessionFactory      func() (Session, error)
 	dcpCheckpointCommit func()
-	preparedStmts       map[string]string
-	metric              *Metric
-	shutdownCh          chan struct{}
-	shutdownDoneCh      chan struct{}
-	keyspace            string
-	batchBuffer         []BatchItem
-	batchMutex          sync.Mutex
-	preparedStmtsMutex  sync.RWMutex
+	// secondary receives a copy of every flush when dual-write is enabled.
+	secondary *Bulk
+	// hostObserver collects per-host driver metrics for this cluster.
+	hostObserver  *HostObserver
+	preparedStmts map[string]string
+	// statementGenerations counts, per keyspace.table, how often the
+	// statements of the table were invalidated. It is guarded by
+	// preparedStmtsMutex.
+	statementGenerations map[string]int
+	// operationConsistency holds cassandra.operationConsistency, applied to
+	// statements that do not carry their own consistency.
+	operationConsistency map[OperationType]Consistency
+	// consistency is the session consistency, cassandra.consistency, that
+	// statements without any override run at.
+	consistency Consistency
+	metric      *Metric
+	shutdownCh  chan struct{}
+	// writeCtx is the parent of every write. Close cancels it once the
+	// drain timeout has passed.
+	writeCtx           context.Context
+	cancelWrites       context.CancelFunc
+	shutdownDoneCh     chan struct{}
+	keyspace           string
+	batchBuffer        []BatchItem
+	connectRetry       config.ConnectRetry
+	batchMutex         sync.Mutex
+	preparedStmtsMutex sync.RWMutex
+	sessionMu          sync.RWMutex
+	// rebuildMu serializes session rebuilds. It is held while reconnecting,
+	// which sessionMu never is, so readers of the session do not block.
+	rebuildMu sync.Mutex
 	// flushDone is closed when the current in-flight flush completes.
 	// A new channel is created for each flush. Enforces single flush at a time.
 	flushDone           chan struct{}
@@ -50,13 +78,36 @@ type Bulk struct {
 	batchTickerDuration time.Duration
 	isDcpRebalancing    int32
 	eventCounter        int64
+	writeLatencyMs      int64
+	statementTimeout    time.Duration
+	drainTimeout        time.Duration
+	writeErrors         int64
 	maxInFlightRequests int
 	batchSizeLimit      int
 	batchByteSizeLimit  int
 	currentBatchSize    int
 	currentByteSize     int
 	batchPerEvent       bool
-	writeTimestamp      string
+	// bestEffort makes write failures count towards writeErrors instead of
+	// panicking. Only set on a best-effort dual-write secondary.
+	bestEffort bool
+	// queue holds the flushes a best-effort secondary has yet to write, so
+	// the primary acks without waiting for it. queueDone is closed once the
+	// queue is closed and drained. Both are nil on every other Bulk.
+	queue     chan []BatchItem
+	queueDone chan struct{}
+	// droppedWrites counts the items of flushes dropped because queue was
+	// full.
+	droppedWrites int64
+	// ownOverrides makes statements use this cluster's keyspace and
+	// consistency instead of their own, which are resolved from the
+	// primary's table mappings. Routed keyspaces are still used. Only set on
+	// the dual-write secondary.
+	ownOverrides   bool
+	writeTimestamp string
+	// schemaTypes holds the types ValidateSchema read for each table
+	// mapping, in order.
+	schemaTypes []SchemaTypes
 }
 
 const (
@@ -65,6 +116,13 @@ const (
 	writeTimestampNow       = "now"
 )
 
+const (
+	clusterPrimary   = "primary"
+	clusterSecondary = "secondary"
+)
+
+var errBulkClosed = errors.New("cassandra bulk is closed")
+
 type Mapper func(event interface{}) []Model
 
 type BulkBuilder struct{}
@@ -74,41 +132,235 @@ type Metric struct {
 	BulkRequestProcessLatencyMs int64
 	BulkRequestSize             int64
 	BulkRequestByteSize         int64
+	StatementReprepares         int64
+}
+
+// ClusterMetric holds write metrics for one of the clusters of a dual-write
+// setup.
+type ClusterMetric struct {
+	Cluster        string
+	WriteLatencyMs int64
+	WriteErrors    int64
+	// DroppedWrites counts the items a best-effort secondary dropped
+	// because it fell too far behind the primary.
+	DroppedWrites int64
+}
+
+// SessionFactory builds a session for one cluster. It is called for the
+// primary and, with dual-write, the secondary cluster, and again whenever a
+// lost session has to be rebuilt.
+type SessionFactory func(cfg config.Cassandra) (Session, error)
+
+// BulkOption customizes how NewBulk obtains its sessions.
+type BulkOption func(*bulkOptions)
+
+type bulkOptions struct {
+	session        Session
+	sessionFactory SessionFactory
+}
+
+// WithSession makes the primary cluster use session instead of connecting.
+// Bulk.Close closes it. It is not rebuilt when lost unless a session factory
+// is also set.
+func WithSession(session Session) BulkOption {
+	return func(o *bulkOptions) { o.session = session }
 }
 
-func NewBulk(cfg *config.Connector, dcpCheckpointCommit func()) (*Bulk, error) {
-	realSession, err := NewCassandraSession(cfg.Cassandra)
+// WithSessionFactory replaces NewCassandraSession for every cluster.
+func WithSessionFactory(factory SessionFactory) BulkOption {
+	return func(o *bulkOptions) { o.sessionFactory = factory }
+}
+
+func NewBulk(cfg *config.Connector, dcpCheckpointCommit func(), opts ...BulkOption) (*Bulk, error) {
+	var options bulkOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	operationConsistency, err := parseOperationConsistency(cfg.Cassandra.OperationConsistency)
 	if err != nil {
 		return nil, err
 	}
 
+	hostObserver := NewHostObserver()
+	var (
+		realSession Session
+		factory     func() (Session, error)
+	)
+	if options.session != nil {
+		realSession = options.session
+		if options.sessionFactory != nil {
+			factory = func() (Session, error) { return options.sessionFactory(cfg.Cassandra) }
+		}
+	} else {
+		realSession, factory, err = connect(cfg.Cassandra, hostObserver, options.sessionFactory)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var schemaTypes []SchemaTypes
+	if !cfg.Cassandra.DisableSchemaValidation {
+		schemaTypes, err = ValidateSchema(realSession, cfg.Cassandra.Keyspace, cfg.Cassandra.CollectionTableMapping)
+		if err != nil {
+			realSession.Close()
+			return nil, err
+		}
+	}
+
 	// flushDone starts already closed — no previous flush to wait for.
 	initialDone := make(chan struct{})
 	close(initialDone)
 
+	writeCtx, cancelWrites := context.WithCancel(context.Background())
+
 	b := &Bulk{
-		tracer:              otel.Tracer("github.com/Trendyol/go-dcp-cassandra"),
-		session:             realSession,
-		keyspace:            cfg.Cassandra.Keyspace,
-		dcpCheckpointCommit: dcpCheckpointCommit,
-		shutdownCh:          make(chan struct{}),
-		shutdownDoneCh:      make(chan struct{}),
-		metric:              &Metric{},
-		preparedStmts:       make(map[string]string),
-		batchBuffer:         make([]BatchItem, 0, cfg.Cassandra.BatchSizeLimit),
-		batchSizeLimit:      cfg.Cassandra.BatchSizeLimit,
-		batchByteSizeLimit:  cfg.Cassandra.BatchByteSizeLimit,
-		batchTickerDuration: cfg.Cassandra.BatchTickerDuration,
-		batchTicker:         time.NewTicker(cfg.Cassandra.BatchTickerDuration),
-		maxInFlightRequests: cfg.Cassandra.MaxInFlightRequests,
-		batchPerEvent:       cfg.Cassandra.BatchPerEvent,
-		writeTimestamp:      cfg.Cassandra.WriteTimestamp,
-		flushDone:           initialDone,
+		tracer:               otel.Tracer("github.com/Trendyol/go-dcp-cassandra"),
+		writeCtx:             writeCtx,
+		cancelWrites:         cancelWrites,
+		statementTimeout:     cfg.Cassandra.StatementTimeout,
+		drainTimeout:         cfg.Cassandra.ShutdownDrainTimeout,
+		session:              realSession,
+		sessionFactory:       factory,
+		hostObserver:         hostObserver,
+		connectRetry:         cfg.Cassandra.ConnectRetry,
+		keyspace:             cfg.Cassandra.Keyspace,
+		dcpCheckpointCommit:  dcpCheckpointCommit,
+		shutdownCh:           make(chan struct{}),
+		shutdownDoneCh:       make(chan struct{}),
+		metric:               &Metric{},
+		preparedStmts:        make(map[string]string),
+		operationConsistency: operationConsistency,
+		consistency:          clusterConsistency(cfg.Cassandra.Consistency),
+		batchBuffer:          make([]BatchItem, 0, cfg.Cassandra.BatchSizeLimit),
+		batchSizeLimit:       cfg.Cassandra.BatchSizeLimit,
+		batchByteSizeLimit:   cfg.Cassandra.BatchByteSizeLimit,
+		batchTickerDuration:  cfg.Cassandra.BatchTickerDuration,
+		batchTicker:          time.NewTicker(cfg.Cassandra.BatchTickerDuration),
+		maxInFlightRequests:  cfg.Cassandra.MaxInFlightRequests,
+		batchPerEvent:        cfg.Cassandra.BatchPerEvent,
+		writeTimestamp:       cfg.Cassandra.WriteTimestamp,
+		flushDone:            initialDone,
+		schemaTypes:          schemaTypes,
+	}
+
+	if err := b.warmUpStatements(cfg.Cassandra.CollectionTableMapping); err != nil {
+		cancelWrites()
+		realSession.Close()
+		return nil, err
+	}
+
+	if cfg.DualWrite != nil {
+		secondary, err := newSecondaryBulk(cfg, b.shutdownCh, options.sessionFactory)
+		if err != nil {
+			cancelWrites()
+			realSession.Close()
+			return nil, err
+		}
+		secondary.writeCtx, secondary.cancelWrites = writeCtx, cancelWrites
+		b.secondary = secondary
 	}
 
 	return b, nil
 }
 
+// newSecondaryBulk builds the writer for the dual-write cluster. It has no
+// buffer or ticker of its own; the primary hands it every flushed batch.
+func newSecondaryBulk(cfg *config.Connector, shutdownCh chan struct{}, sessionFactory SessionFactory) (*Bulk, error) {
+	dw := cfg.DualWrite
+	operationConsistency, err := parseOperationConsistency(dw.Cassandra.OperationConsistency)
+	if err != nil {
+		return nil, fmt.Errorf("dual-write secondary: %w", err)
+	}
+
+	hostObserver := NewHostObserver()
+	session, factory, err := connect(dw.Cassandra, hostObserver, sessionFactory)
+	if err != nil {
+		return nil, fmt.Errorf("dual-write secondary: %w", err)
+	}
+
+	if !dw.Cassandra.DisableSchemaValidation {
+		_, err = validateSchema(session, dw.Cassandra.Keyspace, cfg.Cassandra.CollectionTableMapping, true)
+		if err != nil {
+			session.Close()
+			return nil, fmt.Errorf("dual-write secondary: %w", err)
+		}
+	}
+
+	b := &Bulk{
+		tracer:               otel.Tracer("github.com/Trendyol/go-dcp-cassandra"),
+		session:              session,
+		hostObserver:         hostObserver,
+		connectRetry:         dw.Cassandra.ConnectRetry,
+		keyspace:             dw.Cassandra.Keyspace,
+		shutdownCh:           shutdownCh,
+		metric:               &Metric{},
+		preparedStmts:        make(map[string]string),
+		operationConsistency: operationConsistency,
+		consistency:          clusterConsistency(dw.Cassandra.Consistency),
+		maxInFlightRequests:  dw.Cassandra.MaxInFlightRequests,
+		statementTimeout:     dw.Cassandra.StatementTimeout,
+		batchPerEvent:        cfg.Cassandra.BatchPerEvent,
+		bestEffort:           dw.Policy == config.DualWritePolicyBestEffort,
+		ownOverrides:         true,
+		writeTimestamp:       cfg.Cassandra.WriteTimestamp,
+	}
+	if err := b.warmUpStatements(cfg.Cassandra.CollectionTableMapping); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("dual-write secondary: %w", err)
+	}
+	// A best-effort secondary must never stall the primary, so it relies on
+	// the driver's own reconnection instead of blocking on a rebuild, and
+	// writes from its own queue.
+	if b.bestEffort {
+		b.startQueue(dw.QueueSize)
+	} else {
+		b.sessionFactory = factory
+	}
+	return b, nil
+}
+
+func parseOperationConsistency(overrides map[string]string) (map[OperationType]Consistency, error) {
+	parsed := make(map[OperationType]Consistency, len(overrides))
+	for operation, name := range overrides {
+		consistency, err := ParseConsistency(name)
+		if err != nil {
+			return nil, fmt.Errorf("operationConsistency %s: %w", operation, err)
+		}
+		parsed[OperationType(operation)] = consistency
+	}
+	return parsed, nil
+}
+
+// clusterConsistency returns the consistency newCassandraSession configures
+// the session with for name, which defaults to QUORUM.
+func clusterConsistency(name string) Consistency {
+	consistency, err := ParseConsistency(name)
+	if err != nil || consistency == DefaultConsistency {
+		return ConsistencyQuorum
+	}
+	return consistency
+}
+
+// connect opens a session for cfg, retrying for up to connectRetry.maxDuration,
+// and returns the factory used so the session can be rebuilt later. Sessions
+// are built by sessionFactory when set, otherwise by the gocql driver
+// reporting to observer.
+func connect(
+	cfg config.Cassandra, observer *HostObserver, sessionFactory SessionFactory,
+) (Session, func() (Session, error), error) {
+	factory := func() (Session, error) { return newCassandraSession(cfg, observer) }
+	if sessionFactory != nil {
+		factory = func() (Session, error) { return sessionFactory(cfg) }
+	}
+	retry := cfg.ConnectRetry
+	session, err := connectWithRetry(factory, retry, time.Now().Add(retry.MaxDuration), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return session, factory, nil
+}
+
 // StartBulk runs the ticker-driven flush loop. Blocks until Close is called.
 func (b *Bulk) StartBulk() {
 	defer close(b.shutdownDoneCh)
@@ -134,10 +386,98 @@ func (b *Bulk) StartBulk() {
 	}
 }
 
+// Close flushes the buffer and waits for in-flight writes, then for the
+// queue of a best-effort secondary. If either has not drained after
+// shutdownDrainTimeout, writes are cancelled; cancelled writes are neither
+// acked nor committed, so their events are redelivered on restart.
 func (b *Bulk) Close() {
 	close(b.shutdownCh)
-	<-b.shutdownDoneCh
-	b.session.Close()
+	b.waitDrained(b.shutdownDoneCh)
+	if b.secondary != nil && b.secondary.queue != nil {
+		// No flush is in flight any more, so nothing enqueues.
+		close(b.secondary.queue)
+		b.waitDrained(b.secondary.queueDone)
+	}
+	if b.cancelWrites != nil {
+		b.cancelWrites()
+	}
+	b.currentSession().Close()
+	if b.secondary != nil {
+		b.secondary.currentSession().Close()
+	}
+}
+
+// waitDrained waits for done, cancelling writes once shutdownDrainTimeout
+// has passed.
+func (b *Bulk) waitDrained(done <-chan struct{}) {
+	if b.drainTimeout <= 0 {
+		<-done
+		return
+	}
+	select {
+	case <-done:
+	case <-time.After(b.drainTimeout):
+		log.Printf("Cassandra writes did not drain within %s, cancelling in-flight writes", b.drainTimeout)
+		b.cancelWrites()
+		<-done
+	}
+}
+
+func (b *Bulk) currentSession() Session {
+	b.sessionMu.RLock()
+	defer b.sessionMu.RUnlock()
+	return b.session
+}
+
+// withSession runs fn against the current session. If the session has lost
+// every connection it is rebuilt and fn is retried. The rebuild blocks the
+// in-flight flush, which in turn blocks AddActions, so DCP consumption pauses
+// until Cassandra is reachable again instead of failing every write.
+func (b *Bulk) withSession(fn func(Session) error) error {
+	for {
+		session := b.currentSession()
+		if session == nil {
+			return fmt.Errorf("cassandra session is nil")
+		}
+		err := fn(session)
+		if err == nil || b.sessionFactory == nil || !isSessionLost(err) {
+			return err
+		}
+		if err := b.rebuildSession(session); err != nil {
+			return err
+		}
+	}
+}
+
+// rebuildSession replaces lost with a freshly created session, retrying until
+// it succeeds or the bulk is closed. Concurrent callers that observed the same
+// lost session wait on the first rebuild and then reuse its result. The
+// session lock is only taken to swap the session in, so the retry loop never
+// blocks readers of the current session.
+func (b *Bulk) rebuildSession(lost Session) error {
+	b.rebuildMu.Lock()
+	defer b.rebuildMu.Unlock()
+
+	if b.currentSession() != lost {
+		return nil
+	}
+	select {
+	case <-b.shutdownCh:
+		return errBulkClosed
+	default:
+	}
+
+	log.Printf("Cassandra session lost, rebuilding; writes are paused until it is restored")
+	session, err := connectWithRetry(b.sessionFactory, b.connectRetry, time.Time{}, b.shutdownCh)
+	if err != nil {
+		return err
+	}
+	b.sessionMu.Lock()
+	b.session = session
+	b.sessionMu.Unlock()
+	lost.Close()
+	log.Printf("Cassandra session rebuilt, resuming writes")
+	return nil
 }
 
 func (b *Bulk) AddActions(ctx *models.ListenerContext, eventTime time.Time, actions []Model) {
@@ -148,10 +488,6 @@ func (b *Bulk) AddActions(ctx *models.ListenerContext, eventTime time.Time, acti
 
 	atomic.StoreInt64(&b.metric.ProcessLatencyMs, time.Since(eventTime).Milliseconds())
 
-	if len(actions) == 0 {
-		return
-	}
-
 	var once sync.Once
 	ackFn := func() { once.Do(ctx.Ack) }
 
@@ -178,7 +514,10 @@ func (b *Bulk) AddActions(ctx *models.ListenerContext, eventTime time.Time, acti
 	}
 
 	if len(items) == 0 {
-		return
+		// Nothing to write, e.g. the mapper filtered the event out. The
+		// event is still acked, but only by the flush that writes the events
+		// buffered before it, so the checkpoint never passes unwritten ones.
+		items = append(items, BatchItem{Ack: ackFn, EventID: eventID})
 	}
 
 	b.batchMutex.Lock()
@@ -245,7 +584,7 @@ func (b *Bulk) flushLocked() {
 	b.flushDone = thisDone
 	b.flushMu.Unlock()
 
-	go b.runFlush(context.Background(), batch, thisDone)
+	go b.runFlush(b.writeContext(), batch, thisDone)
 }
 
 // runFlush writes all items from batch to Cassandra concurrently
@@ -261,10 +600,22 @@ func (b *Bulk) runFlush(ctx context.Context, batch []BatchItem, thisDone chan st
 
 	startedTime := time.Now()
 
-	if b.batchPerEvent {
-		b.writeByEvent(ctx, batch)
-	} else {
-		b.writeConcurrently(ctx, batch)
+	switch {
+	case b.secondary == nil:
+		b.write(ctx, batch)
+	case b.secondary.queue != nil:
+		b.secondary.enqueue(batch)
+		b.write(ctx, batch)
+	default:
+		var wg sync.WaitGroup
+		wg.Go(func() { b.write(ctx, batch) })
+		wg.Go(func() { b.secondary.write(ctx, batch) })
+		wg.Wait()
+	}
+
+	if b.writesCancelled() {
+		log.Printf("Cassandra flush of %d item(s) cancelled on shutdown; not acking or committing", len(batch))
+		return
 	}
 
 	// Ack all items after all writes complete.
@@ -280,6 +631,86 @@ func (b *Bulk) runFlush(ctx context.Context, batch []BatchItem, thisDone chan st
 	atomic.StoreInt64(&b.metric.BulkRequestProcessLatencyMs, time.Since(startedTime).Milliseconds())
 }
 
+// startQueue makes this Bulk write the flushes handed to enqueue in the
+// background, holding up to size of them.
+func (b *Bulk) startQueue(size int) {
+	b.queue = make(chan []BatchItem, size)
+	b.queueDone = make(chan struct{})
+	go func() {
+		defer close(b.queueDone)
+		for batch := range b.queue {
+			b.write(b.writeContext(), batch)
+		}
+	}()
+}
+
+// enqueue hands batch to the queue of a best-effort secondary without
+// waiting for it to be written. A flush that does not fit is dropped and
+// counted in droppedWrites.
+func (b *Bulk) enqueue(batch []BatchItem) {
+	select {
+	case b.queue <- batch:
+	default:
+		atomic.AddInt64(&b.droppedWrites, int64(len(batch)))
+		log.Printf("dual-write secondary: queue is full, dropping a flush of %d item(s)", len(batch))
+	}
+}
+
+// write sends batch to this Bulk's cluster and records how long it took.
+func (b *Bulk) write(ctx context.Context, batch []BatchItem) {
+	startedTime := time.Now()
+
+	if b.batchPerEvent {
+		b.writeByEvent(ctx, batch)
+	} else {
+		b.writeConcurrently(ctx, batch)
+	}
+
+	atomic.StoreInt64(&b.writeLatencyMs, time.Since(startedTime).Milliseconds())
+}
+
+func (b *Bulk) writeContext() context.Context {
+	if b.writeCtx == nil {
+		return context.Background()
+	}
+	return b.writeCtx
+}
+
+// writesCancelled reports whether Close has cancelled in-flight writes.
+func (b *Bulk) writesCancelled() bool {
+	return b.writeCtx != nil && b.writeCtx.Err() != nil
+}
+
+// statementTimeoutFor returns the statement's own timeout if it has one,
+// otherwise cassandra.statementTimeout.
+func (b *Bulk) statementTimeoutFor(raw *Raw) time.Duration {
+	if raw.Timeout > 0 {
+		return raw.Timeout
+	}
+	return b.statementTimeout
+}
+
+// failWrite handles a failed write. On the primary cluster, or a secondary
+// that must succeed, it panics so the flush is never acked or committed; a
+// best-effort secondary only counts the error. A write that failed because
+// the bulk closed while it waited for a session rebuild cancels the flush
+// instead, so it is redelivered on restart like any other cancelled write.
+func (b *Bulk) failWrite(err error) {
+	if errors.Is(err, errBulkClosed) && b.cancelWrites != nil {
+		b.cancelWrites()
+	}
+	if b.writesCancelled() || errors.Is(err, errBulkClosed) {
+		log.Printf("write cancelled on shutdown: %v", err)
+		return
+	}
+	atomic.AddInt64(&b.writeErrors, 1)
+	if b.bestEffort {
+		log.Printf("dual-write secondary: %v", err)
+		return
+	}
+	panic(err.Error())
+}
+
 // writeConcurrently writes all items independently with a semaphore bounding
 // the number of concurrent Cassandra requests.
 func (b *Bulk) writeConcurrently(ctx context.Context, batch []BatchItem) {
@@ -330,10 +761,21 @@ func (b *Bulk) writeByEvent(ctx context.Context, batch []BatchItem) {
 		semaphore <- struct{}{}
 		wg.Go(func() {
 			defer func() { <-semaphore }()
-			if len(g.items) == 1 {
-				b.requestSync(ctx, g.items[0])
-			} else {
-				b.writeUnloggedBatch(ctx, g.items)
+			// Conditional statements in a batch must all hit the same
+			// partition, so lightweight transactions are written alone.
+			items := make([]BatchItem, 0, len(g.items))
+			for _, item := range g.items {
+				if raw, ok := item.Model.(*Raw); ok && raw.conditional() {
+					b.requestSync(ctx, item)
+					continue
+				}
+				items = append(items, item)
+			}
+			switch {
+			case len(items) == 1:
+				b.requestSync(ctx, items[0])
+			case len(items) > 1:
+				b.writeUnloggedBatch(ctx, items)
 			}
 		})
 	}
@@ -344,37 +786,62 @@ func (b *Bulk) writeByEvent(ctx context.Context, batch []BatchItem) {
 // writeUnloggedBatch writes multiple items from the same DCP event as a
 // single CQL UNLOGGED BATCH.
 func (b *Bulk) writeUnloggedBatch(ctx context.Context, items []BatchItem) {
-	_, span := b.tracer.Start(ctx, "cassandra.batch",
+	ctx, span := b.tracer.Start(ctx, "cassandra.batch",
 		otelTrace.WithAttributes(attribute.Int("batch.items", len(items))),
 	)
 	defer span.End()
 
-	batch := b.session.NewBatch(UnloggedBatch)
+	err := b.withPreparedSession(b.batchTables(items), func(session Session) error {
+		batch := session.NewBatch(UnloggedBatch)
+		// A batch has a single consistency level, so the strongest one
+		// any of its statements runs at wins, counting statements without
+		// an override at the session consistency.
+		consistency := DefaultConsistency
+		var timeout time.Duration
+
+		for _, item := range items {
+			if item.Model == nil {
+				continue
+			}
+			rawModel, ok := item.Model.(*Raw)
+			if !ok {
+				continue
+			}
+			query, values := b.buildQueryAndValues(rawModel)
+			if rawModel.writesTimestamp() {
+				batch.WithTimestamp(rawModel.Timestamp)
+			}
+			batch.Query(query, values...)
+			consistency = max(consistency, b.effectiveConsistency(rawModel))
+			timeout = max(timeout, b.statementTimeoutFor(rawModel))
+		}
 
-	for _, item := range items {
-		if item.Model == nil {
-			continue
+		if batch.Size() == 0 {
+			return nil
 		}
+		batch.WithConsistency(consistency)
+		batch.WithTimeout(timeout)
+		return batch.ExecuteBatchContext(ctx)
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		b.failWrite(fmt.Errorf("cassandra unlogged batch write failed: %w", err))
+	}
+}
+
+func (b *Bulk) batchTables(items []BatchItem) []string {
+	var tables []string
+	for _, item := range items {
 		rawModel, ok := item.Model.(*Raw)
 		if !ok {
 			continue
 		}
-		query, values := b.buildQueryAndValues(rawModel)
-		if rawModel.Timestamp > 0 {
-			batch.WithTimestamp(rawModel.Timestamp)
+		if table := b.qualifiedTable(rawModel); !slices.Contains(tables, table) {
+			tables = append(tables, table)
 		}
-		batch.Query(query, values...)
-	}
-
-	if batch.Size() == 0 {
-		return
-	}
-
-	if err := batch.ExecuteBatch(); err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, err.Error())
-		panic(fmt.Sprintf("Cassandra unlogged batch write failed: %v", err))
 	}
+	return tables
 }
 
 func (b *Bulk) requestSync(ctx context.Context, item BatchItem) {
@@ -387,7 +854,7 @@ func (b *Bulk) requestSync(ctx context.Context, item BatchItem) {
 		return
 	}
 
-	_, span := b.tracer.Start(ctx, "cassandra.write",
+	ctx, span := b.tracer.Start(ctx, "cassandra.write",
 		otelTrace.WithAttributes(
 			attribute.String("db.cassandra.table", rawModel.Table),
 			attribute.String("db.operation", string(rawModel.Operation)),
@@ -398,41 +865,79 @@ func (b *Bulk) requestSync(ctx context.Context, item BatchItem) {
 	var err error
 	switch rawModel.Operation {
 	case Insert, Upsert:
-		err = b.insert(rawModel)
+		err = b.insert(ctx, rawModel)
 	case Update:
-		err = b.update(rawModel)
+		err = b.update(ctx, rawModel)
 	case Delete:
-		err = b.delete(rawModel)
+		err = b.delete(ctx, rawModel)
 	}
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
-		panic(fmt.Sprintf("Cassandra %s failed on table %s: %v", rawModel.Operation, rawModel.Table, err))
+		b.failWrite(fmt.Errorf("cassandra %s failed on table %s: %w", rawModel.Operation, rawModel.Table, err))
 	}
 }
 
-func (b *Bulk) insert(raw *Raw) error {
-	if b.session == nil {
-		return fmt.Errorf("cassandra session is nil")
+func (b *Bulk) insert(ctx context.Context, raw *Raw) error {
+	return b.withPreparedSession([]string{b.qualifiedTable(raw)}, func(session Session) error {
+		query, values := b.buildInsertValues(raw, raw.writesTimestamp())
+		q := session.PreparedQuery(query, values...)
+		q.WithConsistency(b.resolveConsistency(raw))
+		q.WithTimeout(b.statementTimeoutFor(raw))
+		return q.ExecContext(ctx)
+	})
+}
+
+func (b *Bulk) update(ctx context.Context, raw *Raw) error {
+	return b.withPreparedSession([]string{b.qualifiedTable(raw)}, func(session Session) error {
+		query, values := b.buildUpdateValues(raw, raw.writesTimestamp())
+		q := session.PreparedQuery(query, values...)
+		q.WithConsistency(b.resolveConsistency(raw))
+		q.WithTimeout(b.statementTimeoutFor(raw))
+		return q.ExecContext(ctx)
+	})
+}
+
+func (b *Bulk) delete(ctx context.Context, raw *Raw) error {
+	return b.withPreparedSession([]string{b.qualifiedTable(raw)}, func(session Session) error {
+		query, values := b.buildDeleteValues(raw, raw.writesTimestamp())
+		q := session.PreparedQuery(query, values...)
+		q.WithConsistency(b.resolveConsistency(raw))
+		q.WithTimeout(b.statementTimeoutFor(raw))
+		return q.ExecContext(ctx)
+	})
+}
+
+// resolveConsistency returns the statement's own consistency if it has one
+// and this cluster uses it, otherwise the per-operation override configured
+// for this cluster.
+func (b *Bulk) resolveConsistency(raw *Raw) Consistency {
+	if raw.Consistency != DefaultConsistency && !b.ownOverrides {
+		return raw.Consistency
 	}
-	query, values := b.buildInsertValues(raw, raw.Timestamp > 0)
-	return b.session.PreparedQuery(query, values...).Exec()
+	return b.operationConsistency[raw.Operation]
 }
 
-func (b *Bulk) update(raw *Raw) error {
-	if b.session == nil {
-		return fmt.Errorf("cassandra session is nil")
+// effectiveConsistency returns the level raw runs at on this cluster: its
+// resolved override, or the session consistency when it has none.
+func (b *Bulk) effectiveConsistency(raw *Raw) Consistency {
+	if consistency := b.resolveConsistency(raw); consistency != DefaultConsistency {
+		return consistency
 	}
-	query, values := b.buildUpdateValues(raw, raw.Timestamp > 0)
-	return b.session.PreparedQuery(query, values...).Exec()
+	return b.consistency
 }
 
-func (b *Bulk) delete(raw *Raw) error {
-	if b.session == nil {
-		return fmt.Errorf("cassandra session is nil")
+// statementKeyspace returns the statement's own keyspace if it has one and
+// this cluster uses it, otherwise the keyspace of this cluster.
+func (b *Bulk) statementKeyspace(raw *Raw) string {
+	if raw.Keyspace != "" && (raw.RoutedKeyspace || !b.ownOverrides) {
+		return raw.Keyspace
 	}
-	query, values := b.buildDeleteValues(raw, raw.Timestamp > 0)
-	return b.session.PreparedQuery(query, values...).Exec()
+	return b.keyspace
+}
+
+func (b *Bulk) qualifiedTable(raw *Raw) string {
+	return b.statementKeyspace(raw) + "." + raw.Table
 }
 
 func (b *Bulk) resolveTimestamp(eventTime time.Time) int64 {
@@ -460,10 +965,17 @@ func estimateSize(model Model) int {
 		size += len(k)
 		size += estimateValueSize(v)
 	}
+	for k, v := range raw.FilterFrom {
+		size += len(k)
+		size += estimateValueSize(v)
+	}
 	return size
 }
 
 func estimateValueSize(v interface{}) int {
+	if v == Unset {
+		return 0
+	}
 	switch val := v.(type) {
 	case string:
 		return len(val)
@@ -496,6 +1008,13 @@ func (b *Bulk) PrepareEndRebalancing() {
 	atomic.StoreInt32(&b.isDcpRebalancing, 0)
 }
 
+// SchemaTypes returns the types schema validation read from the primary
+// cluster for each collectionTableMapping entry, in order. It is empty when
+// schema validation is disabled.
+func (b *Bulk) SchemaTypes() []SchemaTypes {
+	return b.schemaTypes
+}
+
 func (b *Bulk) GetMetric() *Metric {
 	if b.metric == nil {
 		return &Metric{}
@@ -505,6 +1024,38 @@ func (b *Bulk) GetMetric() *Metric {
 		BulkRequestProcessLatencyMs: atomic.LoadInt64(&b.metric.BulkRequestProcessLatencyMs),
 		BulkRequestSize:             atomic.LoadInt64(&b.metric.BulkRequestSize),
 		BulkRequestByteSize:         atomic.LoadInt64(&b.metric.BulkRequestByteSize),
+		StatementReprepares:         atomic.LoadInt64(&b.metric.StatementReprepares),
+	}
+}
+
+// GetClusterMetrics returns per-cluster write metrics, primary first, when
+// dual-write is enabled. It returns nil for a single-cluster Bulk.
+func (b *Bulk) GetClusterMetrics() []ClusterMetric {
+	if b.secondary == nil {
+		return nil
+	}
+	return []ClusterMetric{
+		b.clusterMetric(clusterPrimary),
+		b.secondary.clusterMetric(clusterSecondary),
+	}
+}
+
+// GetHostMetrics returns per-host driver metrics of the primary cluster and,
+// when dual-write is enabled, of the secondary cluster.
+func (b *Bulk) GetHostMetrics() []HostMetric {
+	metrics := b.hostObserver.Snapshot(clusterPrimary)
+	if b.secondary != nil {
+		metrics = append(metrics, b.secondary.hostObserver.Snapshot(clusterSecondary)...)
+	}
+	return metrics
+}
+
+func (b *Bulk) clusterMetric(cluster string) ClusterMetric {
+	return ClusterMetric{
+		Cluster:        cluster,
+		WriteLatencyMs: atomic.LoadInt64(&b.writeLatencyMs),
+		WriteErrors:    atomic.LoadInt64(&b.writeErrors),
+		DroppedWrites:  atomic.LoadInt64(&b.droppedWrites),
 	}
 }
 
@@ -524,7 +1075,8 @@ func (b *Bulk) getCachedPreparedStatement(cacheKey string, raw *Raw, operation s
 		return query
 	}
 
-	hasTS := raw.Timestamp > 0
+	hasTS := raw.writesTimestamp()
+	keyspace := b.statementKeyspace(raw)
 	var query string
 
 	switch operation {
@@ -535,7 +1087,10 @@ func (b *Bulk) getCachedPreparedStatement(cacheKey string, raw *Raw, operation s
 			placeholders[i] = "?"
 		}
 		query = fmt.Sprintf("INSERT INTO %s.%s (%s) VALUES (%s)",
-			b.keyspace, raw.Table, join(columns, ","), join(placeholders, ","))
+			keyspace, raw.Table, join(columns, ","), join(placeholders, ","))
+		if raw.Operation == Insert {
+			query += " IF NOT EXISTS"
+		}
 		if hasTS {
 			query += " USING TIMESTAMP ?"
 		}
@@ -552,32 +1107,39 @@ func (b *Bulk) getCachedPreparedStatement(cacheKey string, raw *Raw, operation s
 		}
 		if hasTS {
 			query = fmt.Sprintf("UPDATE %s.%s USING TIMESTAMP ? SET %s WHERE %s",
-				b.keyspace, raw.Table, join(setParts, ","), join(whereParts, " AND "))
+				keyspace, raw.Table, join(setParts, ","), join(whereParts, " AND "))
 		} else {
 			query = fmt.Sprintf("UPDATE %s.%s SET %s WHERE %s",
-				b.keyspace, raw.Table, join(setParts, ","), join(whereParts, " AND "))
+				keyspace, raw.Table, join(setParts, ","), join(whereParts, " AND "))
+		}
+		if raw.IfExists {
+			query += " IF EXISTS"
 		}
 	case "DELETE":
 		filterColumns := sortedKeys(raw.Filter)
-		whereParts := make([]string, len(filterColumns))
-		for i, k := range filterColumns {
-			whereParts[i] = fmt.Sprintf("%s = ?", k)
+		whereParts := make([]string, 0, len(filterColumns)+len(raw.FilterFrom))
+		for _, k := range filterColumns {
+			whereParts = append(whereParts, fmt.Sprintf("%s = ?", k))
+		}
+		for _, k := range sortedKeys(raw.FilterFrom) {
+			whereParts = append(whereParts, fmt.Sprintf("%s >= ?", k))
 		}
 		if hasTS {
 			query = fmt.Sprintf("DELETE FROM %s.%s USING TIMESTAMP ? WHERE %s",
-				b.keyspace, raw.Table, join(whereParts, " AND "))
+				keyspace, raw.Table, join(whereParts, " AND "))
 		} else {
 			query = fmt.Sprintf("DELETE FROM %s.%s WHERE %s",
-				b.keyspace, raw.Table, join(whereParts, " AND "))
+				keyspace, raw.Table, join(whereParts, " AND "))
 		}
 	}
 
+	query = withGeneration(query, b.statementGenerations[keyspace+"."+raw.Table])
 	b.preparedStmts[cacheKey] = query
 	return query
 }
 
 func (b *Bulk) buildQueryAndValues(raw *Raw) (string, []interface{}) {
-	hasTS := raw.Timestamp > 0
+	hasTS := raw.writesTimestamp()
 	switch raw.Operation {
 	case Insert, Upsert:
 		return b.buildInsertValues(raw, hasTS)
@@ -592,7 +1154,10 @@ func (b *Bulk) buildQueryAndValues(raw *Raw) (string, []interface{}) {
 
 func (b *Bulk) buildInsertValues(raw *Raw, hasTS bool) (string, []interface{}) {
 	columns := sortedKeys(raw.Document)
-	cacheKey := fmt.Sprintf("INSERT:%s:%s:%v", raw.Table, strings.Join(columns, ","), hasTS)
+	cacheKey := fmt.Sprintf("INSERT:%s:%s:%v", b.qualifiedTable(raw), strings.Join(columns, ","), hasTS)
+	if raw.conditional() {
+		cacheKey += ":ifNotExists"
+	}
 	query := b.getCachedPreparedStatement(cacheKey, raw, "INSERT")
 	values := make([]interface{}, 0, len(columns)+1)
 	for _, col := range columns {
@@ -608,7 +1173,10 @@ func (b *Bulk) buildUpdateValues(raw *Raw, hasTS bool) (string, []interface{}) {
 	docColumns := sortedKeys(raw.Document)
 	filterColumns := sortedKeys(raw.Filter)
 	cacheKey := fmt.Sprintf("UPDATE:%s:%s:%s:%v",
-		raw.Table, strings.Join(docColumns, ","), strings.Join(filterColumns, ","), hasTS)
+		b.qualifiedTable(raw), strings.Join(docColumns, ","), strings.Join(filterColumns, ","), hasTS)
+	if raw.conditional() {
+		cacheKey += ":ifExists"
+	}
 	query := b.getCachedPreparedStatement(cacheKey, raw, "UPDATE")
 	values := make([]interface{}, 0, len(docColumns)+len(filterColumns)+1)
 
@@ -626,9 +1194,11 @@ func (b *Bulk) buildUpdateValues(raw *Raw, hasTS bool) (string, []interface{}) {
 
 func (b *Bulk) buildDeleteValues(raw *Raw, hasTS bool) (string, []interface{}) {
 	filterColumns := sortedKeys(raw.Filter)
-	cacheKey := fmt.Sprintf("DELETE:%s:%s:%v", raw.Table, strings.Join(filterColumns, ","), hasTS)
+	fromColumns := sortedKeys(raw.FilterFrom)
+	cacheKey := fmt.Sprintf("DELETE:%s:%s:%s:%v",
+		b.qualifiedTable(raw), strings.Join(filterColumns, ","), strings.Join(fromColumns, ","), hasTS)
 	query := b.getCachedPreparedStatement(cacheKey, raw, "DELETE")
-	values := make([]interface{}, 0, len(filterColumns)+1)
+	values := make([]interface{}, 0, len(filterColumns)+len(fromColumns)+1)
 
 	if hasTS {
 		values = append(values, raw.Timestamp)
@@ -636,6 +1206,9 @@ func (b *Bulk) buildDeleteValues(raw *Raw, hasTS bool) (string, []interface{}) {
 	for _, col := range filterColumns {
 		values = append(values, raw.Filter[col])
 	}
+	for _, col := range fromColumns {
+		values = append(values, raw.FilterFrom[col])
+	}
 	return query, values
 }
 
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/Trendyol/go-dcp/models"
+	gocql "github.com/apache/cassandra-gocql-driver/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel"
@@ -83,7 +84,7 @@ func TestNewBulk_InvalidSession(t *testing.T) {
 
 func TestBulk_Insert_NilSession(t *testing.T) {
 	b := &Bulk{}
-	err := b.insert(&Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Insert})
+	err := b.insert(context.Background(), &Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Insert})
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "session is nil")
 }
@@ -92,33 +93,33 @@ func TestBulk_Insert_NilSession(t *testing.T) {
 
 func TestBulk_InsertUpdateDelete_Success(t *testing.T) {
 	b := newBulk(&mockSession{})
-	assert.NoError(t, b.insert(&Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Insert}))
-	assert.NoError(t, b.update(&Raw{
+	assert.NoError(t, b.insert(context.Background(), &Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Insert}))
+	assert.NoError(t, b.update(context.Background(), &Raw{
 		Table: "t", Document: map[string]interface{}{"name": "x"},
 		Filter: map[string]interface{}{"id": "1"}, Operation: Update,
 	}))
-	assert.NoError(t, b.delete(&Raw{Table: "t", Filter: map[string]interface{}{"id": "1"}, Operation: Delete}))
+	assert.NoError(t, b.delete(context.Background(), &Raw{Table: "t", Filter: map[string]interface{}{"id": "1"}, Operation: Delete}))
 }
 
 // --- Error handling ---
 
 func TestBulk_WorkerHandlesError(t *testing.T) {
 	b := newBulk(&mockSessionErr{})
-	err := b.insert(&Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Insert})
+	err := b.insert(context.Background(), &Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Insert})
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "mock error")
 }
 
 func TestBulk_ErrorHandling_Operations(t *testing.T) {
 	b := newBulk(&mockSessionErr{})
-	assert.Error(t, b.insert(&Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Insert}))
-	assert.Error(t, b.update(&Raw{
+	assert.Error(t, b.insert(context.Background(), &Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Insert}))
+	assert.Error(t, b.update(context.Background(), &Raw{
 		Table:     "t",
 		Document:  map[string]interface{}{"f": "v"},
 		Filter:    map[string]interface{}{"id": "1"},
 		Operation: Update,
 	}))
-	assert.Error(t, b.delete(&Raw{Table: "t", Filter: map[string]interface{}{"id": "1"}, Operation: Delete}))
+	assert.Error(t, b.delete(context.Background(), &Raw{Table: "t", Filter: map[string]interface{}{"id": "1"}, Operation: Delete}))
 }
 
 func TestBulk_WriteError_Panics(t *testing.T) {
@@ -131,6 +132,345 @@ func TestBulk_WriteError_Panics(t *testing.T) {
 	b.requestSync(context.Background(), BatchItem{Model: &Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Insert}})
 }
 
+// --- Session rebuild ---
+
+func TestBulk_RebuildsLostSession(t *testing.T) {
+	lost := &mockSessionLost{}
+	b := newBulk(lost)
+	b.connectRetry = config.ConnectRetry{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	attempts := 0
+	rebuilt := &mockSession{}
+	b.sessionFactory = func() (Session, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, fmt.Errorf("still down")
+		}
+		return rebuilt, nil
+	}
+
+	require.NoError(t, b.insert(context.Background(), &Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Insert}))
+	assert.Equal(t, 3, attempts)
+	assert.True(t, lost.closed, "lost session must be closed after rebuild")
+	assert.Same(t, rebuilt, b.currentSession())
+}
+
+func TestBulk_RebuildAbortedOnShutdown(t *testing.T) {
+	b := newBulk(&mockSessionLost{})
+	b.shutdownCh = make(chan struct{})
+	b.connectRetry = config.ConnectRetry{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	b.sessionFactory = func() (Session, error) { return nil, fmt.Errorf("still down") }
+
+	time.AfterFunc(10*time.Millisecond, func() { close(b.shutdownCh) })
+
+	err := b.insert(context.Background(), &Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Insert})
+	assert.ErrorIs(t, err, errBulkClosed)
+}
+
+func TestBulk_CloseDuringRebuildCancelsFlush(t *testing.T) {
+	b := newBulk(&mockSessionLost{})
+	b.shutdownCh = make(chan struct{})
+	b.writeCtx, b.cancelWrites = context.WithCancel(context.Background())
+	b.drainTimeout = time.Minute
+	b.batchSizeLimit = 1
+	b.connectRetry = config.ConnectRetry{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	rebuilding := make(chan struct{})
+	var once sync.Once
+	b.sessionFactory = func() (Session, error) {
+		once.Do(func() { close(rebuilding) })
+		return nil, fmt.Errorf("still down")
+	}
+	var committed, acked int32
+	b.dcpCheckpointCommit = func() { atomic.AddInt32(&committed, 1) }
+
+	go b.StartBulk()
+	b.AddActions(newListenerContext(func() { atomic.AddInt32(&acked, 1) }), time.Now(), []Model{
+		&Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Upsert},
+	})
+	<-rebuilding
+
+	done := make(chan struct{})
+	go func() {
+		b.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close did not abort the session rebuild")
+	}
+	assert.Zero(t, atomic.LoadInt32(&acked), "writes aborted by Close must not be acked")
+	assert.Zero(t, atomic.LoadInt32(&committed), "writes aborted by Close must not be committed")
+	assert.Zero(t, atomic.LoadInt64(&b.writeErrors))
+}
+
+func TestBulk_RebuildDoesNotBlockSessionReaders(t *testing.T) {
+	lost := &mockSessionLost{}
+	b := newBulk(lost)
+	b.shutdownCh = make(chan struct{})
+	b.connectRetry = config.ConnectRetry{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	rebuilding := make(chan struct{})
+	var once sync.Once
+	b.sessionFactory = func() (Session, error) {
+		once.Do(func() { close(rebuilding) })
+		return nil, fmt.Errorf("still down")
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- b.insert(context.Background(), &Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Upsert})
+	}()
+	<-rebuilding
+
+	read := make(chan Session, 1)
+	go func() { read <- b.currentSession() }()
+	select {
+	case session := <-read:
+		assert.Same(t, lost, session)
+	case <-time.After(time.Second):
+		t.Fatal("reading the session blocked on the rebuild")
+	}
+
+	close(b.shutdownCh)
+	assert.ErrorIs(t, <-errCh, errBulkClosed)
+}
+
+func TestBulk_NoRebuildForStatementErrors(t *testing.T) {
+	b := newBulk(&mockSessionErr{})
+	b.sessionFactory = func() (Session, error) {
+		t.Fatal("session must not be rebuilt for statement-level errors")
+		return nil, nil
+	}
+
+	assert.Error(t, b.insert(context.Background(), &Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Insert}))
+}
+
+// --- Dual-write ---
+
+func TestRunFlush_DualWrite_WritesBothClusters(t *testing.T) {
+	primaryCount, secondaryCount := int64(0), int64(0)
+	b := newBulk(&mockSessionCounting{count: &primaryCount})
+	b.secondary = newBulk(&mockSessionCounting{count: &secondaryCount})
+
+	batch := []BatchItem{
+		{Model: &Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Insert}},
+		{Model: &Raw{Table: "t", Document: map[string]interface{}{"id": "2"}, Operation: Insert}},
+	}
+	b.runFlush(context.Background(), batch, make(chan struct{}))
+
+	assert.Equal(t, int64(2), atomic.LoadInt64(&primaryCount))
+	assert.Equal(t, int64(2), atomic.LoadInt64(&secondaryCount))
+}
+
+func TestRunFlush_DualWrite_BestEffortSecondaryFailureIsMetered(t *testing.T) {
+	b := newBulk(&mockSession{})
+	b.secondary = newBulk(&mockSessionErr{})
+	b.secondary.bestEffort = true
+	b.secondary.startQueue(1)
+
+	acked := false
+	batch := []BatchItem{
+		{Model: &Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Insert}, Ack: func() { acked = true }},
+	}
+	b.runFlush(context.Background(), batch, make(chan struct{}))
+	close(b.secondary.queue)
+	<-b.secondary.queueDone
+
+	assert.True(t, acked, "best-effort secondary failures must not block the ack")
+	metrics := b.GetClusterMetrics()
+	require.Len(t, metrics, 2)
+	assert.Equal(t, clusterPrimary, metrics[0].Cluster)
+	assert.Equal(t, int64(0), metrics[0].WriteErrors)
+	assert.Equal(t, clusterSecondary, metrics[1].Cluster)
+	assert.Equal(t, int64(1), metrics[1].WriteErrors)
+}
+
+func TestRunFlush_DualWrite_BlockedBestEffortSecondaryDoesNotDelayAck(t *testing.T) {
+	started, release := make(chan struct{}, 3), make(chan struct{})
+	b := newBulk(&mockSession{})
+	b.secondary = newBulk(&mockSessionBlocking{onQuery: func() {
+		started <- struct{}{}
+		<-release
+	}})
+	b.secondary.bestEffort = true
+	b.secondary.startQueue(1)
+
+	flush := func(id string) chan struct{} {
+		acked := make(chan struct{})
+		batch := []BatchItem{
+			{Model: &Raw{Table: "t", Document: map[string]interface{}{"id": id}, Operation: Insert}, Ack: func() { close(acked) }},
+		}
+		go b.runFlush(context.Background(), batch, make(chan struct{}))
+		return acked
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		select {
+		case <-flush(id):
+		case <-time.After(time.Second):
+			t.Fatalf("flush %s was not acked while the secondary is blocked", id)
+		}
+		if id == "1" {
+			<-started
+		}
+	}
+
+	// The first flush blocks the secondary's writer, the second waits in
+	// its queue and the third does not fit.
+	assert.Equal(t, int64(1), b.GetClusterMetrics()[1].DroppedWrites)
+	close(release)
+	close(b.secondary.queue)
+	<-b.secondary.queueDone
+}
+
+func TestDualWrite_RequiredSecondaryFailurePanics(t *testing.T) {
+	secondary := newBulk(&mockSessionErr{})
+
+	assert.Panics(t, func() {
+		secondary.requestSync(context.Background(), BatchItem{
+			Model: &Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Insert},
+		})
+	})
+	assert.Equal(t, int64(1), secondary.clusterMetric(clusterSecondary).WriteErrors)
+}
+
+func TestGetClusterMetrics_SingleCluster(t *testing.T) {
+	b := newBulk(&mockSession{})
+	assert.Nil(t, b.GetClusterMetrics())
+}
+
+// --- Consistency overrides ---
+
+func TestResolveConsistency(t *testing.T) {
+	b := newBulk(&mockSession{})
+	b.operationConsistency = map[OperationType]Consistency{Delete: ConsistencyLocalQuorum}
+
+	assert.Equal(t, ConsistencyLocalQuorum, b.resolveConsistency(&Raw{Operation: Delete}))
+	assert.Equal(t, DefaultConsistency, b.resolveConsistency(&Raw{Operation: Upsert}))
+	assert.Equal(t, ConsistencyOne, b.resolveConsistency(&Raw{Operation: Delete, Consistency: ConsistencyOne}),
+		"statement consistency must take precedence over the per-operation override")
+}
+
+func TestSecondary_ResolvesOverridesPerCluster(t *testing.T) {
+	secondary := newBulk(&mockSession{})
+	secondary.keyspace = "ks2"
+	secondary.ownOverrides = true
+	secondary.operationConsistency = map[OperationType]Consistency{Upsert: ConsistencyLocalOne}
+
+	mapped := &Raw{Table: "t", Keyspace: "analytics", Operation: Upsert, Consistency: ConsistencyAll}
+	assert.Equal(t, "ks2", secondary.statementKeyspace(mapped), "configured overrides only apply to the primary")
+	assert.Equal(t, ConsistencyLocalOne, secondary.resolveConsistency(mapped))
+	assert.Equal(t, DefaultConsistency, secondary.resolveConsistency(&Raw{Operation: Delete, Consistency: ConsistencyAll}))
+
+	routed := &Raw{Table: "t", Keyspace: "tenant_a", RoutedKeyspace: true, Operation: Upsert}
+	assert.Equal(t, "tenant_a", secondary.statementKeyspace(routed), "routed keyspaces apply to every cluster")
+
+	primary := newBulk(&mockSession{})
+	assert.Equal(t, "analytics", primary.statementKeyspace(mapped))
+	assert.Equal(t, ConsistencyAll, primary.resolveConsistency(mapped))
+}
+
+func TestWriteUnloggedBatch_UsesStrongestConsistency(t *testing.T) {
+	batch := &mockBatchConsistency{}
+	b := newBulk(&mockSessionConsistency{batch: batch})
+	b.writeUnloggedBatch(context.Background(), []BatchItem{
+		{Model: &Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Insert, Consistency: ConsistencyLocalOne}},
+		{Model: &Raw{Table: "t", Document: map[string]interface{}{"id": "2"}, Operation: Insert, Consistency: ConsistencyQuorum}},
+	})
+	assert.Equal(t, ConsistencyQuorum, batch.consistency)
+}
+
+func TestWriteUnloggedBatch_CountsUnoverriddenAtSessionConsistency(t *testing.T) {
+	batch := &mockBatchConsistency{}
+	b := newBulk(&mockSessionConsistency{batch: batch})
+	b.consistency = ConsistencyQuorum
+	b.writeUnloggedBatch(context.Background(), []BatchItem{
+		{Model: &Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Upsert}},
+		{Model: &Raw{Table: "t", Document: map[string]interface{}{"id": "2"}, Operation: Upsert, Consistency: ConsistencyLocalOne}},
+	})
+	assert.Equal(t, ConsistencyQuorum, batch.consistency, "a weaker override must not weaken the session consistency")
+
+	b.consistency = ConsistencyOne
+	b.writeUnloggedBatch(context.Background(), []BatchItem{
+		{Model: &Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Upsert}},
+		{Model: &Raw{Table: "t", Document: map[string]interface{}{"id": "2"}, Operation: Upsert, Consistency: ConsistencyLocalQuorum}},
+	})
+	assert.Equal(t, ConsistencyLocalQuorum, batch.consistency)
+}
+
+func TestClusterConsistency(t *testing.T) {
+	assert.Equal(t, ConsistencyLocalQuorum, clusterConsistency("LOCAL_QUORUM"))
+	assert.Equal(t, ConsistencyQuorum, clusterConsistency(""))
+	assert.Equal(t, ConsistencyQuorum, clusterConsistency("INVALID"))
+}
+
+func TestBulk_StatementTimeout(t *testing.T) {
+	session := &mockSessionContext{timeouts: make(chan time.Duration, 2)}
+	b := newBulk(session)
+	b.statementTimeout = 2 * time.Second
+
+	require.NoError(t, b.insert(context.Background(), &Raw{Table: "t", Document: map[string]interface{}{"id": "1"}}))
+	assert.Equal(t, 2*time.Second, <-session.timeouts)
+
+	require.NoError(t, b.insert(context.Background(), &Raw{
+		Table: "t", Document: map[string]interface{}{"id": "1"}, Timeout: 50 * time.Millisecond,
+	}))
+	assert.Equal(t, 50*time.Millisecond, <-session.timeouts, "statement timeout overrides the configured one")
+}
+
+func TestWriteUnloggedBatch_UsesLongestTimeout(t *testing.T) {
+	batch := &mockBatchConsistency{}
+	b := newBulk(&mockSessionConsistency{batch: batch})
+	b.statementTimeout = time.Second
+	b.writeUnloggedBatch(context.Background(), []BatchItem{
+		{Model: &Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Insert}},
+		{Model: &Raw{Table: "t", Document: map[string]interface{}{"id": "2"}, Operation: Insert, Timeout: 3 * time.Second}},
+	})
+	assert.Equal(t, 3*time.Second, batch.timeout)
+}
+
+func TestBulk_CloseCancelsHungWritesAfterDrainTimeout(t *testing.T) {
+	session := &mockSessionContext{timeouts: make(chan time.Duration, 1), block: true}
+	b := newBulk(session)
+	b.shutdownCh = make(chan struct{})
+	b.writeCtx, b.cancelWrites = context.WithCancel(context.Background())
+	b.drainTimeout = 50 * time.Millisecond
+	b.batchSizeLimit = 1
+	var committed, acked int32
+	b.dcpCheckpointCommit = func() { atomic.AddInt32(&committed, 1) }
+
+	go b.StartBulk()
+	b.AddActions(newListenerContext(func() { atomic.AddInt32(&acked, 1) }), time.Now(), []Model{
+		&Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Insert},
+	})
+	<-session.timeouts
+
+	done := make(chan struct{})
+	go func() {
+		b.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close did not cancel the hung write")
+	}
+	assert.Zero(t, atomic.LoadInt32(&acked), "cancelled writes must not be acked")
+	assert.Zero(t, atomic.LoadInt32(&committed), "cancelled writes must not be committed")
+	assert.Zero(t, atomic.LoadInt64(&b.writeErrors))
+}
+
+func TestParseOperationConsistency(t *testing.T) {
+	parsed, err := parseOperationConsistency(map[string]string{"delete": "LOCAL_QUORUM", "upsert": "LOCAL_ONE"})
+	require.NoError(t, err)
+	assert.Equal(t, ConsistencyLocalQuorum, parsed[Delete])
+	assert.Equal(t, ConsistencyLocalOne, parsed[Upsert])
+
+	_, err = parseOperationConsistency(map[string]string{"delete": "NEVER"})
+	assert.Error(t, err)
+}
+
 // --- Flush triggered by size ---
 
 func TestFlush_TriggeredBySize(t *testing.T) {
@@ -155,6 +495,35 @@ func TestFlush_TriggeredBySize(t *testing.T) {
 	assert.Equal(t, int64(3), atomic.LoadInt64(&writeCount))
 }
 
+func TestAddActions_EmptyEventAckedAfterEarlierWrites(t *testing.T) {
+	writeCount := int64(0)
+	b := newBulk(&mockSessionCounting{count: &writeCount})
+	b.batchSizeLimit = 2
+	b.maxInFlightRequests = 10
+
+	var acks []string
+	var mu sync.Mutex
+	ack := func(name string) func() {
+		return func() {
+			mu.Lock()
+			defer mu.Unlock()
+			acks = append(acks, name)
+		}
+	}
+	b.AddActions(newListenerContext(ack("written")), time.Now(), []Model{
+		&Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Insert},
+	})
+	b.AddActions(newListenerContext(ack("filtered")), time.Now(), nil)
+
+	b.flushMu.Lock()
+	done := b.flushDone
+	b.flushMu.Unlock()
+	<-done
+
+	assert.Equal(t, int64(1), atomic.LoadInt64(&writeCount))
+	assert.Equal(t, []string{"written", "filtered"}, acks, "an event without models is acked with the flush")
+}
+
 // --- Flush triggered by ticker ---
 
 func TestFlush_TriggeredByTicker(t *testing.T) {
@@ -340,12 +709,12 @@ func runBatchPerEventTest(t *testing.T, batchPerEvent bool, expectedCount int64)
 
 	ctx := newListenerContext(func() {})
 	b.AddActions(ctx, time.Now(), []Model{
-		&Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Insert},
-		&Raw{Table: "t", Document: map[string]interface{}{"id": "2"}, Operation: Insert},
+		&Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Upsert},
+		&Raw{Table: "t", Document: map[string]interface{}{"id": "2"}, Operation: Upsert},
 	})
 	b.AddActions(ctx, time.Now(), []Model{
-		&Raw{Table: "t", Document: map[string]interface{}{"id": "3"}, Operation: Insert},
-		&Raw{Table: "t", Document: map[string]interface{}{"id": "4"}, Operation: Insert},
+		&Raw{Table: "t", Document: map[string]interface{}{"id": "3"}, Operation: Upsert},
+		&Raw{Table: "t", Document: map[string]interface{}{"id": "4"}, Operation: Upsert},
 	})
 
 	b.flushMu.Lock()
@@ -366,6 +735,29 @@ func TestBatchPerEvent_Disabled(t *testing.T) {
 	runBatchPerEventTest(t, false, 4)
 }
 
+func TestBatchPerEvent_ConditionalWrittenAlone(t *testing.T) {
+	count := int64(0)
+	b := newBulk(&mockSessionCounting{count: &count})
+	b.batchPerEvent = true
+	b.batchSizeLimit = 3
+	b.maxInFlightRequests = 10
+
+	ctx := newListenerContext(func() {})
+	b.AddActions(ctx, time.Now(), []Model{
+		&Raw{Table: "log", Document: map[string]interface{}{"id": "1"}, Operation: Insert},
+		&Raw{Table: "t", Document: map[string]interface{}{"id": "1"}, Operation: Upsert},
+		&Raw{Table: "u", Document: map[string]interface{}{"id": "1"}, Operation: Upsert},
+	})
+
+	b.flushMu.Lock()
+	done := b.flushDone
+	b.flushMu.Unlock()
+	<-done
+
+	// One INSERT IF NOT EXISTS and one UNLOGGED BATCH of the two upserts.
+	assert.Equal(t, int64(2), atomic.LoadInt64(&count))
+}
+
 // --- maxInFlightRequests caps concurrency ---
 
 func TestMaxInFlightRequests_CapsParallelism(t *testing.T) {
@@ -507,7 +899,7 @@ func TestBulk_ConcurrentInserts(t *testing.T) {
 	var wg sync.WaitGroup
 	for i := 0; i < 10; i++ {
 		wg.Go(func() {
-			_ = b.insert(&Raw{
+			_ = b.insert(context.Background(), &Raw{
 				Table:     fmt.Sprintf("table%d", i%3),
 				Document:  map[string]interface{}{"id": fmt.Sprintf("doc%d", i), "name": fmt.Sprintf("test%d", i)},
 				Operation: Insert,
@@ -683,6 +1075,59 @@ func TestBulk_SameColumns_ProduceSameCacheKey(t *testing.T) {
 	assert.Equal(t, q1, q2, "same column names must produce identical cached queries")
 }
 
+func TestBulk_UnsetValue_SharesStatement(t *testing.T) {
+	b := newBulk(&mockSession{})
+	raw1 := &Raw{Table: "t", Document: map[string]interface{}{"id": "1", "name": "a"}, Operation: Upsert}
+	raw2 := &Raw{Table: "t", Document: map[string]interface{}{"id": "2", "name": Unset}, Operation: Upsert}
+
+	q1, _ := b.buildInsertValues(raw1, false)
+	q2, values := b.buildInsertValues(raw2, false)
+
+	assert.Equal(t, q1, q2, "an unset column must not change the statement")
+	assert.Equal(t, []interface{}{"2", Unset}, values)
+	assert.Equal(t, len("id")+len("2")+len("name"), estimateSize(raw2))
+}
+
+func TestBulk_OperationStatements(t *testing.T) {
+	b := newBulk(&mockSession{})
+	document := map[string]interface{}{"id": "1", "name": "a"}
+	set := map[string]interface{}{"name": "a"}
+	filter := map[string]interface{}{"id": "1"}
+	tests := []struct {
+		raw      *Raw
+		query    string
+		expected []interface{}
+	}{
+		{
+			raw:      &Raw{Table: "t", Document: document, Operation: Upsert, Timestamp: 5},
+			query:    "INSERT INTO ks.t (id,name) VALUES (?,?) USING TIMESTAMP ?",
+			expected: []interface{}{"1", "a", int64(5)},
+		},
+		{
+			raw:      &Raw{Table: "t", Document: document, Operation: Insert, Timestamp: 5},
+			query:    "INSERT INTO ks.t (id,name) VALUES (?,?) IF NOT EXISTS",
+			expected: []interface{}{"1", "a"},
+		},
+		{
+			raw:      &Raw{Table: "t", Document: set, Filter: filter, Operation: Update, Timestamp: 5},
+			query:    "UPDATE ks.t USING TIMESTAMP ? SET name = ? WHERE id = ?",
+			expected: []interface{}{int64(5), "a", "1"},
+		},
+		{
+			raw:      &Raw{Table: "t", Document: set, Filter: filter, Operation: Update, IfExists: true, Timestamp: 5},
+			query:    "UPDATE ks.t SET name = ? WHERE id = ? IF EXISTS",
+			expected: []interface{}{"a", "1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, values := b.buildQueryAndValues(tt.raw)
+			assert.Equal(t, tt.query, query)
+			assert.Equal(t, tt.expected, values, "conditional statements have no timestamp")
+		})
+	}
+}
+
 // --- Regression: flush updates metrics atomically ---
 
 func TestFlush_UpdatesMetrics(t *testing.T) {
@@ -953,14 +1398,20 @@ func (m *mockSession) Close()                                     {}
 
 type mockQuery struct{}
 
-func (m *mockQuery) Exec() error { return nil }
+func (m *mockQuery) WithTimeout(time.Duration)         {}
+func (m *mockQuery) ExecContext(context.Context) error { return m.Exec() }
+func (m *mockQuery) WithConsistency(Consistency)       {}
+func (m *mockQuery) Exec() error                       { return nil }
 
 type mockBatch struct{ size int }
 
-func (m *mockBatch) Query(string, ...interface{}) { m.size++ }
-func (m *mockBatch) Size() int                    { return m.size }
-func (m *mockBatch) ExecuteBatch() error          { return nil }
-func (m *mockBatch) WithTimestamp(int64)          {}
+func (m *mockBatch) Query(string, ...interface{})              { m.size++ }
+func (m *mockBatch) Size() int                                 { return m.size }
+func (m *mockBatch) ExecuteBatch() error                       { return nil }
+func (m *mockBatch) WithTimestamp(int64)                       {}
+func (m *mockBatch) WithTimeout(time.Duration)                 {}
+func (m *mockBatch) ExecuteBatchContext(context.Context) error { return m.ExecuteBatch() }
+func (m *mockBatch) WithConsistency(Consistency)               {}
 
 type mockSessionErr struct{}
 
@@ -971,14 +1422,81 @@ func (m *mockSessionErr) Close()                                     {}
 
 type mockQueryErr struct{}
 
-func (m *mockQueryErr) Exec() error { return fmt.Errorf("mock error") }
+func (m *mockQueryErr) WithTimeout(time.Duration)         {}
+func (m *mockQueryErr) ExecContext(context.Context) error { return m.Exec() }
+func (m *mockQueryErr) WithConsistency(Consistency)       {}
+func (m *mockQueryErr) Exec() error                       { return fmt.Errorf("mock error") }
 
 type mockBatchErr struct{ size int }
 
-func (m *mockBatchErr) Query(string, ...interface{}) { m.size++ }
-func (m *mockBatchErr) Size() int                    { return m.size }
-func (m *mockBatchErr) ExecuteBatch() error          { return fmt.Errorf("mock batch error") }
-func (m *mockBatchErr) WithTimestamp(int64)          {}
+func (m *mockBatchErr) Query(string, ...interface{})              { m.size++ }
+func (m *mockBatchErr) Size() int                                 { return m.size }
+func (m *mockBatchErr) ExecuteBatch() error                       { return fmt.Errorf("mock batch error") }
+func (m *mockBatchErr) WithTimestamp(int64)                       {}
+func (m *mockBatchErr) WithTimeout(time.Duration)                 {}
+func (m *mockBatchErr) ExecuteBatchContext(context.Context) error { return m.ExecuteBatch() }
+func (m *mockBatchErr) WithConsistency(Consistency)               {}
+
+// mockSessionLost behaves like a session whose connection pool is empty.
+type mockSessionLost struct{ closed bool }
+
+func (m *mockSessionLost) Query(string, ...interface{}) Query         { return &mockQueryLost{} }
+func (m *mockSessionLost) PreparedQuery(string, ...interface{}) Query { return &mockQueryLost{} }
+func (m *mockSessionLost) NewBatch(BatchType) Batch                   { return &mockBatch{} }
+func (m *mockSessionLost) Close()                                     { m.closed = true }
+
+type mockQueryLost struct{}
+
+func (m *mockQueryLost) WithTimeout(time.Duration)         {}
+func (m *mockQueryLost) ExecContext(context.Context) error { return m.Exec() }
+func (m *mockQueryLost) WithConsistency(Consistency)       {}
+func (m *mockQueryLost) Exec() error                       { return gocql.ErrNoConnections }
+
+// mockSessionConsistency hands out a single batch that records its consistency.
+type mockSessionConsistency struct{ batch *mockBatchConsistency }
+
+func (m *mockSessionConsistency) Query(string, ...interface{}) Query         { return &mockQuery{} }
+func (m *mockSessionConsistency) PreparedQuery(string, ...interface{}) Query { return &mockQuery{} }
+func (m *mockSessionConsistency) NewBatch(BatchType) Batch                   { return m.batch }
+func (m *mockSessionConsistency) Close()                                     {}
+
+type mockBatchConsistency struct {
+	mockBatch
+	consistency Consistency
+	timeout     time.Duration
+}
+
+func (m *mockBatchConsistency) WithConsistency(c Consistency) { m.consistency = c }
+func (m *mockBatchConsistency) WithTimeout(d time.Duration)   { m.timeout = d }
+
+// mockSessionContext hands out queries that record their timeout and, when
+// block is set, hang until their context is cancelled.
+type mockSessionContext struct {
+	mockSession
+	timeouts chan time.Duration
+	block    bool
+}
+
+func (m *mockSessionContext) PreparedQuery(string, ...interface{}) Query {
+	return &mockQueryContext{session: m}
+}
+
+type mockQueryContext struct {
+	session *mockSessionContext
+	timeout time.Duration
+}
+
+func (m *mockQueryContext) WithConsistency(Consistency) {}
+func (m *mockQueryContext) WithTimeout(d time.Duration) { m.timeout = d }
+func (m *mockQueryContext) Exec() error                 { return m.ExecContext(context.Background()) }
+func (m *mockQueryContext) ExecContext(ctx context.Context) error {
+	m.session.timeouts <- m.timeout
+	if m.session.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
 
 // mockSessionOrdered tracks the order of PreparedQuery and Close calls.
 type mockSessionOrdered struct {
@@ -1036,9 +1554,12 @@ type mockBatchCounting struct {
 	size  int
 }
 
-func (m *mockBatchCounting) Query(string, ...interface{}) { m.size++ }
-func (m *mockBatchCounting) Size() int                    { return m.size }
-func (m *mockBatchCounting) WithTimestamp(int64)          {}
+func (m *mockBatchCounting) Query(string, ...interface{})              { m.size++ }
+func (m *mockBatchCounting) Size() int                                 { return m.size }
+func (m *mockBatchCounting) WithTimestamp(int64)                       {}
+func (m *mockBatchCounting) WithTimeout(time.Duration)                 {}
+func (m *mockBatchCounting) ExecuteBatchContext(context.Context) error { return m.ExecuteBatch() }
+func (m *mockBatchCounting) WithConsistency(Consistency)               {}
 func (m *mockBatchCounting) ExecuteBatch() error {
 	atomic.AddInt64(m.count, 1)
 	return nil
@@ -1051,3 +1572,92 @@ func (m *mockSessionBatchErr) Query(string, ...interface{}) Query         { retu
 func (m *mockSessionBatchErr) PreparedQuery(string, ...interface{}) Query { return &mockQuery{} }
 func (m *mockSessionBatchErr) Close()                                     {}
 func (m *mockSessionBatchErr) NewBatch(BatchType) Batch                   { return &mockBatchErr{} }
+
+func TestBuildQueryAndValues_KeyspaceOverride(t *testing.T) {
+	b := &Bulk{keyspace: "default_ks", preparedStmts: make(map[string]string)}
+
+	query, _ := b.buildQueryAndValues(&Raw{
+		Table: "orders", Keyspace: "tenant_a", Document: map[string]interface{}{"id": "1"}, Operation: Upsert,
+	})
+	assert.Equal(t, "INSERT INTO tenant_a.orders (id) VALUES (?)", query)
+
+	query, _ = b.buildQueryAndValues(&Raw{
+		Table: "orders", Document: map[string]interface{}{"id": "1"}, Operation: Upsert,
+	})
+	assert.Equal(t, "INSERT INTO default_ks.orders (id) VALUES (?)", query,
+		"same shape in another keyspace must not reuse the cached statement")
+
+	query, _ = b.buildQueryAndValues(&Raw{
+		Table: "orders", Keyspace: "tenant_b", Filter: map[string]interface{}{"id": "1"}, Operation: Delete,
+	})
+	assert.Equal(t, "DELETE FROM tenant_b.orders WHERE id = ?", query)
+	assert.Len(t, b.preparedStmts, 3)
+}
+
+func TestBuildQueryAndValues_RangeDelete(t *testing.T) {
+	b := &Bulk{keyspace: "ks", preparedStmts: make(map[string]string)}
+
+	query, values := b.buildQueryAndValues(&Raw{
+		Table:      "order_items",
+		Filter:     map[string]interface{}{"order_id": "o1"},
+		FilterFrom: map[string]interface{}{"item_index": 2},
+		Operation:  Delete,
+		Timestamp:  10,
+	})
+	assert.Equal(t, "DELETE FROM ks.order_items USING TIMESTAMP ? WHERE order_id = ? AND item_index >= ?", query)
+	assert.Equal(t, []interface{}{int64(10), "o1", 2}, values)
+
+	query, _ = b.buildQueryAndValues(&Raw{
+		Table: "order_items", Filter: map[string]interface{}{"order_id": "o1", "item_index": 2}, Operation: Delete, Timestamp: 10,
+	})
+	assert.Equal(t, "DELETE FROM ks.order_items USING TIMESTAMP ? WHERE item_index = ? AND order_id = ?", query,
+		"a range delete must not reuse the statement of a point delete")
+}
+
+func TestNewBulk_WithSession(t *testing.T) {
+	cfg := &config.Connector{Cassandra: config.Cassandra{Hosts: []string{"unused"}, Keyspace: "ks"}}
+	cfg.ApplyDefaults()
+	session := &mockSession{}
+
+	b, err := NewBulk(cfg, func() {}, WithSession(session))
+	require.NoError(t, err)
+	assert.Same(t, session, b.currentSession())
+	assert.Nil(t, b.sessionFactory, "an injected session is not rebuilt without a factory")
+}
+
+func TestNewBulk_WithSessionFactory(t *testing.T) {
+	cfg := &config.Connector{
+		Cassandra: config.Cassandra{Hosts: []string{"primary"}, Keyspace: "ks"},
+		DualWrite: &config.DualWrite{
+			Policy:    config.DualWritePolicyRequireBoth,
+			Cassandra: config.Cassandra{Hosts: []string{"secondary"}, Keyspace: "ks2"},
+		},
+	}
+	cfg.ApplyDefaults()
+
+	var hosts []string
+	factory := func(c config.Cassandra) (Session, error) {
+		hosts = append(hosts, c.Hosts[0])
+		return &mockSession{}, nil
+	}
+
+	b, err := NewBulk(cfg, func() {}, WithSessionFactory(factory))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"primary", "secondary"}, hosts)
+
+	_, err = b.sessionFactory()
+	require.NoError(t, err)
+	_, err = b.secondary.sessionFactory()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"primary", "secondary", "primary", "secondary"}, hosts, "rebuilds use the factory")
+}
+
+func TestNewBulk_SessionFactoryError(t *testing.T) {
+	cfg := &config.Connector{Cassandra: config.Cassandra{Hosts: []string{"h"}, Keyspace: "ks"}}
+	cfg.ApplyDefaults()
+	factory := func(config.Cassandra) (Session, error) { return nil, fmt.Errorf("refused") }
+
+	_, err := NewBulk(cfg, func() {}, WithSessionFactory(factory))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "refused")
+}
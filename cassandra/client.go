@@ -2,7 +2,10 @@ package cassandra
 
 import (
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"log"
+	"time"
 
 	gocql "github.com/apache/cassandra-gocql-driver/v2"
 	"github.com/apache/cassandra-gocql-driver/v2/lz4"
@@ -11,8 +14,13 @@ import (
 	"github.com/Trendyol/go-dcp-cassandra/config"
 )
 
-//nolint:funlen
+// NewCassandraSession opens a session for cfg with a fresh HostObserver.
 func NewCassandraSession(cfg config.Cassandra) (Session, error) {
+	return newCassandraSession(cfg, NewHostObserver())
+}
+
+//nolint:funlen
+func newCassandraSession(cfg config.Cassandra, observer *HostObserver) (Session, error) {
 	cluster := gocql.NewCluster(cfg.Hosts...)
 	cluster.Keyspace = cfg.Keyspace
 
@@ -114,6 +122,11 @@ func NewCassandraSession(cfg config.Cassandra) (Session, error) {
 		cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(gocql.RoundRobinHostPolicy(), gocql.ShuffleReplicas())
 	}
 
+	cluster.QueryObserver = observer
+	cluster.BatchObserver = observer
+	cluster.ConnectObserver = observer
+	cluster.Metadata.HostListener.HostStateChangeListener = observer
+
 	session, err := cluster.CreateSession()
 	if err != nil {
 		log.Printf("Failed to create Cassandra session: %v", err)
@@ -121,3 +134,34 @@ func NewCassandraSession(cfg config.Cassandra) (Session, error) {
 	}
 	return NewGocqlSessionAdapter(session), nil
 }
+
+// connectWithRetry calls factory until it returns a session, backing off
+// exponentially between attempts. It gives up once the next attempt would
+// start after deadline (a zero deadline retries forever) or stop is closed.
+func connectWithRetry(
+	factory func() (Session, error), retry config.ConnectRetry, deadline time.Time, stop <-chan struct{},
+) (Session, error) {
+	backoff := retry.MinBackoff
+	for attempt := 1; ; attempt++ {
+		session, err := factory()
+		if err == nil {
+			return session, nil
+		}
+		if !deadline.IsZero() && time.Now().Add(backoff).After(deadline) {
+			return nil, fmt.Errorf("could not connect to cassandra after %d attempt(s): %w", attempt, err)
+		}
+		log.Printf("Cassandra connection attempt %d failed, retrying in %s: %v", attempt, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-stop:
+			return nil, errBulkClosed
+		}
+		backoff = min(backoff*2, retry.MaxBackoff)
+	}
+}
+
+// isSessionLost reports whether err means the session has no usable
+// connection left, as opposed to a failure of the individual statement.
+func isSessionLost(err error) bool {
+	return errors.Is(err, gocql.ErrNoConnections) || errors.Is(err, gocql.ErrSessionClosed)
+}
@@ -1,7 +1,9 @@
 package cassandra
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	gocql "github.com/apache/cassandra-gocql-driver/v2"
 	"github.com/stretchr/testify/assert"
@@ -111,3 +113,53 @@ func TestNewCassandraSession_ConsistencyLevels(t *testing.T) {
 		})
 	}
 }
+
+func TestConnectWithRetry_SucceedsAfterFailures(t *testing.T) {
+	attempts := 0
+	factory := func() (Session, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, errors.New("connection refused")
+		}
+		return &mockSession{}, nil
+	}
+	retry := config.ConnectRetry{MinBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+	session, err := connectWithRetry(factory, retry, time.Now().Add(time.Second), nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, session)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestConnectWithRetry_GivesUpAfterDeadline(t *testing.T) {
+	attempts := 0
+	factory := func() (Session, error) {
+		attempts++
+		return nil, errors.New("connection refused")
+	}
+	retry := config.ConnectRetry{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	_, err := connectWithRetry(factory, retry, time.Now().Add(20*time.Millisecond), nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "connection refused")
+	assert.Greater(t, attempts, 1)
+}
+
+func TestConnectWithRetry_ZeroMaxDurationTriesOnce(t *testing.T) {
+	attempts := 0
+	factory := func() (Session, error) {
+		attempts++
+		return nil, errors.New("connection refused")
+	}
+	retry := config.ConnectRetry{MinBackoff: time.Second, MaxBackoff: time.Second}
+
+	_, err := connectWithRetry(factory, retry, time.Now(), nil)
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestIsSessionLost(t *testing.T) {
+	assert.True(t, isSessionLost(gocql.ErrNoConnections))
+	assert.True(t, isSessionLost(gocql.ErrSessionClosed))
+	assert.False(t, isSessionLost(errors.New("mock error")))
+}
@@ -1,14 +1,31 @@
 package cassandra
 
+import (
+	"time"
+
+	gocql "github.com/apache/cassandra-gocql-driver/v2"
+)
+
 type OperationType string
 
 const (
+	// Insert writes Document only if the row does not exist yet, with
+	// INSERT ... IF NOT EXISTS.
 	Insert OperationType = "insert"
+	// Update sets the Document columns of the row keyed by Filter, and only
+	// if it exists when IfExists is set.
 	Update OperationType = "update"
+	// Delete deletes the rows keyed by Filter and FilterFrom.
 	Delete OperationType = "delete"
+	// Upsert writes Document whether or not the row exists, with INSERT.
 	Upsert OperationType = "upsert"
 )
 
+// Unset is a Document value that leaves its column as it is instead of
+// writing null, which would create a tombstone. The column stays in the
+// statement, so rows with and without a value share one prepared statement.
+var Unset = gocql.UnsetValue
+
 type Model interface {
 	Convert() *ExecArgs
 }
@@ -21,20 +38,63 @@ type Raw struct {
 	RowKey    map[string]interface{}
 	ID        string
 	Timestamp int64
+	// Keyspace overrides the cluster keyspace for this statement. Empty
+	// keeps it. Unless RoutedKeyspace is set, it only applies to the primary
+	// cluster; the dual-write secondary writes to its own keyspace.
+	Keyspace string
+	// RoutedKeyspace marks Keyspace as resolved from the document, e.g. per
+	// tenant, rather than configured, so every cluster writes to it.
+	RoutedKeyspace bool
+	// Consistency overrides the cluster and per-operation consistency for
+	// this statement on the primary cluster. The zero value keeps them. The
+	// dual-write secondary always uses its own.
+	Consistency Consistency
+	// Timeout overrides cassandra.statementTimeout for this statement.
+	Timeout time.Duration
+	// FilterFrom adds `column >= ?` conditions to a delete, making it a
+	// range delete over clustering columns.
+	FilterFrom map[string]interface{}
+	// IfExists makes an Update conditional on the row existing.
+	IfExists bool
 }
 
 type ExecArgs struct {
-	Document  map[string]interface{}
-	Filter    map[string]interface{}
-	Table     string
-	Operation OperationType
+	Document       map[string]interface{}
+	Filter         map[string]interface{}
+	FilterFrom     map[string]interface{}
+	Table          string
+	Keyspace       string
+	RoutedKeyspace bool
+	Operation      OperationType
+	Consistency    Consistency
+	Timeout        time.Duration
+	IfExists       bool
 }
 
 func (r *Raw) Convert() *ExecArgs {
 	return &ExecArgs{
-		Table:     r.Table,
-		Document:  r.Document,
-		Operation: r.Operation,
-		Filter:    r.Filter,
+		Table:          r.Table,
+		Keyspace:       r.Keyspace,
+		RoutedKeyspace: r.RoutedKeyspace,
+		Document:       r.Document,
+		Operation:      r.Operation,
+		Filter:         r.Filter,
+		FilterFrom:     r.FilterFrom,
+		Consistency:    r.Consistency,
+		Timeout:        r.Timeout,
+		IfExists:       r.IfExists,
 	}
 }
+
+// conditional reports whether r is a lightweight transaction: an Insert, or
+// an Update with IfExists.
+func (r *Raw) conditional() bool {
+	return r.Operation == Insert || (r.Operation == Update && r.IfExists)
+}
+
+// writesTimestamp reports whether the statement of r has USING TIMESTAMP.
+// Cassandra rejects custom timestamps on lightweight transactions, so
+// conditional statements never do.
+func (r *Raw) writesTimestamp() bool {
+	return r.Timestamp > 0 && !r.conditional()
+}
@@ -0,0 +1,152 @@
+package cassandra
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	gocql "github.com/apache/cassandra-gocql-driver/v2"
+)
+
+// HostLatencyBucketsMs are the upper bounds, in milliseconds, of the per-host
+// latency histogram.
+var HostLatencyBucketsMs = []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000}
+
+const unknownHost = "unknown"
+
+// HostMetric is a snapshot of the driver activity against one Cassandra node.
+type HostMetric struct {
+	// LatencyBuckets maps each bound of HostLatencyBucketsMs to the number of
+	// attempts that took at most that long.
+	LatencyBuckets map[float64]uint64
+	Cluster        string
+	Host           string
+	LatencySumMs   float64
+	LatencyCount   uint64
+	Errors         uint64
+	Retries        uint64
+	Connects       uint64
+	ConnectErrors  uint64
+	Ups            uint64
+	Downs          uint64
+}
+
+type hostStats struct {
+	buckets       []uint64
+	latencySumMs  float64
+	latencyCount  uint64
+	errors        uint64
+	retries       uint64
+	connects      uint64
+	connectErrors uint64
+	ups           uint64
+	downs         uint64
+}
+
+// HostObserver collects per-host statistics from gocql. It is registered as
+// the query, batch and connect observer and as the host state listener of
+// every session built for one cluster, so its counters survive session
+// rebuilds.
+type HostObserver struct {
+	hosts map[string]*hostStats
+	mu    sync.Mutex
+}
+
+func NewHostObserver() *HostObserver {
+	return &HostObserver{hosts: make(map[string]*hostStats)}
+}
+
+func (o *HostObserver) ObserveQuery(_ context.Context, q gocql.ObservedQuery) {
+	o.observeAttempt(q.Host, q.End.Sub(q.Start).Seconds()*1000, q.Attempt, q.Err)
+}
+
+func (o *HostObserver) ObserveBatch(_ context.Context, b gocql.ObservedBatch) {
+	o.observeAttempt(b.Host, b.End.Sub(b.Start).Seconds()*1000, b.Attempt, b.Err)
+}
+
+func (o *HostObserver) ObserveConnect(c gocql.ObservedConnect) {
+	o.update(c.Host, func(stats *hostStats) {
+		if c.Err != nil {
+			stats.connectErrors++
+		} else {
+			stats.connects++
+		}
+	})
+}
+
+func (o *HostObserver) OnHostUp(event gocql.HostUpEvent) {
+	o.update(event.Host, func(stats *hostStats) { stats.ups++ })
+}
+
+func (o *HostObserver) OnHostDown(event gocql.HostDownEvent) {
+	o.update(event.Host, func(stats *hostStats) { stats.downs++ })
+}
+
+func (o *HostObserver) observeAttempt(host *gocql.HostInfo, latencyMs float64, attempt int, err error) {
+	o.update(host, func(stats *hostStats) {
+		for i, bound := range HostLatencyBucketsMs {
+			if latencyMs <= bound {
+				stats.buckets[i]++
+			}
+		}
+		stats.latencySumMs += latencyMs
+		stats.latencyCount++
+		if attempt > 0 {
+			stats.retries++
+		}
+		// Warm-up and re-prepare queries fail on purpose once prepared.
+		if err != nil && !errors.Is(err, errPrepareOnly) {
+			stats.errors++
+		}
+	})
+}
+
+func (o *HostObserver) update(host *gocql.HostInfo, fn func(*hostStats)) {
+	name := unknownHost
+	if host != nil {
+		name = host.ConnectAddressAndPort()
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	stats, ok := o.hosts[name]
+	if !ok {
+		stats = &hostStats{buckets: make([]uint64, len(HostLatencyBucketsMs))}
+		o.hosts[name] = stats
+	}
+	fn(stats)
+}
+
+// Snapshot returns the statistics of every host seen so far, sorted by host.
+func (o *HostObserver) Snapshot(cluster string) []HostMetric {
+	if o == nil {
+		return nil
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	metrics := make([]HostMetric, 0, len(o.hosts))
+	for host, stats := range o.hosts {
+		buckets := make(map[float64]uint64, len(HostLatencyBucketsMs))
+		for i, bound := range HostLatencyBucketsMs {
+			buckets[bound] = stats.buckets[i]
+		}
+		metrics = append(metrics, HostMetric{
+			Cluster:        cluster,
+			Host:           host,
+			LatencyBuckets: buckets,
+			LatencySumMs:   stats.latencySumMs,
+			LatencyCount:   stats.latencyCount,
+			Errors:         stats.errors,
+			Retries:        stats.retries,
+			Connects:       stats.connects,
+			ConnectErrors:  stats.connectErrors,
+			Ups:            stats.ups,
+			Downs:          stats.downs,
+		})
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Host < metrics[j].Host })
+	return metrics
+}
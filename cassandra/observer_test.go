@@ -0,0 +1,107 @@
+package cassandra
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	gocql "github.com/apache/cassandra-gocql-driver/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testHost(t *testing.T, ip string) *gocql.HostInfo {
+	t.Helper()
+	host, err := gocql.NewHostInfoFromAddrPort(net.ParseIP(ip), 9042)
+	require.NoError(t, err)
+	return host
+}
+
+func TestHostObserver_QueriesAndBatches(t *testing.T) {
+	observer := NewHostObserver()
+	host := testHost(t, "10.0.0.1")
+	start := time.Now()
+
+	observer.ObserveQuery(context.Background(), gocql.ObservedQuery{
+		Host: host, Start: start, End: start.Add(3 * time.Millisecond),
+	})
+	observer.ObserveQuery(context.Background(), gocql.ObservedQuery{
+		Host: host, Start: start, End: start.Add(300 * time.Millisecond), Attempt: 1, Err: errors.New("timeout"),
+	})
+	observer.ObserveBatch(context.Background(), gocql.ObservedBatch{
+		Host: host, Start: start, End: start.Add(40 * time.Millisecond),
+	})
+
+	metrics := observer.Snapshot(clusterPrimary)
+	require.Len(t, metrics, 1)
+	m := metrics[0]
+	assert.Equal(t, "10.0.0.1:9042", m.Host)
+	assert.Equal(t, clusterPrimary, m.Cluster)
+	assert.Equal(t, uint64(3), m.LatencyCount)
+	assert.InDelta(t, 343, m.LatencySumMs, 0.001)
+	assert.Equal(t, uint64(0), m.LatencyBuckets[2])
+	assert.Equal(t, uint64(1), m.LatencyBuckets[5])
+	assert.Equal(t, uint64(2), m.LatencyBuckets[50])
+	assert.Equal(t, uint64(3), m.LatencyBuckets[500])
+	assert.Equal(t, uint64(1), m.Errors)
+	assert.Equal(t, uint64(1), m.Retries)
+}
+
+func TestHostObserver_PrepareOnlyIsNotAnError(t *testing.T) {
+	observer := NewHostObserver()
+	start := time.Now()
+
+	observer.ObserveQuery(context.Background(), gocql.ObservedQuery{
+		Host: testHost(t, "10.0.0.1"), Start: start, End: start.Add(time.Millisecond), Err: errPrepareOnly,
+	})
+
+	metrics := observer.Snapshot(clusterPrimary)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, uint64(1), metrics[0].LatencyCount)
+	assert.Equal(t, uint64(0), metrics[0].Errors)
+}
+
+func TestHostObserver_ConnectionEvents(t *testing.T) {
+	observer := NewHostObserver()
+	a := testHost(t, "10.0.0.1")
+	b := testHost(t, "10.0.0.2")
+
+	observer.ObserveConnect(gocql.ObservedConnect{Host: b})
+	observer.ObserveConnect(gocql.ObservedConnect{Host: a, Err: errors.New("refused")})
+	observer.OnHostDown(gocql.HostDownEvent{Host: a})
+	observer.OnHostUp(gocql.HostUpEvent{Host: a})
+
+	metrics := observer.Snapshot(clusterSecondary)
+	require.Len(t, metrics, 2)
+	assert.Equal(t, "10.0.0.1:9042", metrics[0].Host, "sorted by host")
+	assert.Equal(t, uint64(1), metrics[0].ConnectErrors)
+	assert.Equal(t, uint64(1), metrics[0].Downs)
+	assert.Equal(t, uint64(1), metrics[0].Ups)
+	assert.Equal(t, uint64(1), metrics[1].Connects)
+}
+
+func TestHostObserver_UnknownHost(t *testing.T) {
+	observer := NewHostObserver()
+	observer.ObserveQuery(context.Background(), gocql.ObservedQuery{})
+
+	metrics := observer.Snapshot(clusterPrimary)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, unknownHost, metrics[0].Host)
+}
+
+func TestBulk_GetHostMetrics(t *testing.T) {
+	assert.Empty(t, (&Bulk{}).GetHostMetrics())
+
+	primary := NewHostObserver()
+	primary.ObserveConnect(gocql.ObservedConnect{Host: testHost(t, "10.0.0.1")})
+	secondary := NewHostObserver()
+	secondary.ObserveConnect(gocql.ObservedConnect{Host: testHost(t, "10.1.0.1")})
+
+	b := &Bulk{hostObserver: primary, secondary: &Bulk{hostObserver: secondary}}
+	metrics := b.GetHostMetrics()
+	require.Len(t, metrics, 2)
+	assert.Equal(t, clusterPrimary, metrics[0].Cluster)
+	assert.Equal(t, clusterSecondary, metrics[1].Cluster)
+}
@@ -0,0 +1,257 @@
+package cassandra
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	gocql "github.com/apache/cassandra-gocql-driver/v2"
+
+	"github.com/Trendyol/go-dcp-cassandra/config"
+)
+
+// Preparer is implemented by sessions that can prepare a statement ahead of
+// its first execution. Sessions that do not implement it prepare lazily.
+type Preparer interface {
+	Prepare(stmt string) error
+}
+
+// errPrepareOnly is returned by the binding of a prepare-only query, so the
+// driver prepares the statement but never executes it.
+var errPrepareOnly = errors.New("statement prepared without executing")
+
+// statementGeneration marks the text of a statement re-prepared after its
+// table changed. gocql caches prepared statements by their text, so a new
+// generation is the only way to make the driver prepare it again.
+const statementGeneration = " /* generation "
+
+// Prepare prepares stmt on every node that is up and stores it in gocql's
+// per-node prepared statement cache, so a statement that does not match the
+// schema fails at startup and the first write to a node does not prepare it
+// inline. Nodes the session holds no connection to prepare it on their first
+// write.
+func (s *GocqlSessionAdapter) Prepare(stmt string) error {
+	prepareOnly := func(*gocql.QueryInfo) ([]interface{}, error) { return nil, errPrepareOnly }
+
+	var (
+		prepared int
+		errs     []error
+	)
+	for _, host := range s.GetHosts() {
+		if !host.IsUp() {
+			continue
+		}
+		err := s.Bind(stmt, prepareOnly).SetHostID(host.HostID()).RetryPolicy(&gocql.SimpleRetryPolicy{}).Exec()
+		switch {
+		case err == nil || errors.Is(err, errPrepareOnly):
+			prepared++
+		case errors.Is(err, gocql.ErrNoConnections):
+		default:
+			errs = append(errs, fmt.Errorf("host %s: %w", host.ConnectAddressAndPort(), err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	if prepared == 0 {
+		return gocql.ErrNoConnections
+	}
+	return nil
+}
+
+// isStatementStale reports whether err means a prepared statement no longer
+// matches the server: either the server forgot it after the driver already
+// re-prepared it once, or the bound values no longer match the columns the
+// driver prepared it with after a schema change.
+func isStatementStale(err error) bool {
+	if err == nil {
+		return false
+	}
+	var unprepared *gocql.RequestErrUnprepared
+	if errors.As(err, &unprepared) {
+		return true
+	}
+	return strings.Contains(err.Error(), "values send got")
+}
+
+// withGeneration returns the text of stmt for generation of its table.
+// Generation 0 is the plain statement.
+func withGeneration(stmt string, generation int) string {
+	stmt, _, _ = strings.Cut(stmt, statementGeneration)
+	if generation == 0 {
+		return stmt
+	}
+	return fmt.Sprintf("%s%s%d */", stmt, statementGeneration, generation)
+}
+
+// mappingStatements returns one template per statement shape the default
+// mapper produces for mappings: the write of every mapped column and the
+// delete by primaryKeyFields (or by every _key and document field column),
+// or the soft delete UPDATE, depending on deleteMode. Exploded mappings
+// delete by their parent key, and also range delete from an element index
+// on. Mappings with a templated keyspace or table are skipped.
+func mappingStatements(mappings []config.CollectionTableMapping, withTimestamp bool) []*Raw {
+	var timestamp int64
+	if withTimestamp {
+		timestamp = 1
+	}
+
+	var statements []*Raw
+	for _, mapping := range mappings {
+		// Templated keyspaces and tables are only known per document.
+		if _, static := mapping.StaticKeyspace(""); !static || !mapping.StaticTable() || len(mapping.FieldMappings) == 0 {
+			continue
+		}
+
+		document := make(map[string]interface{}, len(mapping.FieldMappings))
+		filter := make(map[string]interface{})
+		for column, source := range mapping.FieldMappings {
+			document[column] = nil
+			if source != "documentData" && !config.IsMetadataSource(source) && !config.IsExpressionSource(source) {
+				filter[column] = nil
+			}
+		}
+		keyFields := mapping.PrimaryKeyFields
+		if mapping.Explode != "" {
+			keyFields = mapping.ParentKeyFields()
+		}
+		if len(keyFields) > 0 {
+			filter = make(map[string]interface{}, len(keyFields))
+			for _, column := range keyFields {
+				filter[column] = nil
+			}
+		}
+
+		statements = append(statements, writeStatement(mapping, document, timestamp))
+		if len(filter) > 0 && mapping.DeleteMode.Uses(config.DeleteModeHard) {
+			statements = append(statements, &Raw{
+				Table: mapping.TableName, Keyspace: mapping.Keyspace, Filter: filter, Operation: Delete, Timestamp: timestamp,
+			})
+		}
+		if columns := mapping.SoftDeleteColumns(); len(columns) > 0 {
+			softDeleted := make(map[string]interface{}, len(columns))
+			for _, column := range columns {
+				softDeleted[column] = nil
+			}
+			statements = append(statements, &Raw{
+				Table: mapping.TableName, Keyspace: mapping.Keyspace, Document: softDeleted, Filter: filter,
+				Operation: Update, Timestamp: timestamp,
+			})
+		}
+		if mapping.Explode != "" {
+			statements = append(statements, &Raw{
+				Table: mapping.TableName, Keyspace: mapping.Keyspace, Filter: filter, Operation: Delete, Timestamp: timestamp,
+				FilterFrom: map[string]interface{}{mapping.IndexColumn(): nil},
+			})
+		}
+	}
+	return statements
+}
+
+// writeStatement returns the template of the mutation statement of
+// mapping's writeMode, writing document.
+func writeStatement(mapping config.CollectionTableMapping, document map[string]interface{}, timestamp int64) *Raw {
+	raw := &Raw{
+		Table: mapping.TableName, Keyspace: mapping.Keyspace, Document: document, Operation: Upsert, Timestamp: timestamp,
+	}
+	switch mapping.WriteMode {
+	case config.WriteModeInsertIfNotExists:
+		raw.Operation = Insert
+	case config.WriteModeUpdateExisting:
+		raw.Operation, raw.IfExists = Update, mapping.IfExists
+		raw.Filter = make(map[string]interface{}, len(mapping.PrimaryKeyFields))
+		for _, column := range mapping.PrimaryKeyFields {
+			raw.Filter[column] = nil
+			delete(raw.Document, column)
+		}
+	}
+	return raw
+}
+
+// warmUpStatements builds and prepares every statement implied by mappings,
+// so a statement the schema rejects fails at startup and the first flush
+// does not prepare them inline.
+func (b *Bulk) warmUpStatements(mappings []config.CollectionTableMapping) error {
+	statements := mappingStatements(mappings, b.writeTimestamp != "" && b.writeTimestamp != writeTimestampNone)
+	preparer, ok := b.currentSession().(Preparer)
+
+	var errs []error
+	for _, raw := range statements {
+		query, _ := b.buildQueryAndValues(raw)
+		if !ok {
+			continue
+		}
+		if err := preparer.Prepare(query); err != nil {
+			errs = append(errs, fmt.Errorf("preparing %q: %w", query, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("statement warm-up failed: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// withPreparedSession runs fn like withSession. If fn fails because a
+// statement on one of tables (qualified as keyspace.table) is stale, the
+// statements of those tables are invalidated and re-prepared, and fn is
+// retried once.
+func (b *Bulk) withPreparedSession(tables []string, fn func(Session) error) error {
+	err := b.withSession(fn)
+	if !isStatementStale(err) {
+		return err
+	}
+	log.Printf("Stale prepared statement on %v, re-preparing: %v", tables, err)
+	b.invalidateStatements(tables)
+	return b.withSession(fn)
+}
+
+// invalidateStatements moves the cached statements of tables to a new
+// generation and prepares them on every node, counting each re-prepare.
+// Statements that fail to prepare are left to be prepared lazily by the
+// retried write, which reports the error.
+func (b *Bulk) invalidateStatements(tables []string) {
+	b.preparedStmtsMutex.Lock()
+	if b.statementGenerations == nil {
+		b.statementGenerations = make(map[string]int, len(tables))
+	}
+	for _, table := range tables {
+		b.statementGenerations[table]++
+	}
+	var queries []string
+	for cacheKey, query := range b.preparedStmts {
+		table := cacheKeyTable(cacheKey)
+		if !slices.Contains(tables, table) {
+			continue
+		}
+		query = withGeneration(query, b.statementGenerations[table])
+		b.preparedStmts[cacheKey] = query
+		queries = append(queries, query)
+	}
+	b.preparedStmtsMutex.Unlock()
+
+	preparer, ok := b.currentSession().(Preparer)
+	for _, query := range queries {
+		if b.metric != nil {
+			atomic.AddInt64(&b.metric.StatementReprepares, 1)
+		}
+		if !ok {
+			continue
+		}
+		if err := preparer.Prepare(query); err != nil {
+			log.Printf("Re-preparing %q failed: %v", query, err)
+		}
+	}
+}
+
+// cacheKeyTable extracts the qualified table from a preparedStmts key, which
+// has the form "OPERATION:keyspace.table:...".
+func cacheKeyTable(cacheKey string) string {
+	parts := strings.SplitN(cacheKey, ":", 3)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
@@ -0,0 +1,277 @@
+package cassandra
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	gocql "github.com/apache/cassandra-gocql-driver/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Trendyol/go-dcp-cassandra/config"
+)
+
+// mockPrepareSession records prepared and executed statements. Like gocql,
+// it keys prepared statements by their text: executing a statement in stale
+// keeps failing however often that same text is prepared again.
+type mockPrepareSession struct {
+	mockSession
+	prepareErr error
+	stale      map[string]error
+	prepared   []string
+	executed   []string
+}
+
+func (m *mockPrepareSession) Prepare(stmt string) error {
+	m.prepared = append(m.prepared, stmt)
+	return m.prepareErr
+}
+
+func (m *mockPrepareSession) PreparedQuery(stmt string, _ ...interface{}) Query {
+	return &mockPrepareQuery{session: m, stmt: stmt}
+}
+
+type mockPrepareQuery struct {
+	session *mockPrepareSession
+	stmt    string
+}
+
+func (m *mockPrepareQuery) WithTimeout(time.Duration)         {}
+func (m *mockPrepareQuery) ExecContext(context.Context) error { return m.Exec() }
+func (m *mockPrepareQuery) WithConsistency(Consistency)       {}
+
+func (m *mockPrepareQuery) Exec() error {
+	m.session.executed = append(m.session.executed, m.stmt)
+	return m.session.stale[m.stmt]
+}
+
+func newPrepareBulk(session Session, writeTimestamp string) *Bulk {
+	return &Bulk{
+		session:        session,
+		keyspace:       "ks",
+		metric:         &Metric{},
+		preparedStmts:  make(map[string]string),
+		writeTimestamp: writeTimestamp,
+	}
+}
+
+func TestWarmUpStatements_PreparesMappingShapes(t *testing.T) {
+	session := &mockPrepareSession{}
+	b := newPrepareBulk(session, writeTimestampNone)
+	mappings := []config.CollectionTableMapping{
+		{
+			TableName:        "orders",
+			FieldMappings:    map[string]string{"id": "_key", "status": "status", "raw": "documentData"},
+			PrimaryKeyFields: []string{"id"},
+		},
+		{
+			TableName:     "events",
+			FieldMappings: map[string]string{"id": "_key", "payload": "documentData"},
+		},
+	}
+
+	require.NoError(t, b.warmUpStatements(mappings))
+
+	sort.Strings(session.prepared)
+	assert.Equal(t, []string{
+		"DELETE FROM ks.events WHERE id = ?",
+		"DELETE FROM ks.orders WHERE id = ?",
+		"INSERT INTO ks.events (id,payload) VALUES (?,?)",
+		"INSERT INTO ks.orders (id,raw,status) VALUES (?,?,?)",
+	}, session.prepared)
+	assert.Len(t, b.preparedStmts, 4)
+}
+
+func TestWarmUpStatements_WithWriteTimestamp(t *testing.T) {
+	session := &mockPrepareSession{}
+	b := newPrepareBulk(session, writeTimestampEventTime)
+	mappings := []config.CollectionTableMapping{
+		{TableName: "orders", FieldMappings: map[string]string{"id": "_key"}},
+	}
+
+	require.NoError(t, b.warmUpStatements(mappings))
+
+	sort.Strings(session.prepared)
+	assert.Equal(t, []string{
+		"DELETE FROM ks.orders USING TIMESTAMP ? WHERE id = ?",
+		"INSERT INTO ks.orders (id) VALUES (?) USING TIMESTAMP ?",
+	}, session.prepared)
+}
+
+func TestWarmUpStatements_ReportsPrepareErrors(t *testing.T) {
+	session := &mockPrepareSession{prepareErr: errors.New("unconfigured table orders")}
+	b := newPrepareBulk(session, writeTimestampNone)
+	mappings := []config.CollectionTableMapping{
+		{TableName: "orders", FieldMappings: map[string]string{"id": "_key"}},
+	}
+
+	err := b.warmUpStatements(mappings)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unconfigured table orders")
+}
+
+func TestWarmUpStatements_SkipsPrepareWithoutPreparer(t *testing.T) {
+	b := newPrepareBulk(&mockSession{}, writeTimestampNone)
+	mappings := []config.CollectionTableMapping{
+		{TableName: "orders", FieldMappings: map[string]string{"id": "_key"}},
+	}
+
+	require.NoError(t, b.warmUpStatements(mappings))
+	assert.Len(t, b.preparedStmts, 2, "statements are still built and cached")
+}
+
+func TestBulk_ReprepareOnStaleStatement(t *testing.T) {
+	const stmt = "INSERT INTO ks.orders (id) VALUES (?)"
+	session := &mockPrepareSession{stale: map[string]error{stmt: errors.New("gocql: expected 2 values send got 1")}}
+	b := newPrepareBulk(session, writeTimestampNone)
+	raw := &Raw{Table: "orders", Document: map[string]interface{}{"id": "1"}, Operation: Upsert}
+
+	require.NoError(t, b.insert(context.Background(), raw))
+
+	reprepared := stmt + " /* generation 1 */"
+	assert.Equal(t, []string{stmt, reprepared}, session.executed, "write retried with the re-prepared statement")
+	assert.Equal(t, []string{reprepared}, session.prepared)
+	assert.Equal(t, int64(1), b.GetMetric().StatementReprepares)
+
+	require.NoError(t, b.insert(context.Background(), raw))
+	assert.Equal(t, reprepared, session.executed[2], "later writes keep the new generation")
+}
+
+func TestBulk_ReprepareRetriesOnlyOnce(t *testing.T) {
+	unprepared := &gocql.RequestErrUnprepared{}
+	session := &mockPrepareSession{stale: map[string]error{
+		"INSERT INTO ks.orders (id) VALUES (?)":                    unprepared,
+		"INSERT INTO ks.orders (id) VALUES (?) /* generation 1 */": unprepared,
+	}}
+	b := newPrepareBulk(session, writeTimestampNone)
+	raw := &Raw{Table: "orders", Document: map[string]interface{}{"id": "1"}, Operation: Upsert}
+
+	err := b.insert(context.Background(), raw)
+	require.Error(t, err)
+	assert.Len(t, session.executed, 2)
+}
+
+func TestInvalidateStatements_OnlyAffectedTables(t *testing.T) {
+	session := &mockPrepareSession{}
+	b := newPrepareBulk(session, writeTimestampNone)
+	b.buildQueryAndValues(&Raw{Table: "orders", Document: map[string]interface{}{"id": 1}, Operation: Upsert})
+	b.buildQueryAndValues(&Raw{Table: "orders", Filter: map[string]interface{}{"id": 1}, Operation: Delete})
+	b.buildQueryAndValues(&Raw{Table: "events", Document: map[string]interface{}{"id": 1}, Operation: Upsert})
+
+	b.invalidateStatements([]string{"ks.orders"})
+	b.invalidateStatements([]string{"ks.orders"})
+
+	sort.Strings(session.prepared)
+	assert.Equal(t, []string{
+		"DELETE FROM ks.orders WHERE id = ? /* generation 1 */",
+		"DELETE FROM ks.orders WHERE id = ? /* generation 2 */",
+		"INSERT INTO ks.orders (id) VALUES (?) /* generation 1 */",
+		"INSERT INTO ks.orders (id) VALUES (?) /* generation 2 */",
+	}, session.prepared)
+	assert.Equal(t, int64(4), b.GetMetric().StatementReprepares)
+	query, _ := b.buildQueryAndValues(&Raw{Table: "events", Document: map[string]interface{}{"id": 1}, Operation: Upsert})
+	assert.Equal(t, "INSERT INTO ks.events (id) VALUES (?)", query)
+	query, _ = b.buildQueryAndValues(&Raw{Table: "orders", Filter: map[string]interface{}{"id": 1}, Operation: Delete})
+	assert.Equal(t, "DELETE FROM ks.orders WHERE id = ? /* generation 2 */", query)
+}
+
+func TestIsStatementStale(t *testing.T) {
+	assert.False(t, isStatementStale(nil))
+	assert.True(t, isStatementStale(&gocql.RequestErrUnprepared{}))
+	assert.True(t, isStatementStale(fmt.Errorf("write: %w", &gocql.RequestErrUnprepared{})))
+	assert.True(t, isStatementStale(errors.New("gocql: expected 3 values send got 2")))
+	assert.False(t, isStatementStale(errors.New("timeout")))
+}
+
+func TestMappingStatements_Keyspaces(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{TableName: "orders", Keyspace: "archive", FieldMappings: map[string]string{"id": "_key"}},
+		{TableName: "tenants", Keyspace: "tenant_{_keyPrefix}", FieldMappings: map[string]string{"id": "_key"}},
+	}
+
+	statements := mappingStatements(mappings, false)
+
+	require.Len(t, statements, 2, "templated keyspaces are skipped")
+	for _, raw := range statements {
+		assert.Equal(t, "archive", raw.Keyspace)
+	}
+}
+
+func TestMappingStatements_MetadataNotInDeleteFilter(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{TableName: "orders", FieldMappings: map[string]string{"id": "_key", "cas": "_cas", "raw": "documentData"}},
+	}
+
+	statements := mappingStatements(mappings, false)
+
+	require.Len(t, statements, 2)
+	assert.Len(t, statements[0].Document, 3)
+	assert.Equal(t, map[string]interface{}{"id": nil}, statements[1].Filter)
+}
+
+func TestMappingStatements_Explode(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{
+			TableName:        "order_items",
+			Explode:          "items",
+			PrimaryKeyFields: []string{"order_id", "item_index"},
+			FieldMappings:    map[string]string{"order_id": "_key", "item_index": "_index", "sku": "_element.sku"},
+		},
+	}
+
+	statements := mappingStatements(mappings, false)
+
+	require.Len(t, statements, 3)
+	assert.Len(t, statements[0].Document, 3)
+	assert.Equal(t, map[string]interface{}{"order_id": nil}, statements[1].Filter)
+	assert.Nil(t, statements[1].FilterFrom)
+	assert.Equal(t, map[string]interface{}{"order_id": nil}, statements[2].Filter)
+	assert.Equal(t, map[string]interface{}{"item_index": nil}, statements[2].FilterFrom)
+}
+
+func TestMappingStatements_DeleteMode(t *testing.T) {
+	mapping := config.CollectionTableMapping{
+		TableName:        "orders",
+		PrimaryKeyFields: []string{"id"},
+		FieldMappings:    map[string]string{"id": "_key", "status": "status"},
+		DeleteMode:       config.DeleteMode{Deletion: config.DeleteModeHard, Expiration: config.DeleteModeSoft},
+		SoftDelete:       config.SoftDelete{DeletedAtColumn: "deleted_at", Columns: map[string]interface{}{"status": "EXPIRED"}},
+	}
+
+	statements := mappingStatements([]config.CollectionTableMapping{mapping}, false)
+
+	require.Len(t, statements, 3)
+	assert.Equal(t, Delete, statements[1].Operation)
+	assert.Equal(t, Update, statements[2].Operation)
+	assert.Equal(t, map[string]interface{}{"deleted_at": nil, "status": nil}, statements[2].Document)
+	assert.Equal(t, map[string]interface{}{"id": nil}, statements[2].Filter)
+
+	mapping.DeleteMode = config.DeleteMode{Deletion: config.DeleteModeIgnore, Expiration: config.DeleteModeIgnore}
+	statements = mappingStatements([]config.CollectionTableMapping{mapping}, false)
+	require.Len(t, statements, 1, "ignored deletes prepare no statement")
+	assert.Equal(t, Upsert, statements[0].Operation)
+}
+
+func TestMappingStatements_WriteMode(t *testing.T) {
+	mapping := config.CollectionTableMapping{
+		TableName:        "orders",
+		PrimaryKeyFields: []string{"id"},
+		FieldMappings:    map[string]string{"id": "_key", "status": "status"},
+		WriteMode:        config.WriteModeInsertIfNotExists,
+	}
+	statements := mappingStatements([]config.CollectionTableMapping{mapping}, true)
+	assert.Equal(t, Insert, statements[0].Operation)
+
+	mapping.WriteMode, mapping.IfExists = config.WriteModeUpdateExisting, true
+	statements = mappingStatements([]config.CollectionTableMapping{mapping}, true)
+	assert.Equal(t, &Raw{
+		Table: "orders", Document: map[string]interface{}{"status": nil}, Filter: map[string]interface{}{"id": nil},
+		Operation: Update, IfExists: true, Timestamp: 1,
+	}, statements[0])
+	query, _ := newBulk(&mockSession{}).buildQueryAndValues(statements[0])
+	assert.Equal(t, "UPDATE ks.orders SET status = ? WHERE id = ? IF EXISTS", query)
+}
@@ -0,0 +1,360 @@
+package cassandra
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Trendyol/go-dcp-cassandra/config"
+)
+
+const (
+	ColumnKindPartitionKey = "partition_key"
+	ColumnKindClustering   = "clustering"
+	ColumnKindRegular      = "regular"
+	ColumnKindStatic       = "static"
+)
+
+// Column is a row of system_schema.columns.
+type Column struct {
+	Name     string
+	Kind     string
+	Type     string
+	Position int
+}
+
+// TableSchema holds the columns of a table keyed by column name.
+type TableSchema struct {
+	Columns  map[string]Column
+	Keyspace string
+	Name     string
+}
+
+// PrimaryKey returns the partition key columns followed by the clustering
+// columns, each in declaration order.
+func (t *TableSchema) PrimaryKey() []string {
+	var partition, clustering []Column
+	for _, column := range t.Columns {
+		switch column.Kind {
+		case ColumnKindPartitionKey:
+			partition = append(partition, column)
+		case ColumnKindClustering:
+			clustering = append(clustering, column)
+		}
+	}
+	byPosition := func(columns []Column) func(i, j int) bool {
+		return func(i, j int) bool { return columns[i].Position < columns[j].Position }
+	}
+	sort.Slice(partition, byPosition(partition))
+	sort.Slice(clustering, byPosition(clustering))
+
+	keys := make([]string, 0, len(partition)+len(clustering))
+	for _, column := range append(partition, clustering...) {
+		keys = append(keys, column.Name)
+	}
+	return keys
+}
+
+// SchemaReader is implemented by sessions that can read table metadata.
+// Sessions that do not implement it skip schema validation.
+type SchemaReader interface {
+	TableSchema(keyspace, table string) (*TableSchema, error)
+}
+
+// UserTypeReader is implemented by sessions that can read the user-defined
+// types of a keyspace. Sessions that do not implement it leave user types
+// to be declared in config.
+type UserTypeReader interface {
+	// UserTypes returns the fields of each user-defined type of keyspace,
+	// type name to field name to field type.
+	UserTypes(keyspace string) (map[string]map[string]string, error)
+}
+
+func (s *GocqlSessionAdapter) TableSchema(keyspace, table string) (*TableSchema, error) {
+	iter := s.Session.Query(
+		"SELECT column_name, kind, position, type FROM system_schema.columns WHERE keyspace_name = ? AND table_name = ?",
+		keyspace, table,
+	).Iter()
+
+	schema := &TableSchema{Keyspace: keyspace, Name: table, Columns: make(map[string]Column)}
+	var column Column
+	for iter.Scan(&column.Name, &column.Kind, &column.Position, &column.Type) {
+		schema.Columns[column.Name] = column
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+func (s *GocqlSessionAdapter) UserTypes(keyspace string) (map[string]map[string]string, error) {
+	iter := s.Session.Query(
+		"SELECT type_name, field_names, field_types FROM system_schema.types WHERE keyspace_name = ?",
+		keyspace,
+	).Iter()
+
+	types := make(map[string]map[string]string)
+	var (
+		name               string
+		fieldNames, fields []string
+	)
+	for iter.Scan(&name, &fieldNames, &fields) {
+		types[name] = make(map[string]string, len(fieldNames))
+		for i := range fieldNames {
+			if i < len(fields) {
+				types[name][fieldNames[i]] = fields[i]
+			}
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return types, nil
+}
+
+// SchemaTypes are the types of one mapping read from system_schema: the
+// column types and the user-defined types it does not declare itself.
+type SchemaTypes struct {
+	ColumnTypes map[string]string
+	UserTypes   map[string]map[string]string
+}
+
+// ValidateSchema checks every mapping against the live schema of its keyspace
+// (the mapping's own, or keyspace when it has none):
+// the table must exist, every fieldMappings column must exist, and
+// primaryKeyFields, when set, must be exactly the table's partition and
+// clustering columns. All problems are reported together. Mappings with a
+// templated keyspace or table cannot be checked ahead of time and are
+// skipped.
+//
+// It returns the SchemaTypes of each mapping, in order, so the default
+// mapper can coerce values to the column types not declared in columnTypes
+// and to the user-defined types they refer to. Mappings are not modified.
+// Dead-letter tables must have every config.DeadLetterColumns column.
+func ValidateSchema(session Session, keyspace string, mappings []config.CollectionTableMapping) ([]SchemaTypes, error) {
+	return validateSchema(session, keyspace, mappings, false)
+}
+
+// validateSchema is ValidateSchema. With ownKeyspace, static keyspace
+// overrides of mappings and dead-letter tables are ignored and every table
+// is looked up in keyspace, as the dual-write secondary writes them.
+func validateSchema(
+	session Session, keyspace string, mappings []config.CollectionTableMapping, ownKeyspace bool,
+) ([]SchemaTypes, error) {
+	reader, ok := session.(SchemaReader)
+	if !ok {
+		return nil, nil
+	}
+
+	var errs []error
+	deadLetterTables := make(map[string]bool)
+	userTypes := make(map[string]map[string]map[string]string)
+	types := make([]SchemaTypes, len(mappings))
+	for i, mapping := range mappings {
+		deadLetterErrs, err := validateDeadLetterTable(reader, keyspace, mapping, deadLetterTables, ownKeyspace)
+		if err != nil {
+			return nil, err
+		}
+		errs = append(errs, deadLetterErrs...)
+
+		tableKeyspace, static := mapping.StaticKeyspace(keyspace)
+		if !static || !mapping.StaticTable() {
+			continue
+		}
+		if ownKeyspace {
+			tableKeyspace = keyspace
+		}
+		schema, err := reader.TableSchema(strings.ToLower(tableKeyspace), strings.ToLower(mapping.TableName))
+		if err != nil {
+			return nil, fmt.Errorf("reading schema of %s.%s: %w", tableKeyspace, mapping.TableName, err)
+		}
+		errs = append(errs, validateMapping(schema, mapping)...)
+		types[i].ColumnTypes = schemaColumnTypes(schema, mapping)
+		types[i].UserTypes, err = schemaUserTypes(session, strings.ToLower(tableKeyspace), mapping, types[i].ColumnTypes, userTypes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("schema validation failed: %w", errors.Join(errs...))
+	}
+	return types, nil
+}
+
+func validateMapping(schema *TableSchema, mapping config.CollectionTableMapping) []error {
+	qualified := schema.Keyspace + "." + schema.Name
+	if len(schema.Columns) == 0 {
+		return []error{fmt.Errorf("table %s does not exist", qualified)}
+	}
+
+	var errs []error
+	for _, column := range sortedMappingColumns(mapping.FieldMappings) {
+		if _, ok := schema.Columns[strings.ToLower(column)]; !ok {
+			errs = append(errs, fmt.Errorf("fieldMappings column %q does not exist in table %s", column, qualified))
+		}
+	}
+	for _, column := range mapping.SoftDeleteColumns() {
+		if _, ok := schema.Columns[strings.ToLower(column)]; !ok {
+			errs = append(errs, fmt.Errorf("softDelete column %q does not exist in table %s", column, qualified))
+		}
+	}
+
+	if len(mapping.PrimaryKeyFields) > 0 {
+		actual := schema.PrimaryKey()
+		configured := make([]string, len(mapping.PrimaryKeyFields))
+		for i, field := range mapping.PrimaryKeyFields {
+			configured[i] = strings.ToLower(field)
+		}
+		if !sameSet(actual, configured) {
+			errs = append(errs, fmt.Errorf(
+				"primaryKeyFields %v do not match primary key %v of table %s",
+				mapping.PrimaryKeyFields, actual, qualified,
+			))
+		}
+	}
+	return errs
+}
+
+// validateDeadLetterTable checks the deadLetterTable of mapping, if it has
+// one that is not in checked yet, and adds it to checked. With ownKeyspace
+// its keyspace is always keyspace.
+func validateDeadLetterTable(
+	reader SchemaReader, keyspace string, mapping config.CollectionTableMapping, checked map[string]bool, ownKeyspace bool,
+) ([]error, error) {
+	if mapping.InvalidDocumentPolicy != config.InvalidDocumentDeadLetter {
+		return nil, nil
+	}
+	tableKeyspace, table := mapping.DeadLetterTarget()
+	if tableKeyspace == "" || ownKeyspace {
+		tableKeyspace = keyspace
+	}
+	qualified := strings.ToLower(tableKeyspace + "." + table)
+	if checked[qualified] {
+		return nil, nil
+	}
+	checked[qualified] = true
+
+	schema, err := reader.TableSchema(strings.ToLower(tableKeyspace), strings.ToLower(table))
+	if err != nil {
+		return nil, fmt.Errorf("reading schema of %s.%s: %w", tableKeyspace, table, err)
+	}
+	if len(schema.Columns) == 0 {
+		return []error{fmt.Errorf("deadLetterTable %s does not exist", qualified)}, nil
+	}
+	var errs []error
+	for _, column := range config.DeadLetterColumns {
+		if _, ok := schema.Columns[column]; !ok {
+			errs = append(errs, fmt.Errorf("dead-letter column %q does not exist in table %s", column, qualified))
+		}
+	}
+	return errs, nil
+}
+
+// schemaColumnTypes returns the schema types of the mapped and soft delete
+// columns of mapping that it does not declare.
+func schemaColumnTypes(schema *TableSchema, mapping config.CollectionTableMapping) map[string]string {
+	var types map[string]string
+	columns := append(sortedMappingColumns(mapping.FieldMappings), mapping.SoftDeleteColumns()...)
+	for _, column := range columns {
+		if _, declared := mapping.ColumnTypes[column]; declared {
+			continue
+		}
+		schemaColumn, ok := schema.Columns[strings.ToLower(column)]
+		if !ok {
+			continue
+		}
+		if types == nil {
+			types = make(map[string]string)
+		}
+		types[column] = schemaColumn.Type
+	}
+	return types
+}
+
+// schemaUserTypes returns the user-defined types the declared column types
+// of mapping and columnTypes refer to, directly or through other user
+// types, and that mapping does not declare. The types of each keyspace are
+// read once, into cache, and only when needed.
+func schemaUserTypes(
+	session Session,
+	keyspace string,
+	mapping config.CollectionTableMapping,
+	columnTypes map[string]string,
+	cache map[string]map[string]map[string]string,
+) (map[string]map[string]string, error) {
+	reader, ok := session.(UserTypeReader)
+	if !ok {
+		return nil, nil
+	}
+	var pending []string
+	for _, declared := range []map[string]string{mapping.ColumnTypes, columnTypes} {
+		for _, columnType := range declared {
+			pending = append(pending, userTypeNames(columnType)...)
+		}
+	}
+	var found map[string]map[string]string
+	for len(pending) > 0 {
+		name := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if _, declared := mapping.UserTypes[name]; declared {
+			continue
+		}
+		if _, seen := found[name]; seen {
+			continue
+		}
+		types, read := cache[keyspace]
+		if !read {
+			var err error
+			if types, err = reader.UserTypes(keyspace); err != nil {
+				return nil, fmt.Errorf("reading user types of %s: %w", keyspace, err)
+			}
+			cache[keyspace] = types
+		}
+		fields, ok := types[name]
+		if !ok {
+			continue
+		}
+		if found == nil {
+			found = make(map[string]map[string]string)
+		}
+		found[name] = fields
+		for _, fieldType := range fields {
+			pending = append(pending, userTypeNames(fieldType)...)
+		}
+	}
+	return found, nil
+}
+
+func userTypeNames(columnType string) []string {
+	t, err := config.ParseCQLType(columnType)
+	if err != nil {
+		return nil
+	}
+	return t.UserTypeNames()
+}
+
+func sortedMappingColumns(fieldMappings map[string]string) []string {
+	columns := make([]string, 0, len(fieldMappings))
+	for column := range fieldMappings {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[string]struct{}, len(a))
+	for _, v := range a {
+		set[v] = struct{}{}
+	}
+	for _, v := range b {
+		if _, ok := set[v]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,254 @@
+package cassandra
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Trendyol/go-dcp-cassandra/config"
+)
+
+// mockSchemaSession serves table schemas from memory.
+type mockSchemaSession struct {
+	mockSession
+	tables map[string]*TableSchema
+	err    error
+}
+
+func (m *mockSchemaSession) TableSchema(keyspace, table string) (*TableSchema, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if schema, ok := m.tables[keyspace+"."+table]; ok {
+		return schema, nil
+	}
+	return &TableSchema{Keyspace: keyspace, Name: table, Columns: map[string]Column{}}, nil
+}
+
+func ordersSchema() *TableSchema {
+	return &TableSchema{
+		Keyspace: "ks",
+		Name:     "orders",
+		Columns: map[string]Column{
+			"tenant":     {Name: "tenant", Kind: ColumnKindPartitionKey, Type: "text"},
+			"id":         {Name: "id", Kind: ColumnKindClustering, Type: "text"},
+			"status":     {Name: "status", Kind: ColumnKindRegular, Type: "text"},
+			"created_at": {Name: "created_at", Kind: ColumnKindRegular, Type: "timestamp"},
+		},
+	}
+}
+
+func TestTableSchema_PrimaryKey(t *testing.T) {
+	schema := &TableSchema{Columns: map[string]Column{
+		"b":  {Name: "b", Kind: ColumnKindPartitionKey, Position: 1},
+		"a":  {Name: "a", Kind: ColumnKindPartitionKey, Position: 0},
+		"c2": {Name: "c2", Kind: ColumnKindClustering, Position: 1},
+		"c1": {Name: "c1", Kind: ColumnKindClustering, Position: 0},
+		"v":  {Name: "v", Kind: ColumnKindRegular, Position: -1},
+	}}
+	assert.Equal(t, []string{"a", "b", "c1", "c2"}, schema.PrimaryKey())
+}
+
+func TestValidateSchema_Valid(t *testing.T) {
+	session := &mockSchemaSession{tables: map[string]*TableSchema{"ks.orders": ordersSchema()}}
+	mappings := []config.CollectionTableMapping{
+		{
+			TableName:        "Orders",
+			PrimaryKeyFields: []string{"id", "tenant"},
+			FieldMappings:    map[string]string{"tenant": "tenant", "id": "_key", "status": "status"},
+		},
+	}
+	_, err := ValidateSchema(session, "ks", mappings)
+	assert.NoError(t, err)
+}
+
+func TestValidateSchema_OwnKeyspaceIgnoresStaticOverrides(t *testing.T) {
+	session := &mockSchemaSession{tables: map[string]*TableSchema{"ks2.orders": ordersSchema()}}
+	mappings := []config.CollectionTableMapping{
+		{Keyspace: "analytics", TableName: "orders", FieldMappings: map[string]string{"id": "_key", "status": "status"}},
+	}
+	_, err := validateSchema(session, "ks2", mappings, true)
+	assert.NoError(t, err)
+	_, err = ValidateSchema(session, "ks2", mappings)
+	assert.Error(t, err, "the primary looks the table up in the override")
+}
+
+func TestValidateSchema_UnknownColumn(t *testing.T) {
+	session := &mockSchemaSession{tables: map[string]*TableSchema{"ks.orders": ordersSchema()}}
+	mappings := []config.CollectionTableMapping{
+		{TableName: "orders", FieldMappings: map[string]string{"id": "_key", "stauts": "status"}},
+	}
+	_, err := ValidateSchema(session, "ks", mappings)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"stauts"`)
+	assert.Contains(t, err.Error(), "ks.orders")
+}
+
+func TestValidateSchema_PrimaryKeyMismatch(t *testing.T) {
+	session := &mockSchemaSession{tables: map[string]*TableSchema{"ks.orders": ordersSchema()}}
+	mappings := []config.CollectionTableMapping{
+		{
+			TableName:        "orders",
+			PrimaryKeyFields: []string{"id"},
+			FieldMappings:    map[string]string{"id": "_key", "tenant": "tenant"},
+		},
+	}
+	_, err := ValidateSchema(session, "ks", mappings)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "primaryKeyFields")
+	assert.Contains(t, err.Error(), "[tenant id]")
+}
+
+func TestValidateSchema_MissingTable(t *testing.T) {
+	session := &mockSchemaSession{}
+	mappings := []config.CollectionTableMapping{
+		{TableName: "nope", FieldMappings: map[string]string{"id": "_key"}},
+	}
+	_, err := ValidateSchema(session, "ks", mappings)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ks.nope does not exist")
+}
+
+func TestValidateSchema_ReadError(t *testing.T) {
+	session := &mockSchemaSession{err: errors.New("unauthorized")}
+	mappings := []config.CollectionTableMapping{{TableName: "orders"}}
+	_, err := ValidateSchema(session, "ks", mappings)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unauthorized")
+}
+
+func TestValidateSchema_SkippedWithoutSchemaReader(t *testing.T) {
+	mappings := []config.CollectionTableMapping{{TableName: "nope", FieldMappings: map[string]string{"id": "_key"}}}
+	types, err := ValidateSchema(&mockSession{}, "ks", mappings)
+	assert.NoError(t, err)
+	assert.Nil(t, types)
+}
+
+func TestValidateSchema_FillsColumnTypes(t *testing.T) {
+	session := &mockSchemaSession{tables: map[string]*TableSchema{"ks.orders": ordersSchema()}}
+	mappings := []config.CollectionTableMapping{
+		{
+			TableName:     "orders",
+			FieldMappings: map[string]string{"id": "_key", "created_at": "createdAt", "status": "status"},
+			ColumnTypes:   map[string]string{"status": "ascii"},
+		},
+	}
+	types, err := ValidateSchema(session, "ks", mappings)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"id": "text", "created_at": "timestamp"}, types[0].ColumnTypes,
+		"declared types are not read from the schema")
+	assert.Equal(t, map[string]string{"status": "ascii"}, mappings[0].ColumnTypes, "mappings are not modified")
+}
+
+// mockUserTypeSession also serves user types, counting the reads.
+type mockUserTypeSession struct {
+	mockSchemaSession
+	types map[string]map[string]map[string]string
+	reads int
+}
+
+func (m *mockUserTypeSession) UserTypes(keyspace string) (map[string]map[string]string, error) {
+	m.reads++
+	return m.types[keyspace], nil
+}
+
+func TestValidateSchema_FillsUserTypes(t *testing.T) {
+	schema := ordersSchema()
+	schema.Columns["address"] = Column{Name: "address", Kind: ColumnKindRegular, Type: "frozen<address>"}
+	schema.Columns["stops"] = Column{Name: "stops", Kind: ColumnKindRegular, Type: "list<frozen<tuple<int, point>>>"}
+	session := &mockUserTypeSession{
+		mockSchemaSession: mockSchemaSession{tables: map[string]*TableSchema{"ks.orders": schema}},
+		types: map[string]map[string]map[string]string{"ks": {
+			"address": {"street": "text", "geo": "frozen<point>"},
+			"point":   {"lat": "double", "lon": "double"},
+			"unused":  {"x": "int"},
+		}},
+	}
+	mappings := []config.CollectionTableMapping{
+		{
+			TableName:     "orders",
+			FieldMappings: map[string]string{"id": "_key", "address": "address", "stops": "stops"},
+			UserTypes:     map[string]map[string]string{"point": {"lat": "float"}},
+		},
+		{TableName: "orders", Collection: "other", FieldMappings: map[string]string{"id": "_key", "address": "address"}},
+		{TableName: "orders", Collection: "plain", FieldMappings: map[string]string{"id": "_key"}},
+	}
+	types, err := ValidateSchema(session, "ks", mappings)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]map[string]string{
+		"address": {"street": "text", "geo": "frozen<point>"},
+	}, types[0].UserTypes, "declared types are not read from the schema")
+	assert.Equal(t, map[string]map[string]string{"point": {"lat": "float"}}, mappings[0].UserTypes, "mappings are not modified")
+	assert.Equal(t, map[string]map[string]string{
+		"address": {"street": "text", "geo": "frozen<point>"},
+		"point":   {"lat": "double", "lon": "double"},
+	}, types[1].UserTypes)
+	assert.Nil(t, types[2].UserTypes)
+	assert.Nil(t, mappings[1].UserTypes)
+	assert.Equal(t, 1, session.reads, "types are read once per keyspace")
+}
+
+func TestValidateSchema_MappingKeyspace(t *testing.T) {
+	session := &mockSchemaSession{tables: map[string]*TableSchema{"archive.orders": ordersSchema()}}
+	mappings := []config.CollectionTableMapping{
+		{TableName: "orders", Keyspace: "archive", FieldMappings: map[string]string{"id": "_key"}},
+		{TableName: "orders", Keyspace: "tenant_{_keyPrefix}", FieldMappings: map[string]string{"id": "_key"}},
+	}
+	_, err := ValidateSchema(session, "ks", mappings)
+	assert.NoError(t, err)
+}
+
+func TestValidateSchema_SoftDeleteColumns(t *testing.T) {
+	session := &mockSchemaSession{tables: map[string]*TableSchema{"ks.orders": ordersSchema()}}
+	mappings := []config.CollectionTableMapping{
+		{
+			TableName:        "orders",
+			PrimaryKeyFields: []string{"id", "tenant"},
+			FieldMappings:    map[string]string{"tenant": "tenant", "id": "_key"},
+			DeleteMode:       config.DeleteMode{Expiration: config.DeleteModeSoft},
+			SoftDelete:       config.SoftDelete{DeletedAtColumn: "created_at", Columns: map[string]interface{}{"status": "EXPIRED"}},
+		},
+	}
+	types, err := ValidateSchema(session, "ks", mappings)
+	require.NoError(t, err)
+	assert.Equal(t, "timestamp", types[0].ColumnTypes["created_at"], "soft delete column types are read too")
+
+	mappings[0].SoftDelete.DeletedAtColumn = "deleted_at"
+	_, err = ValidateSchema(session, "ks", mappings)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `softDelete column "deleted_at" does not exist in table ks.orders`)
+}
+
+func TestValidateSchema_DeadLetterTable(t *testing.T) {
+	deadLetter := &TableSchema{Keyspace: "ops", Name: "invalid_documents", Columns: map[string]Column{}}
+	for _, column := range config.DeadLetterColumns {
+		deadLetter.Columns[column] = Column{Name: column, Kind: ColumnKindRegular}
+	}
+	session := &mockSchemaSession{tables: map[string]*TableSchema{
+		"ks.orders":             ordersSchema(),
+		"ops.invalid_documents": deadLetter,
+	}}
+	mappings := []config.CollectionTableMapping{
+		{
+			TableName:             "orders",
+			FieldMappings:         map[string]string{"id": "_key"},
+			InvalidDocumentPolicy: config.InvalidDocumentDeadLetter,
+			DeadLetterTable:       "ops.invalid_documents",
+		},
+	}
+	_, err := ValidateSchema(session, "ks", mappings)
+	require.NoError(t, err)
+
+	delete(deadLetter.Columns, config.DeadLetterErrorColumn)
+	_, err = ValidateSchema(session, "ks", mappings)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `dead-letter column "error" does not exist in table ops.invalid_documents`)
+
+	mappings[0].DeadLetterTable = "invalid_documents"
+	_, err = ValidateSchema(session, "ks", mappings)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "deadLetterTable ks.invalid_documents does not exist")
+}
@@ -1,6 +1,11 @@
 package cassandra
 
 import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
 	gocql "github.com/apache/cassandra-gocql-driver/v2"
 )
 
@@ -12,6 +17,72 @@ const (
 	CounterBatch
 )
 
+// Consistency is a per-statement consistency override. Levels are declared
+// from weakest to strongest; DefaultConsistency keeps the session's level.
+type Consistency int
+
+const (
+	DefaultConsistency Consistency = iota
+	ConsistencyAny
+	ConsistencyOne
+	ConsistencyLocalOne
+	ConsistencyTwo
+	ConsistencyThree
+	ConsistencyLocalQuorum
+	ConsistencyQuorum
+	ConsistencyEachQuorum
+	ConsistencyAll
+)
+
+var consistencyByName = map[string]Consistency{
+	"ANY":          ConsistencyAny,
+	"ONE":          ConsistencyOne,
+	"LOCAL_ONE":    ConsistencyLocalOne,
+	"TWO":          ConsistencyTwo,
+	"THREE":        ConsistencyThree,
+	"LOCAL_QUORUM": ConsistencyLocalQuorum,
+	"QUORUM":       ConsistencyQuorum,
+	"EACH_QUORUM":  ConsistencyEachQuorum,
+	"ALL":          ConsistencyAll,
+}
+
+// ParseConsistency converts a CQL consistency name such as "LOCAL_QUORUM".
+// An empty name yields DefaultConsistency.
+func ParseConsistency(name string) (Consistency, error) {
+	name = strings.TrimSpace(strings.ToUpper(name))
+	if name == "" {
+		return DefaultConsistency, nil
+	}
+	consistency, ok := consistencyByName[name]
+	if !ok {
+		return DefaultConsistency, fmt.Errorf("invalid consistency %q", name)
+	}
+	return consistency, nil
+}
+
+func (c Consistency) toGocql() gocql.Consistency {
+	switch c {
+	case ConsistencyAny:
+		return gocql.Any
+	case ConsistencyOne:
+		return gocql.One
+	case ConsistencyLocalOne:
+		return gocql.LocalOne
+	case ConsistencyTwo:
+		return gocql.Two
+	case ConsistencyThree:
+		return gocql.Three
+	case ConsistencyLocalQuorum:
+		return gocql.LocalQuorum
+	case ConsistencyEachQuorum:
+		return gocql.EachQuorum
+	case ConsistencyAll:
+		return gocql.All
+	default:
+		return gocql.Quorum
+	}
+}
+
 type Session interface {
 	Query(string, ...interface{}) Query
 	PreparedQuery(string, ...interface{}) Query
@@ -20,14 +91,33 @@ type Session interface {
 }
 
 type Query interface {
+	WithConsistency(Consistency)
+	// WithTimeout bounds the execution of this query. Zero keeps the
+	// session timeout.
+	WithTimeout(time.Duration)
 	Exec() error
+	ExecContext(context.Context) error
 }
 
 type Batch interface {
 	Query(string, ...interface{})
 	WithTimestamp(int64)
+	WithConsistency(Consistency)
+	// WithTimeout bounds the execution of this batch. Zero keeps the
+	// session timeout.
+	WithTimeout(time.Duration)
 	Size() int
 	ExecuteBatch() error
+	ExecuteBatchContext(context.Context) error
+}
+
+// withTimeout derives a context bounded by timeout, or returns ctx unchanged
+// when timeout is zero.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
 }
 
 type GocqlSessionAdapter struct {
@@ -67,15 +157,33 @@ func (s *GocqlSessionAdapter) NewBatch(batchType BatchType) Batch {
 }
 
 type GocqlQueryAdapter struct {
-	q *gocql.Query
+	q       *gocql.Query
+	timeout time.Duration
+}
+
+func (q *GocqlQueryAdapter) WithConsistency(consistency Consistency) {
+	if consistency != DefaultConsistency {
+		q.q.SetConsistency(consistency.toGocql())
+	}
+}
+
+func (q *GocqlQueryAdapter) WithTimeout(timeout time.Duration) {
+	q.timeout = timeout
 }
 
 func (q *GocqlQueryAdapter) Exec() error {
-	return q.q.Exec()
+	return q.ExecContext(context.Background())
+}
+
+func (q *GocqlQueryAdapter) ExecContext(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, q.timeout)
+	defer cancel()
+	return q.q.ExecContext(ctx)
 }
 
 type GocqlBatchAdapter struct {
-	batch *gocql.Batch
+	batch   *gocql.Batch
+	timeout time.Duration
 }
 
 func (b *GocqlBatchAdapter) Query(stmt string, values ...interface{}) {
@@ -86,10 +194,26 @@ func (b *GocqlBatchAdapter) WithTimestamp(timestamp int64) {
 	b.batch.WithTimestamp(timestamp)
 }
 
+func (b *GocqlBatchAdapter) WithConsistency(consistency Consistency) {
+	if consistency != DefaultConsistency {
+		b.batch.SetConsistency(consistency.toGocql())
+	}
+}
+
 func (b *GocqlBatchAdapter) Size() int {
 	return b.batch.Size()
 }
 
+func (b *GocqlBatchAdapter) WithTimeout(timeout time.Duration) {
+	b.timeout = timeout
+}
+
 func (b *GocqlBatchAdapter) ExecuteBatch() error {
-	return b.batch.Exec()
+	return b.ExecuteBatchContext(context.Background())
+}
+
+func (b *GocqlBatchAdapter) ExecuteBatchContext(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, b.timeout)
+	defer cancel()
+	return b.batch.ExecContext(ctx)
 }
@@ -1,9 +1,11 @@
 package cassandra
 
 import (
+	"context"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -39,6 +41,10 @@ type enhancedMockQuery struct {
 	execCalled bool
 }
 
+func (m *enhancedMockQuery) WithTimeout(time.Duration)         {}
+func (m *enhancedMockQuery) ExecContext(context.Context) error { return m.Exec() }
+func (m *enhancedMockQuery) WithConsistency(Consistency)       {}
+
 func (m *enhancedMockQuery) Exec() error {
 	m.execCalled = true
 	return nil
@@ -65,6 +71,10 @@ func (m *enhancedMockBatch) ExecuteBatch() error {
 
 func (m *enhancedMockBatch) WithTimestamp(int64) {}
 
+func (m *enhancedMockBatch) WithTimeout(time.Duration)                 {}
+func (m *enhancedMockBatch) ExecuteBatchContext(context.Context) error { return m.ExecuteBatch() }
+func (m *enhancedMockBatch) WithConsistency(Consistency)               {}
+
 func TestSessionInterfaceImplementation(t *testing.T) {
 	var _ Session = &GocqlSessionAdapter{}
 }
@@ -185,3 +195,35 @@ func TestEnhancedMockBatch_Operations(t *testing.T) {
 	err := batch.ExecuteBatch()
 	assert.NoError(t, err)
 }
+
+func TestParseConsistency(t *testing.T) {
+	c, err := ParseConsistency(" local_quorum ")
+	assert.NoError(t, err)
+	assert.Equal(t, ConsistencyLocalQuorum, c)
+
+	c, err = ParseConsistency("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultConsistency, c)
+
+	_, err = ParseConsistency("SOMETIMES")
+	assert.Error(t, err)
+}
+
+func TestConsistency_StrengthOrder(t *testing.T) {
+	assert.Less(t, ConsistencyLocalOne, ConsistencyLocalQuorum)
+	assert.Less(t, ConsistencyLocalQuorum, ConsistencyQuorum)
+	assert.Less(t, ConsistencyQuorum, ConsistencyAll)
+}
+
+func TestWithTimeout(t *testing.T) {
+	ctx := context.Background()
+	same, cancel := withTimeout(ctx, 0)
+	cancel()
+	assert.Equal(t, ctx, same)
+
+	bounded, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+	deadline, ok := bounded.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Placeholders of tableName and keyspace resolved from the event's
+// collection: its scope and collection names, and the groups the mapping's
+// collection pattern captured, as _match.<name> or _match.<number>.
+const (
+	ScopePlaceholder       = "_scope"
+	CollectionPlaceholder  = "_collection"
+	MatchPlaceholderPrefix = "_match."
+)
+
+// DefaultCollectionName is the name of a bucket's default collection.
+const DefaultCollectionName = "_default"
+
+// CollectionPattern returns the regular expression a mapping matches
+// against "scope.collection", or "" for a mapping without collection.
+//
+// collectionRegex is used as is. Otherwise collection is
+// "[scope.]collection", where either part may contain the wildcards * and
+// ?, each captured as a numbered group; without a scope any scope matches.
+func (m CollectionTableMapping) CollectionPattern() string {
+	if m.CollectionRegex != "" {
+		return `^(?:` + m.CollectionRegex + `)$`
+	}
+	if m.Collection == "" {
+		return ""
+	}
+	scope, collection, scoped := strings.Cut(m.Collection, ".")
+	if !scoped {
+		return `^[^.]*\.` + globPattern(m.Collection) + `$`
+	}
+	return `^` + globPattern(scope) + `\.` + globPattern(collection) + `$`
+}
+
+// IsDefaultCollectionMapping reports whether the mapping applies to the
+// collections no other mapping matches.
+func (m CollectionTableMapping) IsDefaultCollectionMapping() bool {
+	return m.CollectionRegex == "" && (m.Collection == "" || m.Collection == DefaultCollectionName)
+}
+
+// StaticTable reports whether tableName is the same for every event.
+func (m CollectionTableMapping) StaticTable() bool {
+	return !strings.Contains(m.TableName, "{")
+}
+
+func globPattern(glob string) string {
+	var b strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(`([^.]*)`)
+		case '?':
+			b.WriteString(`([^.])`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return b.String()
+}
+
+// validateCollection checks the collection pattern and the _match
+// placeholders of tableName and keyspace.
+func validateCollection(m CollectionTableMapping) error {
+	if m.Collection != "" && m.CollectionRegex != "" {
+		return fmt.Errorf("table %s cannot have both collection and collectionRegex", m.TableName)
+	}
+	var pattern *regexp.Regexp
+	if source := m.CollectionPattern(); source != "" {
+		var err error
+		if pattern, err = regexp.Compile(source); err != nil {
+			return fmt.Errorf("invalid collectionRegex for table %s: %w", m.TableName, err)
+		}
+	}
+	if !m.StaticTable() && !keyspaceTemplatePattern.MatchString(m.TableName) {
+		return fmt.Errorf("invalid tableName %q: use letters, digits, underscores and {placeholders}", m.TableName)
+	}
+
+	for _, template := range []string{m.TableName, m.Keyspace} {
+		for _, match := range keyspacePlaceholderPattern.FindAllStringSubmatch(template, -1) {
+			group, ok := strings.CutPrefix(match[1], MatchPlaceholderPrefix)
+			if !ok {
+				continue
+			}
+			if pattern == nil || !hasGroup(pattern, group) {
+				return fmt.Errorf("collection pattern of table %s has no group %q", m.TableName, group)
+			}
+		}
+	}
+	return nil
+}
+
+func hasGroup(pattern *regexp.Regexp, group string) bool {
+	if n, err := strconv.Atoi(group); err == nil {
+		return n >= 1 && n <= pattern.NumSubexp()
+	}
+	return pattern.SubexpIndex(group) >= 0
+}
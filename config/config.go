@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
@@ -9,12 +10,133 @@ import (
 )
 
 type CollectionTableMapping struct {
-	FieldMappings    map[string]string `yaml:"fieldMappings"`
-	PrimaryKeyFields []string          `yaml:"primaryKeyFields,omitempty"`
-	Collection       string            `yaml:"collection"`
-	TableName        string            `yaml:"tableName"`
+	FieldMappings map[string]string `yaml:"fieldMappings"`
+	// FieldSpecs holds the structured fieldMappings entries: type, format,
+	// default and null handling per column. Their sources are also in
+	// FieldMappings.
+	FieldSpecs map[string]FieldSpec `yaml:"-"`
+	// OperationConsistency overrides Consistency for individual operations
+	// (insert, update, delete, upsert) on this table.
+	OperationConsistency map[string]string `yaml:"operationConsistency,omitempty"`
+	// ColumnTypes declares the CQL type of mapped columns so the default
+	// mapper can coerce JSON values. Types missing here are read from
+	// system_schema at startup unless schema validation is disabled.
+	ColumnTypes map[string]string `yaml:"columnTypes,omitempty"`
+	// UserTypes declares the fields of the user-defined types in
+	// ColumnTypes, type name to field name to field type. Types missing
+	// here are read from system_schema at startup like ColumnTypes.
+	UserTypes        map[string]map[string]string `yaml:"userTypes,omitempty"`
+	PrimaryKeyFields []string                     `yaml:"primaryKeyFields,omitempty"`
+	// MissingFieldPolicy chooses what upserts write for columns whose
+	// source field is missing: null (the default), unset, default or error.
+	// Field specs can override it per column.
+	MissingFieldPolicy string `yaml:"missingFieldPolicy,omitempty"`
+	// Collection is "[scope.]collection", optionally with * and ? wildcards.
+	Collection string `yaml:"collection"`
+	// CollectionRegex is matched against "scope.collection" instead of
+	// Collection. Its groups are available as {_match.<group>} placeholders.
+	CollectionRegex string `yaml:"collectionRegex,omitempty"`
+	// TableName may contain the {_scope}, {_collection} and {_match.<group>}
+	// placeholders.
+	TableName string `yaml:"tableName"`
+	// Consistency overrides cassandra.consistency for every statement on this table.
+	Consistency string `yaml:"consistency,omitempty"`
+	// Keyspace overrides cassandra.keyspace for this table. It may contain
+	// placeholders resolved per document: {_keyPrefix} is the document key
+	// up to KeyPrefixSeparator, {_key.<group>} a KeyPattern group, the
+	// collection placeholders are those of TableName, and any other name is
+	// a document field path.
+	Keyspace           string `yaml:"keyspace,omitempty"`
+	KeyPrefixSeparator string `yaml:"keyPrefixSeparator,omitempty"`
+	// KeyPattern is a regular expression with named groups matched against
+	// the document key. Each group is available as the _key.<group> source.
+	KeyPattern string `yaml:"keyPattern,omitempty"`
+	// Filter skips events that should not be written to this table.
+	Filter *Filter `yaml:"filter,omitempty"`
+	// Explode is the path of an array field whose elements are each written
+	// as a row, mapped with the _element and _index sources.
+	Explode string `yaml:"explode,omitempty"`
+	// DeleteMode chooses between deleting, soft deleting and ignoring, for
+	// deletions and expirations separately.
+	DeleteMode DeleteMode `yaml:"deleteMode,omitempty"`
+	SoftDelete SoftDelete `yaml:"softDelete,omitempty"`
+	// InvalidDocumentPolicy handles events whose body is binary, not a
+	// JSON object or cannot be mapped: fail (the default), skip, raw or
+	// deadLetter.
+	InvalidDocumentPolicy string `yaml:"invalidDocumentPolicy,omitempty"`
+	// DeadLetterTable is the "[keyspace.]table" the deadLetter policy
+	// writes to.
+	DeadLetterTable string `yaml:"deadLetterTable,omitempty"`
+	// WriteMode chooses the statement of mutations: upsert (the default),
+	// insertIfNotExists or updateExisting.
+	WriteMode string `yaml:"writeMode,omitempty"`
+	// IfExists makes updateExisting skip rows that do not exist instead of
+	// creating them.
+	IfExists bool `yaml:"ifExists,omitempty"`
 }
 
+// KeyPrefixPlaceholder is the keyspace placeholder replaced by the document
+// key prefix.
+const KeyPrefixPlaceholder = "_keyPrefix"
+
+// KeyGroupSourcePrefix starts the sources, and keyspace placeholders, that
+// read a named group of KeyPattern.
+const KeyGroupSourcePrefix = "_key."
+
+var (
+	keyspaceTemplatePattern    = regexp.MustCompile(`^([A-Za-z0-9_]|\{[^{}]+\})+$`)
+	keyspacePlaceholderPattern = regexp.MustCompile(`\{([^{}]+)\}`)
+	// keyspaceNamePattern matches the unquoted keyspace and table names
+	// Cassandra accepts.
+	keyspaceNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,48}$`)
+)
+
+// IsValidName reports whether name is an unquoted keyspace or table name
+// Cassandra accepts. Names resolved per document are interpolated into CQL,
+// so anything else is rejected.
+func IsValidName(name string) bool {
+	return keyspaceNamePattern.MatchString(name)
+}
+
+// ReplacePlaceholders replaces every {source} placeholder of a keyspace or
+// table name template with replace(source).
+func ReplacePlaceholders(template string, replace func(source string) string) string {
+	return keyspacePlaceholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		return replace(placeholder[1 : len(placeholder)-1])
+	})
+}
+
+// StaticKeyspace returns the keyspace every statement of this mapping is
+// written to, or false when it depends on the document.
+func (m CollectionTableMapping) StaticKeyspace(defaultKeyspace string) (string, bool) {
+	if m.Keyspace == "" {
+		return defaultKeyspace, true
+	}
+	if strings.Contains(m.Keyspace, "{") {
+		return "", false
+	}
+	return m.Keyspace, true
+}
+
+// ConnectRetry controls how long the connector waits for Cassandra to become
+// reachable, both at startup and when a lost session has to be rebuilt.
+type ConnectRetry struct {
+	MaxDuration time.Duration `yaml:"maxDuration"`
+	MinBackoff  time.Duration `yaml:"minBackoff"`
+	MaxBackoff  time.Duration `yaml:"maxBackoff"`
+}
+
+var (
+	validConsistencies = map[string]bool{
+		"ANY": true, "ONE": true, "TWO": true, "THREE": true,
+		"QUORUM": true, "ALL": true, "LOCAL_QUORUM": true,
+		"EACH_QUORUM": true, "LOCAL_ONE": true,
+	}
+	validOperations = map[string]bool{
+		"insert": true, "update": true, "delete": true, "upsert": true,
+	}
+)
+
 type Cassandra struct {
 	Username          string `yaml:"username"`
 	Password          string `yaml:"password"`
@@ -31,29 +153,59 @@ type Cassandra struct {
 		InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
 	} `yaml:"ssl"`
 	CollectionTableMapping []CollectionTableMapping `yaml:"collectionTableMapping,omitempty"`
+	OperationConsistency   map[string]string        `yaml:"operationConsistency,omitempty"`
 	Hosts                  []string                 `yaml:"hosts"`
 	RetryPolicy            struct {
 		NumRetries    int           `yaml:"numRetries"`
 		MinRetryDelay time.Duration `yaml:"minRetryDelay"`
 		MaxRetryDelay time.Duration `yaml:"maxRetryDelay"`
 	} `yaml:"retryPolicy"`
+	ConnectRetry        ConnectRetry  `yaml:"connectRetry"`
 	KeepAlive           time.Duration `yaml:"keepAlive"`
 	Timeout             time.Duration `yaml:"timeout"`
 	ConnectTimeout      time.Duration `yaml:"connectTimeout"`
 	BatchTickerDuration time.Duration `yaml:"batchTickerDuration"`
-	NumConns            int           `yaml:"numConns"`
-	MaxPreparedStmts    int           `yaml:"maxPreparedStmts"`
-	MaxRoutingKeyInfo   int           `yaml:"maxRoutingKeyInfo"`
-	PageSize            int           `yaml:"pageSize"`
-	BatchSizeLimit      int           `yaml:"batchSizeLimit"`
-	BatchByteSizeLimit  int           `yaml:"batchByteSizeLimit"`
-	MaxInFlightRequests int           `yaml:"maxInFlightRequests"`
-	BatchPerEvent       bool          `yaml:"batchPerEvent"`
-	HostSelectionPolicy string        `yaml:"hostSelectionPolicy"`
-	WriteTimestamp      string        `yaml:"writeTimestamp"`
+	// StatementTimeout bounds each write; zero leaves it to timeout.
+	StatementTimeout time.Duration `yaml:"statementTimeout"`
+	// ShutdownDrainTimeout is how long Close waits for in-flight writes
+	// before cancelling them.
+	ShutdownDrainTimeout time.Duration `yaml:"shutdownDrainTimeout"`
+	NumConns             int           `yaml:"numConns"`
+	MaxPreparedStmts     int           `yaml:"maxPreparedStmts"`
+	MaxRoutingKeyInfo    int           `yaml:"maxRoutingKeyInfo"`
+	PageSize             int           `yaml:"pageSize"`
+	BatchSizeLimit       int           `yaml:"batchSizeLimit"`
+	BatchByteSizeLimit   int           `yaml:"batchByteSizeLimit"`
+	MaxInFlightRequests  int           `yaml:"maxInFlightRequests"`
+	BatchPerEvent        bool          `yaml:"batchPerEvent"`
+	// DisableSchemaValidation skips checking collectionTableMapping against
+	// system_schema at startup.
+	DisableSchemaValidation bool   `yaml:"disableSchemaValidation"`
+	HostSelectionPolicy     string `yaml:"hostSelectionPolicy"`
+	WriteTimestamp          string `yaml:"writeTimestamp"`
 }
 
+const (
+	DualWritePolicyBestEffort  = "best_effort"
+	DualWritePolicyRequireBoth = "require_both"
+)
+
+// DualWrite mirrors every flush to a second Cassandra cluster. The secondary
+// has its own session, consistency and concurrency settings; table mappings
+// are always taken from the primary block.
+type DualWrite struct {
+	Policy string `yaml:"policy"`
+	// QueueSize is how many flushes a best_effort secondary may fall behind
+	// the primary before further flushes are dropped for it.
+	QueueSize int       `yaml:"queueSize"`
+	Cassandra Cassandra `yaml:"cassandra"`
+}
+
+// DefaultDualWriteQueueSize is the default DualWrite.QueueSize.
+const DefaultDualWriteQueueSize = 16
+
 type Connector struct {
+	DualWrite *DualWrite `yaml:"dualWrite,omitempty" mapstructure:"dualWrite"`
 	Dcp       config.Dcp `yaml:",inline" mapstructure:",squash"`
 	Cassandra Cassandra  `yaml:"cassandra" mapstructure:"cassandra"`
 }
@@ -63,20 +215,51 @@ func (c *Cassandra) setDefaults() {
 	c.setBatchDefaults()
 	c.setConnectionDefaults()
 	c.setRetryDefaults()
+	c.setMappingDefaults()
 }
 
-func (c *Cassandra) setConsistencyDefault() {
-	validConsistencies := map[string]bool{
-		"ANY": true, "ONE": true, "TWO": true, "THREE": true,
-		"QUORUM": true, "ALL": true, "LOCAL_QUORUM": true,
-		"EACH_QUORUM": true, "LOCAL_ONE": true,
+func (c *Cassandra) setMappingDefaults() {
+	for i := range c.CollectionTableMapping {
+		m := &c.CollectionTableMapping[i]
+		if m.Keyspace != "" && m.KeyPrefixSeparator == "" {
+			m.KeyPrefixSeparator = ":"
+		}
+		m.setUserTypeDefaults()
+		m.setFieldSpecDefaults()
+		m.setMissingFieldDefaults()
+		m.setDeleteModeDefaults()
+		m.setInvalidDocumentDefaults()
+		m.setWriteModeDefaults()
 	}
+}
+
+func (c *Cassandra) setConsistencyDefault() {
 	consistency := strings.TrimSpace(strings.ToUpper(c.Consistency))
 	if consistency == "" || !validConsistencies[consistency] {
 		c.Consistency = "QUORUM"
 	} else {
 		c.Consistency = consistency
 	}
+
+	// Overrides are only normalized here; invalid values are reported by
+	// Validate instead of silently falling back.
+	c.OperationConsistency = normalizeOperationConsistency(c.OperationConsistency)
+	for i := range c.CollectionTableMapping {
+		m := &c.CollectionTableMapping[i]
+		m.Consistency = strings.TrimSpace(strings.ToUpper(m.Consistency))
+		m.OperationConsistency = normalizeOperationConsistency(m.OperationConsistency)
+	}
+}
+
+func normalizeOperationConsistency(overrides map[string]string) map[string]string {
+	if len(overrides) == 0 {
+		return overrides
+	}
+	normalized := make(map[string]string, len(overrides))
+	for operation, consistency := range overrides {
+		normalized[strings.TrimSpace(strings.ToLower(operation))] = strings.TrimSpace(strings.ToUpper(consistency))
+	}
+	return normalized
 }
 
 func (c *Cassandra) setBatchDefaults() {
@@ -124,6 +307,9 @@ func (c *Cassandra) setConnectionDefaults() {
 	if c.PageSize <= 0 {
 		c.PageSize = 5000
 	}
+	if c.ShutdownDrainTimeout <= 0 {
+		c.ShutdownDrainTimeout = 30 * time.Second
+	}
 }
 
 func (c *Cassandra) setRetryDefaults() {
@@ -136,14 +322,79 @@ func (c *Cassandra) setRetryDefaults() {
 	if c.RetryPolicy.MaxRetryDelay <= 0 {
 		c.RetryPolicy.MaxRetryDelay = 1 * time.Second
 	}
+	if c.ConnectRetry.MaxDuration < 0 {
+		c.ConnectRetry.MaxDuration = 0
+	}
+	if c.ConnectRetry.MinBackoff <= 0 {
+		c.ConnectRetry.MinBackoff = 500 * time.Millisecond
+	}
+	if c.ConnectRetry.MaxBackoff <= 0 {
+		c.ConnectRetry.MaxBackoff = 10 * time.Second
+	}
+	if c.ConnectRetry.MaxBackoff < c.ConnectRetry.MinBackoff {
+		c.ConnectRetry.MaxBackoff = c.ConnectRetry.MinBackoff
+	}
 }
 
 func (c *Connector) ApplyDefaults() {
 	c.Cassandra.setDefaults()
+	if c.DualWrite != nil {
+		c.DualWrite.setDefaults()
+	}
+}
+
+func (d *DualWrite) setDefaults() {
+	d.Cassandra.setDefaults()
+
+	policy := strings.TrimSpace(strings.ToLower(d.Policy))
+	if policy != DualWritePolicyRequireBoth {
+		d.Policy = DualWritePolicyBestEffort
+	} else {
+		d.Policy = policy
+	}
+	if d.QueueSize <= 0 {
+		d.QueueSize = DefaultDualWriteQueueSize
+	}
 }
 
 func (c *Connector) Validate() error {
+	if c.DualWrite != nil && len(c.DualWrite.Cassandra.Hosts) == 0 {
+		return fmt.Errorf("dualWrite.cassandra.hosts must not be empty")
+	}
+	if c.DualWrite != nil && !keyspaceNamePattern.MatchString(c.DualWrite.Cassandra.Keyspace) {
+		return fmt.Errorf("invalid dualWrite.cassandra.keyspace %q: use 1 to 48 letters, digits and underscores",
+			c.DualWrite.Cassandra.Keyspace)
+	}
+	if err := validateOperationConsistency("cassandra", c.Cassandra.OperationConsistency); err != nil {
+		return err
+	}
+	if c.DualWrite != nil {
+		if err := validateOperationConsistency("dualWrite.cassandra", c.DualWrite.Cassandra.OperationConsistency); err != nil {
+			return err
+		}
+	}
+	tables := make(map[string]bool, len(c.Cassandra.CollectionTableMapping))
 	for _, m := range c.Cassandra.CollectionTableMapping {
+		table := m.Collection + m.CollectionRegex + "/" + m.Keyspace + "." + m.TableName
+		if tables[table] {
+			return fmt.Errorf("table %s is mapped more than once for collection %q", m.TableName, m.Collection)
+		}
+		tables[table] = true
+		if err := validateUserTypes(m); err != nil {
+			return err
+		}
+		if err := validateMissingFieldPolicy(m); err != nil {
+			return err
+		}
+		if err := validateFieldSpecs(m); err != nil {
+			return err
+		}
+		if err := validateExpressions(m); err != nil {
+			return err
+		}
+		if err := validateFieldPaths(m); err != nil {
+			return err
+		}
 		for _, pk := range m.PrimaryKeyFields {
 			if _, exists := m.FieldMappings[pk]; !exists {
 				return fmt.Errorf(
@@ -152,6 +403,90 @@ func (c *Connector) Validate() error {
 				)
 			}
 		}
+		if m.Consistency != "" && !validConsistencies[m.Consistency] {
+			return fmt.Errorf("invalid consistency %q for table %s", m.Consistency, m.TableName)
+		}
+		if err := validateOperationConsistency("table "+m.TableName, m.OperationConsistency); err != nil {
+			return err
+		}
+		if m.Keyspace != "" && !keyspaceTemplatePattern.MatchString(m.Keyspace) {
+			return fmt.Errorf(
+				"invalid keyspace %q for table %s: use letters, digits, underscores and {placeholders}",
+				m.Keyspace, m.TableName,
+			)
+		}
+		if err := validateCollection(m); err != nil {
+			return err
+		}
+		if err := validateKeyPattern(m); err != nil {
+			return err
+		}
+		if err := validateFilter(m); err != nil {
+			return err
+		}
+		if err := validateExplode(m); err != nil {
+			return err
+		}
+		if err := validateDeleteMode(m); err != nil {
+			return err
+		}
+		if err := validateInvalidDocumentPolicy(m); err != nil {
+			return err
+		}
+		if err := validateWriteMode(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateKeyPattern checks that keyPattern compiles and defines every
+// group used by a _key.<group> source or keyspace placeholder.
+func validateKeyPattern(m CollectionTableMapping) error {
+	var groups map[string]bool
+	if m.KeyPattern != "" {
+		pattern, err := regexp.Compile(m.KeyPattern)
+		if err != nil {
+			return fmt.Errorf("invalid keyPattern for table %s: %w", m.TableName, err)
+		}
+		groups = make(map[string]bool)
+		for _, name := range pattern.SubexpNames() {
+			if name != "" {
+				groups[name] = true
+			}
+		}
+	}
+
+	sources := make([]string, 0, len(m.FieldMappings))
+	for _, source := range m.FieldMappings {
+		sources = append(sources, source)
+	}
+	for _, match := range keyspacePlaceholderPattern.FindAllStringSubmatch(m.Keyspace, -1) {
+		sources = append(sources, match[1])
+	}
+	for _, source := range sources {
+		group, ok := strings.CutPrefix(source, KeyGroupSourcePrefix)
+		if !ok {
+			continue
+		}
+		if m.KeyPattern == "" {
+			return fmt.Errorf("source %s of table %s requires a keyPattern", source, m.TableName)
+		}
+		if !groups[group] {
+			return fmt.Errorf("keyPattern of table %s has no group named %q", m.TableName, group)
+		}
+	}
+	return nil
+}
+
+func validateOperationConsistency(scope string, overrides map[string]string) error {
+	for operation, consistency := range overrides {
+		if !validOperations[operation] {
+			return fmt.Errorf("invalid operation %q in operationConsistency for %s", operation, scope)
+		}
+		if !validConsistencies[consistency] {
+			return fmt.Errorf("invalid consistency %q for operation %s in %s", consistency, operation, scope)
+		}
 	}
 	return nil
 }
@@ -1,11 +1,14 @@
 package config
 
 import (
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 func TestCassandra_SetDefaults(t *testing.T) {
@@ -122,6 +125,21 @@ func TestCassandra_SetDefaults_RetryPolicy(t *testing.T) {
 	assert.Equal(t, 1*time.Second, cassandra.RetryPolicy.MaxRetryDelay)
 }
 
+func TestCassandra_SetDefaults_ConnectRetry(t *testing.T) {
+	cassandra := Cassandra{}
+	cassandra.setDefaults()
+
+	assert.Equal(t, time.Duration(0), cassandra.ConnectRetry.MaxDuration)
+	assert.Equal(t, 500*time.Millisecond, cassandra.ConnectRetry.MinBackoff)
+	assert.Equal(t, 10*time.Second, cassandra.ConnectRetry.MaxBackoff)
+
+	cassandra = Cassandra{ConnectRetry: ConnectRetry{MaxDuration: time.Minute, MinBackoff: 20 * time.Second}}
+	cassandra.setDefaults()
+
+	assert.Equal(t, time.Minute, cassandra.ConnectRetry.MaxDuration)
+	assert.Equal(t, 20*time.Second, cassandra.ConnectRetry.MaxBackoff, "maxBackoff must not be below minBackoff")
+}
+
 func TestCassandra_SetDefaults_CustomValues(t *testing.T) {
 	cassandra := Cassandra{
 		NumConns:         5,
@@ -169,6 +187,120 @@ func TestConnector_ApplyDefaults(t *testing.T) {
 	assert.Equal(t, "QUORUM", config.Cassandra.Consistency, "Empty consistency should default to QUORUM")
 }
 
+func TestConnector_ApplyDefaults_DualWrite(t *testing.T) {
+	c := Connector{
+		DualWrite: &DualWrite{
+			Cassandra: Cassandra{Hosts: []string{"secondary:9042"}, Consistency: "local_one", MaxInFlightRequests: 10},
+		},
+	}
+	c.ApplyDefaults()
+
+	assert.Equal(t, DualWritePolicyBestEffort, c.DualWrite.Policy)
+	assert.Equal(t, DefaultDualWriteQueueSize, c.DualWrite.QueueSize)
+	assert.Equal(t, "LOCAL_ONE", c.DualWrite.Cassandra.Consistency)
+	assert.Equal(t, 10, c.DualWrite.Cassandra.MaxInFlightRequests)
+	assert.Equal(t, 2000, c.DualWrite.Cassandra.BatchSizeLimit)
+
+	c.DualWrite.Policy = " REQUIRE_BOTH "
+	c.ApplyDefaults()
+	assert.Equal(t, DualWritePolicyRequireBoth, c.DualWrite.Policy)
+}
+
+func TestValidate_DualWrite_RequiresHosts(t *testing.T) {
+	c := &Connector{DualWrite: &DualWrite{}}
+	err := c.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "dualWrite")
+}
+
+func TestValidate_DualWrite_Keyspace(t *testing.T) {
+	for _, keyspace := range []string{"", "new-keyspace", "{_keyPrefix}"} {
+		c := &Connector{DualWrite: &DualWrite{Cassandra: Cassandra{Hosts: []string{"secondary:9042"}, Keyspace: keyspace}}}
+		err := c.Validate()
+		require.Error(t, err, keyspace)
+		assert.Contains(t, err.Error(), "dualWrite.cassandra.keyspace")
+	}
+
+	c := &Connector{DualWrite: &DualWrite{Cassandra: Cassandra{Hosts: []string{"secondary:9042"}, Keyspace: "new_keyspace"}}}
+	assert.NoError(t, c.Validate())
+}
+
+func TestValidate_ConsistencyOverrides(t *testing.T) {
+	c := &Connector{
+		Cassandra: Cassandra{
+			OperationConsistency: map[string]string{" Delete ": "local_quorum"},
+			CollectionTableMapping: []CollectionTableMapping{
+				{
+					TableName:            "orders",
+					FieldMappings:        map[string]string{"id": "_key"},
+					Consistency:          "local_one",
+					OperationConsistency: map[string]string{"UPSERT": "one"},
+				},
+			},
+		},
+	}
+	c.ApplyDefaults()
+	require.NoError(t, c.Validate())
+	assert.Equal(t, map[string]string{"delete": "LOCAL_QUORUM"}, c.Cassandra.OperationConsistency)
+	assert.Equal(t, "LOCAL_ONE", c.Cassandra.CollectionTableMapping[0].Consistency)
+	assert.Equal(t, map[string]string{"upsert": "ONE"}, c.Cassandra.CollectionTableMapping[0].OperationConsistency)
+
+	c.Cassandra.CollectionTableMapping[0].Consistency = "SOMETIMES"
+	err := c.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "orders")
+
+	c.Cassandra.CollectionTableMapping[0].Consistency = ""
+	c.Cassandra.OperationConsistency = map[string]string{"truncate": "ONE"}
+	err = c.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "truncate")
+}
+
+func TestValidate_MappingKeyspace(t *testing.T) {
+	c := &Connector{
+		Cassandra: Cassandra{
+			Keyspace: "ks",
+			CollectionTableMapping: []CollectionTableMapping{
+				{TableName: "orders", FieldMappings: map[string]string{"id": "_key"}, Keyspace: "tenant_{_keyPrefix}"},
+				{TableName: "items", FieldMappings: map[string]string{"id": "_key"}},
+			},
+		},
+	}
+	c.ApplyDefaults()
+	require.NoError(t, c.Validate())
+	assert.Equal(t, ":", c.Cassandra.CollectionTableMapping[0].KeyPrefixSeparator)
+	assert.Empty(t, c.Cassandra.CollectionTableMapping[1].KeyPrefixSeparator)
+
+	for _, keyspace := range []string{"tenant-a", "ks; DROP TABLE x", "tenant_{", "{}"} {
+		c.Cassandra.CollectionTableMapping[0].Keyspace = keyspace
+		err := c.Validate()
+		require.Error(t, err, keyspace)
+		assert.Contains(t, err.Error(), "orders")
+	}
+}
+
+func TestCollectionTableMapping_StaticKeyspace(t *testing.T) {
+	keyspace, static := CollectionTableMapping{}.StaticKeyspace("ks")
+	assert.True(t, static)
+	assert.Equal(t, "ks", keyspace)
+
+	keyspace, static = CollectionTableMapping{Keyspace: "archive"}.StaticKeyspace("ks")
+	assert.True(t, static)
+	assert.Equal(t, "archive", keyspace)
+
+	_, static = CollectionTableMapping{Keyspace: "t_{tenant}"}.StaticKeyspace("ks")
+	assert.False(t, static)
+}
+
+func TestReplacePlaceholders(t *testing.T) {
+	name := ReplacePlaceholders("t_{_key.tenant}_{region}", strings.ToUpper)
+	assert.Equal(t, "t__KEY.TENANT_REGION", name)
+	assert.False(t, IsValidName(name))
+	assert.True(t, IsValidName("t_acme_eu"))
+	assert.False(t, IsValidName("t_eu;drop"))
+}
+
 func TestValidate_PrimaryKeyFields_Valid(t *testing.T) {
 	c := &Connector{
 		Cassandra: Cassandra{
@@ -236,3 +368,886 @@ func TestConnector_Validation(t *testing.T) {
 	config.Cassandra.setDefaults()
 	assert.Equal(t, "QUORUM", config.Cassandra.Consistency, "Empty consistency should default to QUORUM")
 }
+
+func TestCollectionTableMapping_UnmarshalFieldSpecs(t *testing.T) {
+	input := `
+collection: orders
+tableName: orders_table
+primaryKeyFields: [id]
+fieldMappings:
+  id: _key
+  created_at: {source: createdAt, type: timestamp, format: RFC3339}
+  status:
+    source: status
+    default: new
+    nullPolicy: Default
+`
+	var m CollectionTableMapping
+	require.NoError(t, yaml.Unmarshal([]byte(input), &m))
+
+	assert.Equal(t, "orders_table", m.TableName)
+	assert.Equal(t, []string{"id"}, m.PrimaryKeyFields)
+	assert.Equal(t, map[string]string{"id": "_key", "created_at": "createdAt", "status": "status"}, m.FieldMappings)
+	require.Len(t, m.FieldSpecs, 2)
+	assert.Equal(t, FieldSpec{Source: "createdAt", Type: "timestamp", Format: "RFC3339"}, m.FieldSpecs["created_at"])
+	assert.Equal(t, "new", m.FieldSpecs["status"].Default)
+
+	c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{m}}}
+	c.ApplyDefaults()
+	require.NoError(t, c.Validate())
+	mapping := c.Cassandra.CollectionTableMapping[0]
+	assert.Equal(t, map[string]string{"created_at": "timestamp"}, mapping.ColumnTypes)
+	assert.Equal(t, NullPolicyDefault, mapping.FieldSpecs["status"].NullPolicy)
+	assert.Equal(t, NullPolicyNull, mapping.FieldSpecs["created_at"].NullPolicy)
+}
+
+func TestValidate_FieldSpecs(t *testing.T) {
+	tests := []struct {
+		name        string
+		spec        FieldSpec
+		columnTypes map[string]string
+		errContains string
+	}{
+		{name: "valid layout", spec: FieldSpec{Source: "at", Type: "date", Format: "02/01/2006"}},
+		{name: "valid epoch", spec: FieldSpec{Source: "at", Type: "timestamp", Format: TimeFormatEpochMillis}},
+		{
+			name:        "format with declared column type",
+			spec:        FieldSpec{Source: "at", Format: "RFC3339"},
+			columnTypes: map[string]string{"col": "timestamp"},
+		},
+		{name: "missing source", spec: FieldSpec{Type: "int"}, errContains: "source is required"},
+		{name: "unsupported type", spec: FieldSpec{Source: "at", Type: "address"}, errContains: "unsupported type"},
+		{
+			name:        "type conflict",
+			spec:        FieldSpec{Source: "at", Type: "int"},
+			columnTypes: map[string]string{"col": "bigint"},
+			errContains: "conflicts with columnTypes",
+		},
+		{
+			name:        "format without time type",
+			spec:        FieldSpec{Source: "at", Type: "text", Format: "RFC3339"},
+			errContains: "requires type timestamp or date",
+		},
+		{name: "unknown format", spec: FieldSpec{Source: "at", Type: "timestamp", Format: "iso"}, errContains: "invalid format"},
+		{name: "invalid null policy", spec: FieldSpec{Source: "at", NullPolicy: "skip"}, errContains: "invalid nullPolicy"},
+		{name: "default policy without default", spec: FieldSpec{Source: "at", NullPolicy: "default"}, errContains: "requires a default"},
+		{name: "required with default", spec: FieldSpec{Source: "at", Required: true, Default: 1}, errContains: "both required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{{
+				TableName:   "orders",
+				FieldSpecs:  map[string]FieldSpec{"col": tt.spec},
+				ColumnTypes: tt.columnTypes,
+			}}}}
+			c.ApplyDefaults()
+			err := c.Validate()
+			if tt.errContains == "" {
+				require.NoError(t, err)
+				assert.Equal(t, tt.spec.Source, c.Cassandra.CollectionTableMapping[0].FieldMappings["col"])
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errContains)
+			assert.Contains(t, err.Error(), "orders")
+		})
+	}
+}
+
+func TestValidate_FanOutMappings(t *testing.T) {
+	c := &Connector{
+		Cassandra: Cassandra{
+			CollectionTableMapping: []CollectionTableMapping{
+				{Collection: "orders", TableName: "orders_by_id", FieldMappings: map[string]string{"id": "_key"}},
+				{Collection: "orders", TableName: "orders_by_customer", FieldMappings: map[string]string{"id": "_key"}},
+				{Collection: "orders", TableName: "orders_by_id", Keyspace: "archive", FieldMappings: map[string]string{"id": "_key"}},
+			},
+		},
+	}
+	require.NoError(t, c.Validate())
+
+	c.Cassandra.CollectionTableMapping[1].TableName = "orders_by_id"
+	err := c.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "mapped more than once")
+}
+
+func TestValidate_KeyPattern(t *testing.T) {
+	mapping := CollectionTableMapping{
+		TableName:     "orders",
+		KeyPattern:    `^order::(?P<tenant>[a-z]+)::(?P<id>\d+)$`,
+		Keyspace:      "tenant_{_key.tenant}",
+		FieldMappings: map[string]string{"tenant": "_key.tenant", "id": "_key.id"},
+	}
+	c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{mapping}}}
+	require.NoError(t, c.Validate())
+
+	tests := []struct {
+		name        string
+		mutate      func(m *CollectionTableMapping)
+		errContains string
+	}{
+		{name: "invalid pattern", mutate: func(m *CollectionTableMapping) { m.KeyPattern = "(" }, errContains: "invalid keyPattern"},
+		{name: "missing pattern", mutate: func(m *CollectionTableMapping) { m.KeyPattern = "" }, errContains: "requires a keyPattern"},
+		{
+			name:        "unknown field group",
+			mutate:      func(m *CollectionTableMapping) { m.FieldMappings = map[string]string{"region": "_key.region"} },
+			errContains: `no group named "region"`,
+		},
+		{
+			name:        "unknown keyspace group",
+			mutate:      func(m *CollectionTableMapping) { m.Keyspace = "ks_{_key.region}" },
+			errContains: `no group named "region"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mapping
+			tt.mutate(&m)
+			c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{m}}}
+			err := c.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errContains)
+		})
+	}
+}
+
+func TestValidate_Filter(t *testing.T) {
+	exists := true
+	valid := &Filter{
+		Include: []FilterRule{{KeyPrefix: "order::"}},
+		Exclude: []FilterRule{
+			{KeyRegex: "^_sync:"},
+			{Field: "type", Equals: "temp"},
+			{Field: "draft", Exists: &exists, Events: []string{EventMutation}},
+		},
+	}
+	c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{
+		{TableName: "orders", FieldMappings: map[string]string{"id": "_key"}, Filter: valid},
+	}}}
+	require.NoError(t, c.Validate())
+
+	tests := []struct {
+		name        string
+		rule        FilterRule
+		errContains string
+	}{
+		{name: "empty rule", rule: FilterRule{}, errContains: "no condition"},
+		{name: "invalid regex", rule: FilterRule{KeyRegex: "("}, errContains: "keyRegex"},
+		{name: "equals without field", rule: FilterRule{Equals: "x"}, errContains: "require a field"},
+		{name: "field without check", rule: FilterRule{Field: "type"}, errContains: "exactly one of equals or exists"},
+		{name: "equals and exists", rule: FilterRule{Field: "type", Equals: "x", Exists: &exists}, errContains: "exactly one"},
+		{name: "unknown event", rule: FilterRule{Events: []string{"update"}}, errContains: `unknown event "update"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.Cassandra.CollectionTableMapping[0].Filter = &Filter{Exclude: []FilterRule{tt.rule}}
+			err := c.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "filter.exclude[0]")
+			assert.Contains(t, err.Error(), tt.errContains)
+		})
+	}
+}
+
+func TestExpressionSource(t *testing.T) {
+	var m CollectionTableMapping
+	require.NoError(t, yaml.Unmarshal([]byte(`
+fieldMappings:
+  full_name: expr("first + ' ' + last")
+  bucket: expr(hash(_key) % 16)
+  is_active: {source: 'expr("status == \"ACTIVE\"")', type: boolean}
+  status: status
+`), &m))
+
+	tests := map[string]string{
+		"full_name": "first + ' ' + last",
+		"bucket":    "hash(_key) % 16",
+		"is_active": `status == "ACTIVE"`,
+	}
+	for column, expected := range tests {
+		expression, ok := ExpressionSource(m.FieldMappings[column])
+		assert.True(t, ok, column)
+		assert.Equal(t, expected, expression, column)
+	}
+	assert.False(t, IsExpressionSource(m.FieldMappings["status"]))
+	assert.False(t, IsExpressionSource("expression"))
+}
+
+func TestValidate_Expressions(t *testing.T) {
+	mapping := CollectionTableMapping{
+		TableName: "users",
+		FieldMappings: map[string]string{
+			"id":        "_key",
+			"full_name": `expr("first + ' ' + last")`,
+			"bucket":    `expr("hash(_key) % 16")`,
+			"is_active": `expr("status == 'ACTIVE'")`,
+		},
+		ColumnTypes: map[string]string{"bucket": "int", "is_active": "boolean"},
+	}
+	c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{mapping}}}
+	require.NoError(t, c.Validate())
+
+	tests := []struct {
+		name        string
+		source      string
+		columnType  string
+		errContains string
+	}{
+		{name: "syntax", source: `expr("first +")`, errContains: "invalid expression for column computed of table users"},
+		{name: "unknown function", source: `expr("nope(first)")`, errContains: "unknown function nope"},
+		{name: "type mismatch", source: `expr("_key % 16")`, errContains: "cannot apply % to string and int"},
+		{
+			name:        "column type",
+			source:      `expr("status == 'ACTIVE'")`,
+			columnType:  "int",
+			errContains: "is bool and cannot be written to a int column",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mapping
+			m.FieldMappings = map[string]string{"id": "_key", "computed": tt.source}
+			m.ColumnTypes = map[string]string{"computed": tt.columnType}
+			c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{m}}}
+			err := c.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errContains)
+		})
+	}
+}
+
+func TestValidate_Explode(t *testing.T) {
+	mapping := CollectionTableMapping{
+		TableName:        "order_items",
+		Explode:          "items",
+		PrimaryKeyFields: []string{"order_id", "item_index"},
+		FieldMappings: map[string]string{
+			"order_id": "_key", "item_index": "_index", "sku": "_element.sku", "item": "_element", "customer": "customerId",
+		},
+	}
+	c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{mapping}}}
+	require.NoError(t, c.Validate())
+	assert.Equal(t, "item_index", mapping.IndexColumn())
+	assert.Equal(t, []string{"order_id"}, mapping.ParentKeyFields())
+
+	tests := []struct {
+		name        string
+		mutate      func(m *CollectionTableMapping)
+		errContains string
+	}{
+		{name: "element source without explode", mutate: func(m *CollectionTableMapping) { m.Explode = "" }, errContains: "requires explode"},
+		{
+			name: "no index column",
+			mutate: func(m *CollectionTableMapping) {
+				m.FieldMappings = map[string]string{"order_id": "_key", "item_index": "position", "sku": "_element.sku"}
+			},
+			errContains: "exactly one column mapped from _index",
+		},
+		{
+			name:        "index not last key column",
+			mutate:      func(m *CollectionTableMapping) { m.PrimaryKeyFields = []string{"item_index", "order_id"} },
+			errContains: "primaryKeyFields ending with the index column item_index",
+		},
+		{
+			name:        "element in parent key",
+			mutate:      func(m *CollectionTableMapping) { m.PrimaryKeyFields = []string{"order_id", "sku", "item_index"} },
+			errContains: "primary key column sku of exploded table order_items must identify the parent document",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mapping
+			tt.mutate(&m)
+			c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{m}}}
+			err := c.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errContains)
+		})
+	}
+}
+
+func TestCollectionTableMapping_UnmarshalDeleteMode(t *testing.T) {
+	var both, separate CollectionTableMapping
+	require.NoError(t, yaml.Unmarshal([]byte(`deleteMode: Soft`), &both))
+	require.NoError(t, yaml.Unmarshal([]byte(`
+deleteMode: {deletion: hard, expiration: soft}
+softDelete:
+  deletedAtColumn: removed_at
+  columns: {status: EXPIRED}
+`), &separate))
+
+	c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{both, separate, {}}}}
+	c.ApplyDefaults()
+	both, separate = c.Cassandra.CollectionTableMapping[0], c.Cassandra.CollectionTableMapping[1]
+
+	assert.Equal(t, DeleteMode{Deletion: DeleteModeSoft, Expiration: DeleteModeSoft}, both.DeleteMode)
+	assert.Equal(t, DefaultDeletedAtColumn, both.SoftDelete.DeletedAtColumn)
+	assert.Equal(t, DeleteModeHard, separate.DeleteMode.For(false))
+	assert.Equal(t, DeleteModeSoft, separate.DeleteMode.For(true))
+	assert.Equal(t, []string{"removed_at", "status"}, separate.SoftDeleteColumns())
+	assert.Equal(t, DeleteModeHard, c.Cassandra.CollectionTableMapping[2].DeleteMode.For(true), "hard by default")
+	assert.Nil(t, c.Cassandra.CollectionTableMapping[2].SoftDeleteColumns())
+}
+
+func TestValidate_DeleteMode(t *testing.T) {
+	mapping := CollectionTableMapping{
+		TableName:        "audit",
+		PrimaryKeyFields: []string{"id"},
+		FieldMappings:    map[string]string{"id": "_key"},
+		DeleteMode:       DeleteMode{Deletion: DeleteModeIgnore, Expiration: DeleteModeSoft},
+		SoftDelete:       SoftDelete{DeletedAtColumn: "deleted_at"},
+	}
+	c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{mapping}}}
+	require.NoError(t, c.Validate())
+
+	tests := []struct {
+		name        string
+		mutate      func(m *CollectionTableMapping)
+		errContains string
+	}{
+		{
+			name:        "unknown mode",
+			mutate:      func(m *CollectionTableMapping) { m.DeleteMode.Deletion = "archive" },
+			errContains: `invalid deleteMode "archive"`,
+		},
+		{name: "no primary key", mutate: func(m *CollectionTableMapping) { m.PrimaryKeyFields = nil }, errContains: "requires primaryKeyFields"},
+		{
+			name:        "key column",
+			mutate:      func(m *CollectionTableMapping) { m.SoftDelete.Columns = map[string]interface{}{"id": "x"} },
+			errContains: "softDelete column id of table audit is a primary key column",
+		},
+		{name: "empty column", mutate: func(m *CollectionTableMapping) { m.SoftDelete.DeletedAtColumn = "" }, errContains: "must not be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mapping
+			tt.mutate(&m)
+			c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{m}}}
+			err := c.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errContains)
+		})
+	}
+}
+
+func TestValidate_DeleteModeDocumentPlaceholders(t *testing.T) {
+	ignored := DeleteMode{Deletion: DeleteModeIgnore, Expiration: DeleteModeIgnore}
+	tests := []struct {
+		name    string
+		mapping CollectionTableMapping
+		err     string
+	}{
+		{
+			name:    "keyspace from the key",
+			mapping: CollectionTableMapping{TableName: "orders", Keyspace: "tenant_{_keyPrefix}"},
+		},
+		{
+			name:    "table from the collection",
+			mapping: CollectionTableMapping{TableName: "orders_{_collection}", Keyspace: "{_scope}"},
+		},
+		{
+			name:    "keyspace from the document",
+			mapping: CollectionTableMapping{TableName: "orders", Keyspace: "region_{meta.region}"},
+			err:     "table orders cannot resolve placeholder {meta.region} for deletions and expirations",
+		},
+		{
+			name:    "table from the document, soft deleted",
+			mapping: CollectionTableMapping{TableName: "orders_{region}", DeleteMode: DeleteMode{Deletion: DeleteModeIgnore}},
+			err:     "cannot resolve placeholder {region} for deletions and expirations",
+		},
+		{
+			name:    "deletes ignored",
+			mapping: CollectionTableMapping{TableName: "orders", Keyspace: "region_{meta.region}", DeleteMode: ignored},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mapping.FieldMappings = map[string]string{"id": "_key"}
+			err := validateDeleteMode(tt.mapping)
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestCollectionTableMapping_CollectionPattern(t *testing.T) {
+	tests := []struct {
+		mapping CollectionTableMapping
+		matches []string
+		misses  []string
+	}{
+		{
+			mapping: CollectionTableMapping{Collection: "orders"},
+			matches: []string{"_default.orders", "tenant_a.orders", ".orders"},
+			misses:  []string{"_default.orders_v2", "_default.users"},
+		},
+		{
+			mapping: CollectionTableMapping{Collection: "tenant_*.orders"},
+			matches: []string{"tenant_a.orders", "tenant_.orders"},
+			misses:  []string{"_default.orders", "tenant_a.users", "tenant_a.b.orders"},
+		},
+		{
+			mapping: CollectionTableMapping{Collection: "inventory.item?"},
+			matches: []string{"inventory.items", "inventory.item1"},
+			misses:  []string{"inventory.item", "inventory.items2"},
+		},
+		{
+			mapping: CollectionTableMapping{CollectionRegex: `(?P<tenant>[a-z]+)_(prod|test)\.orders`},
+			matches: []string{"acme_prod.orders", "acme_test.orders"},
+			misses:  []string{"acme_dev.orders", "x.acme_prod.orders"},
+		},
+	}
+	for _, tt := range tests {
+		pattern := regexp.MustCompile(tt.mapping.CollectionPattern())
+		for _, name := range tt.matches {
+			assert.True(t, pattern.MatchString(name), "%s should match %s", pattern, name)
+		}
+		for _, name := range tt.misses {
+			assert.False(t, pattern.MatchString(name), "%s should not match %s", pattern, name)
+		}
+	}
+
+	assert.Empty(t, CollectionTableMapping{}.CollectionPattern())
+	assert.True(t, CollectionTableMapping{Collection: "_default"}.IsDefaultCollectionMapping())
+	assert.False(t, CollectionTableMapping{CollectionRegex: "_default"}.IsDefaultCollectionMapping())
+}
+
+func TestValidate_Collection(t *testing.T) {
+	mapping := CollectionTableMapping{
+		Collection:    "tenant_*.orders",
+		TableName:     "orders_{_match.1}",
+		Keyspace:      "{_scope}",
+		FieldMappings: map[string]string{"id": "_key"},
+	}
+	c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{mapping}}}
+	require.NoError(t, c.Validate())
+	assert.False(t, mapping.StaticTable())
+
+	tests := []struct {
+		name        string
+		mutate      func(m *CollectionTableMapping)
+		errContains string
+	}{
+		{
+			name:        "collection and regex",
+			mutate:      func(m *CollectionTableMapping) { m.CollectionRegex = "x" },
+			errContains: "both collection and collectionRegex",
+		},
+		{
+			name:        "invalid regex",
+			mutate:      func(m *CollectionTableMapping) { m.Collection, m.CollectionRegex = "", "(" },
+			errContains: "invalid collectionRegex",
+		},
+		{
+			name:        "unknown group number",
+			mutate:      func(m *CollectionTableMapping) { m.TableName = "orders_{_match.2}" },
+			errContains: `has no group "2"`,
+		},
+		{
+			name:        "unknown group name",
+			mutate:      func(m *CollectionTableMapping) { m.Keyspace = "{_match.tenant}" },
+			errContains: `has no group "tenant"`,
+		},
+		{
+			name:        "invalid table template",
+			mutate:      func(m *CollectionTableMapping) { m.TableName = "orders-{_scope}" },
+			errContains: `invalid tableName "orders-{_scope}"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mapping
+			tt.mutate(&m)
+			c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{m}}}
+			err := c.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errContains)
+		})
+	}
+}
+
+func TestValidate_InvalidDocumentPolicy(t *testing.T) {
+	c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{
+		{TableName: "orders", FieldMappings: map[string]string{"id": "_key"}},
+		{TableName: "orders_dlq", FieldMappings: map[string]string{"id": "_key"}, InvalidDocumentPolicy: " DeadLetter "},
+	}}}
+	c.ApplyDefaults()
+	assert.Equal(t, InvalidDocumentFail, c.Cassandra.CollectionTableMapping[0].InvalidDocumentPolicy)
+	assert.Equal(t, InvalidDocumentDeadLetter, c.Cassandra.CollectionTableMapping[1].InvalidDocumentPolicy)
+
+	mapping := CollectionTableMapping{
+		TableName:             "orders_{_scope}",
+		PrimaryKeyFields:      []string{"id", "tenant"},
+		FieldMappings:         map[string]string{"id": "_key", "tenant": "_key.tenant", "body": "documentData", "status": "status"},
+		KeyPattern:            `^(?P<tenant>[^:]+):`,
+		InvalidDocumentPolicy: InvalidDocumentRaw,
+	}
+	c = &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{mapping}}}
+	require.NoError(t, c.Validate())
+
+	tests := []struct {
+		name        string
+		mutate      func(m *CollectionTableMapping)
+		errContains string
+	}{
+		{
+			name:        "unknown policy",
+			mutate:      func(m *CollectionTableMapping) { m.InvalidDocumentPolicy = "drop" },
+			errContains: `invalid invalidDocumentPolicy "drop"`,
+		},
+		{
+			name:        "no dead-letter table",
+			mutate:      func(m *CollectionTableMapping) { m.InvalidDocumentPolicy = InvalidDocumentDeadLetter },
+			errContains: "requires deadLetterTable",
+		},
+		{
+			name: "invalid dead-letter table",
+			mutate: func(m *CollectionTableMapping) {
+				m.InvalidDocumentPolicy, m.DeadLetterTable = InvalidDocumentDeadLetter, "ops.dead-letters"
+			},
+			errContains: `got "ops.dead-letters"`,
+		},
+		{
+			name: "raw without documentData",
+			mutate: func(m *CollectionTableMapping) {
+				m.FieldMappings = map[string]string{"id": "_key", "tenant": "_key.tenant"}
+			},
+			errContains: "requires a documentData column",
+		},
+		{
+			name:        "raw key from body",
+			mutate:      func(m *CollectionTableMapping) { m.PrimaryKeyFields = []string{"id", "status"} },
+			errContains: "requires primary key column status to be read from the key or metadata",
+		},
+		{
+			name: "raw table from body",
+			mutate: func(m *CollectionTableMapping) {
+				m.TableName, m.DeleteMode = "orders_{region}", DeleteMode{Deletion: DeleteModeIgnore, Expiration: DeleteModeIgnore}
+			},
+			errContains: "cannot resolve placeholder {region} without the document",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mapping
+			tt.mutate(&m)
+			c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{m}}}
+			err := c.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errContains)
+		})
+	}
+}
+
+func TestParseCQLType(t *testing.T) {
+	for source, expected := range map[string]string{
+		"text":                           "text",
+		" Int ":                          "int",
+		"list<int>":                      "list<int>",
+		"frozen<set<text>>":              "set<text>",
+		"map<text, frozen<list<int>>>":   "map<text, list<int>>",
+		"map<text,map<int,boolean>>":     "map<text, map<int, boolean>>",
+		"frozen<tuple<int, text, uuid>>": "tuple<int, text, uuid>",
+	} {
+		parsed, err := ParseCQLType(source)
+		require.NoError(t, err, source)
+		assert.Equal(t, expected, parsed.String(), source)
+	}
+
+	for source, errMsg := range map[string]string{
+		"":                  "missing type name",
+		"list":              "list requires type parameters",
+		"tuple":             "tuple requires type parameters",
+		"list<int":          "unbalanced <>",
+		"map<text>":         "map takes 2 type(s)",
+		"frozen<int, text>": "frozen takes one type",
+		"list<int>>":        `unexpected ">"`,
+	} {
+		_, err := ParseCQLType(source)
+		require.Error(t, err, source)
+		assert.Contains(t, err.Error(), errMsg, source)
+	}
+}
+
+func TestValidate_FieldPaths(t *testing.T) {
+	exists := true
+	mapping := CollectionTableMapping{
+		TableName: "orders_{meta['region']}",
+		FieldMappings: map[string]string{
+			"id":    "_key",
+			"sku":   "items[-1].sku",
+			"dot":   `['order.id']`,
+			"skus":  "items[*].sku",
+			"total": `expr(size(items))`,
+		},
+		FieldSpecs: map[string]FieldSpec{
+			"skus": {Source: "items[*].sku", Type: "frozen<list<text>>"},
+		},
+		Filter:     &Filter{Include: []FilterRule{{Field: "$.meta.region", Exists: &exists}}},
+		DeleteMode: DeleteMode{Deletion: DeleteModeIgnore, Expiration: DeleteModeIgnore},
+	}
+	c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{mapping}}}
+	c.ApplyDefaults()
+	require.NoError(t, c.Validate())
+
+	tests := []struct {
+		name        string
+		mutate      func(m *CollectionTableMapping)
+		errContains string
+	}{
+		{
+			name:        "source",
+			mutate:      func(m *CollectionTableMapping) { m.FieldMappings = map[string]string{"sku": "items[0"} },
+			errContains: `invalid source of column sku of table orders_{meta['region']}: expected , or ]`,
+		},
+		{
+			name:        "placeholder",
+			mutate:      func(m *CollectionTableMapping) { m.TableName = "orders_{meta[?(@.x)]}" },
+			errContains: "invalid placeholder {meta[?(@.x)]}",
+		},
+		{
+			name: "filter field",
+			mutate: func(m *CollectionTableMapping) {
+				m.Filter = &Filter{Include: []FilterRule{{Field: "a..", Exists: &exists}}}
+			},
+			errContains: "invalid filter.include[0]",
+		},
+		{
+			name: "collection type",
+			mutate: func(m *CollectionTableMapping) {
+				m.FieldSpecs = map[string]FieldSpec{"skus": {Source: "items[*].sku", Type: "list<geo>"}}
+			},
+			errContains: `unsupported type "list<geo>"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mapping
+			m.ColumnTypes = nil
+			tt.mutate(&m)
+			c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{m}}}
+			c.ApplyDefaults()
+			err := c.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errContains)
+		})
+	}
+}
+
+func TestValidate_UserTypes(t *testing.T) {
+	mapping := CollectionTableMapping{
+		TableName:     "orders",
+		FieldMappings: map[string]string{"id": "_key"},
+		FieldSpecs: map[string]FieldSpec{
+			"address": {Source: "address", Type: "frozen<Address>"},
+			"stops":   {Source: "stops", Type: "list<frozen<tuple<int, point>>>"},
+		},
+		UserTypes: map[string]map[string]string{
+			"Address": {"street": "TEXT", " geo ": "frozen<point>"},
+			"point":   {"lat": "double", "lon": "double"},
+		},
+	}
+	c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{mapping}}}
+	c.ApplyDefaults()
+	require.NoError(t, c.Validate())
+	assert.Equal(t, map[string]string{"street": "text", "geo": "frozen<point>"},
+		c.Cassandra.CollectionTableMapping[0].UserTypes["address"])
+
+	tests := []struct {
+		userTypes   map[string]map[string]string
+		name        string
+		specType    string
+		errContains string
+	}{
+		{
+			name:        "native name",
+			userTypes:   map[string]map[string]string{"text": {"a": "int"}},
+			errContains: "invalid name of user type text of table orders",
+		},
+		{
+			name:        "no fields",
+			userTypes:   map[string]map[string]string{"point": {}},
+			errContains: "user type point of table orders has no fields",
+		},
+		{
+			name:        "invalid field type",
+			userTypes:   map[string]map[string]string{"point": {"lat": "list<double"}},
+			errContains: `field lat of user type point of table orders: invalid type "list<double"`,
+		},
+		{
+			name:        "undeclared",
+			userTypes:   map[string]map[string]string{"point": {"lat": "frozen<coordinate>"}},
+			errContains: "field lat of user type point of table orders refers to undeclared user type coordinate",
+		},
+		{
+			name: "recursive",
+			userTypes: map[string]map[string]string{
+				"point": {"next": "frozen<step>"},
+				"step":  {"points": "list<frozen<point>>"},
+			},
+			errContains: "user type point of table orders contains itself",
+		},
+		{
+			name:        "spec type not declared",
+			userTypes:   map[string]map[string]string{"point": {"lat": "double"}},
+			specType:    "frozen<address>",
+			errContains: `unsupported type "frozen<address>" for field address of table orders`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mapping
+			m.FieldMappings = map[string]string{"id": "_key"}
+			m.ColumnTypes = nil
+			m.FieldSpecs = nil
+			if tt.specType != "" {
+				m.FieldSpecs = map[string]FieldSpec{"address": {Source: "address", Type: tt.specType}}
+			}
+			m.UserTypes = tt.userTypes
+			c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{m}}}
+			c.ApplyDefaults()
+			err := c.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errContains)
+		})
+	}
+}
+
+func TestValidate_MissingFieldPolicy(t *testing.T) {
+	mapping := CollectionTableMapping{
+		TableName:          "orders",
+		MissingFieldPolicy: " Unset",
+		FieldMappings:      map[string]string{"id": "_key", "note": "note"},
+		FieldSpecs: map[string]FieldSpec{
+			"status":   {Source: "status", Default: "new"},
+			"amount":   {Source: "amount", Required: true},
+			"region":   {Source: "region", MissingFieldPolicy: "NULL"},
+			"currency": {Source: "currency", Default: "EUR", MissingFieldPolicy: "unset"},
+		},
+	}
+	c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{mapping}}}
+	c.ApplyDefaults()
+	require.NoError(t, c.Validate())
+	m := c.Cassandra.CollectionTableMapping[0]
+	for column, policy := range map[string]string{
+		"note":     MissingFieldUnset,
+		"status":   MissingFieldDefault,
+		"amount":   MissingFieldError,
+		"region":   MissingFieldNull,
+		"currency": MissingFieldUnset,
+	} {
+		assert.Equal(t, policy, m.MissingFieldPolicyOf(column), column)
+	}
+	assert.Equal(t, MissingFieldNull, CollectionTableMapping{}.MissingFieldPolicyOf("note"), "null without defaults applied")
+
+	tests := []struct {
+		specs       map[string]FieldSpec
+		name        string
+		policy      string
+		errContains string
+	}{
+		{name: "mapping", policy: "tombstone", errContains: `invalid missingFieldPolicy "tombstone" for table orders`},
+		{
+			name:        "spec",
+			specs:       map[string]FieldSpec{"note": {Source: "note", MissingFieldPolicy: "skip"}},
+			errContains: `invalid missingFieldPolicy "skip" for field note of table orders`,
+		},
+		{
+			name:        "default without default",
+			specs:       map[string]FieldSpec{"note": {Source: "note", MissingFieldPolicy: "default"}},
+			errContains: "missingFieldPolicy default requires a default for field note of table orders",
+		},
+		{
+			name:        "required",
+			specs:       map[string]FieldSpec{"note": {Source: "note", Required: true, MissingFieldPolicy: "unset"}},
+			errContains: "field note of table orders cannot be required with missingFieldPolicy unset",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := CollectionTableMapping{
+				TableName:          "orders",
+				MissingFieldPolicy: tt.policy,
+				FieldMappings:      map[string]string{"id": "_key", "note": "note"},
+				FieldSpecs:         tt.specs,
+			}
+			c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{m}}}
+			c.ApplyDefaults()
+			err := c.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errContains)
+		})
+	}
+}
+
+func TestValidate_WriteMode(t *testing.T) {
+	mapping := CollectionTableMapping{
+		TableName:        "orders",
+		WriteMode:        "UpdateExisting",
+		IfExists:         true,
+		PrimaryKeyFields: []string{"id"},
+		FieldMappings:    map[string]string{"id": "_key", "status": "status"},
+	}
+	c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{mapping, {
+		TableName:     "orders_log",
+		FieldMappings: map[string]string{"id": "_key"},
+	}}}}
+	c.ApplyDefaults()
+	require.NoError(t, c.Validate())
+	assert.Equal(t, WriteModeUpdateExisting, c.Cassandra.CollectionTableMapping[0].WriteMode)
+	assert.Equal(t, WriteModeUpsert, c.Cassandra.CollectionTableMapping[1].WriteMode)
+
+	tests := []struct {
+		mutate      func(m *CollectionTableMapping)
+		name        string
+		errContains string
+	}{
+		{
+			name:        "invalid",
+			mutate:      func(m *CollectionTableMapping) { m.WriteMode = "replace" },
+			errContains: `invalid writeMode "replace" for table orders`,
+		},
+		{
+			name:        "update without primary key",
+			mutate:      func(m *CollectionTableMapping) { m.PrimaryKeyFields = nil },
+			errContains: "writeMode updateExisting of table orders requires primaryKeyFields",
+		},
+		{
+			name:        "update without columns",
+			mutate:      func(m *CollectionTableMapping) { m.PrimaryKeyFields = []string{"id", "status"} },
+			errContains: "writeMode updateExisting of table orders requires a column outside primaryKeyFields",
+		},
+		{
+			name:        "ifExists",
+			mutate:      func(m *CollectionTableMapping) { m.WriteMode = WriteModeInsertIfNotExists },
+			errContains: "ifExists of table orders requires writeMode updateExisting",
+		},
+		{
+			name: "explode",
+			mutate: func(m *CollectionTableMapping) {
+				m.WriteMode, m.IfExists = WriteModeInsertIfNotExists, false
+				m.Explode = "items"
+				m.PrimaryKeyFields = []string{"id", "idx"}
+				m.FieldMappings = map[string]string{"id": "_key", "idx": "_index"}
+			},
+			errContains: "writeMode insertIfNotExists cannot be combined with explode for table orders",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mapping
+			tt.mutate(&m)
+			c := &Connector{Cassandra: Cassandra{CollectionTableMapping: []CollectionTableMapping{m}}}
+			c.ApplyDefaults()
+			err := c.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errContains)
+		})
+	}
+}
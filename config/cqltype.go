@@ -0,0 +1,120 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CQLType is a parsed CQL column type such as map<text, frozen<list<int>>>.
+// Frozen types are unwrapped: frozen only changes how Cassandra stores a
+// value, not what the mapper writes.
+type CQLType struct {
+	// Name is the lower-case type name, e.g. list or text.
+	Name   string
+	Params []CQLType
+}
+
+// collectionParams is the number of parameters of each collection type.
+var collectionParams = map[string]int{"list": 1, "set": 1, "map": 2}
+
+// nativeCQLTypes are the type names that are not user-defined types.
+var nativeCQLTypes = map[string]bool{
+	"ascii": true, "bigint": true, "blob": true, "boolean": true, "counter": true, "date": true,
+	"decimal": true, "double": true, "duration": true, "float": true, "inet": true, "int": true,
+	"smallint": true, "text": true, "time": true, "timestamp": true, "timeuuid": true, "tinyint": true,
+	"uuid": true, "varchar": true, "varint": true,
+	"list": true, "set": true, "map": true, "tuple": true, "frozen": true, "vector": true,
+}
+
+// ParseCQLType parses a column type as system_schema.columns reports it.
+func ParseCQLType(source string) (CQLType, error) {
+	t, rest, err := parseCQLType(source)
+	if err == nil && strings.TrimSpace(rest) != "" {
+		err = fmt.Errorf("unexpected %q", strings.TrimSpace(rest))
+	}
+	if err != nil {
+		return CQLType{}, fmt.Errorf("invalid type %q: %w", source, err)
+	}
+	return t, nil
+}
+
+func parseCQLType(source string) (CQLType, string, error) {
+	rest := strings.TrimSpace(source)
+	end := strings.IndexAny(rest, "<>,")
+	if end < 0 {
+		end = len(rest)
+	}
+	t := CQLType{Name: strings.ToLower(strings.TrimSpace(rest[:end]))}
+	rest = rest[end:]
+	if t.Name == "" {
+		return CQLType{}, "", errors.New("missing type name")
+	}
+	if !strings.HasPrefix(rest, "<") {
+		if _, ok := collectionParams[t.Name]; ok || t.Name == "frozen" || t.Name == "tuple" {
+			return CQLType{}, "", fmt.Errorf("%s requires type parameters", t.Name)
+		}
+		return t, rest, nil
+	}
+
+	rest = rest[1:]
+	for closed := false; !closed; {
+		param, after, err := parseCQLType(rest)
+		if err != nil {
+			return CQLType{}, "", err
+		}
+		t.Params = append(t.Params, param)
+		after = strings.TrimSpace(after)
+		if !strings.HasPrefix(after, ",") && !strings.HasPrefix(after, ">") {
+			return CQLType{}, "", errors.New("unbalanced <>")
+		}
+		closed = after[0] == '>'
+		rest = after[1:]
+	}
+
+	if t.Name == "frozen" {
+		if len(t.Params) != 1 {
+			return CQLType{}, "", errors.New("frozen takes one type")
+		}
+		return t.Params[0], rest, nil
+	}
+	if want, ok := collectionParams[t.Name]; ok && len(t.Params) != want {
+		return CQLType{}, "", fmt.Errorf("%s takes %d type(s)", t.Name, want)
+	}
+	return t, rest, nil
+}
+
+// IsUserType reports whether t names a user-defined type.
+func (t CQLType) IsUserType() bool {
+	return !nativeCQLTypes[t.Name]
+}
+
+// UserTypeNames returns the names of the user-defined types t refers to,
+// including those nested in collections and tuples.
+func (t CQLType) UserTypeNames() []string {
+	if t.IsUserType() {
+		return []string{t.Name}
+	}
+	var names []string
+	for _, param := range t.Params {
+		names = append(names, param.UserTypeNames()...)
+	}
+	return names
+}
+
+// IsCollection reports whether t is a list, set or map.
+func (t CQLType) IsCollection() bool {
+	_, ok := collectionParams[t.Name]
+	return ok
+}
+
+func (t CQLType) String() string {
+	if len(t.Params) == 0 {
+		return t.Name
+	}
+	params := make([]string, len(t.Params))
+	for i, param := range t.Params {
+		params[i] = param.String()
+	}
+	return t.Name + "<" + strings.Join(params, ", ") + ">"
+}
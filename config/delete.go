@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Delete modes of deletions and expirations.
+const (
+	// DeleteModeHard deletes the row.
+	DeleteModeHard = "hard"
+	// DeleteModeSoft updates the row's softDelete columns instead.
+	DeleteModeSoft = "soft"
+	// DeleteModeIgnore acks the event without writing.
+	DeleteModeIgnore = "ignore"
+)
+
+// DefaultDeletedAtColumn is the column soft deletes set to the event time.
+const DefaultDeletedAtColumn = "deleted_at"
+
+var validDeleteModes = map[string]bool{DeleteModeHard: true, DeleteModeSoft: true, DeleteModeIgnore: true}
+
+// DeleteMode chooses how deletions and expirations are written. In YAML it
+// is either one mode for both, `deleteMode: soft`, or a mode per event,
+// `deleteMode: {deletion: hard, expiration: soft}`.
+type DeleteMode struct {
+	Deletion   string `yaml:"deletion,omitempty"`
+	Expiration string `yaml:"expiration,omitempty"`
+}
+
+func (d *DeleteMode) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		d.Deletion, d.Expiration = value.Value, value.Value
+		return nil
+	}
+	type plain DeleteMode
+	return value.Decode((*plain)(d))
+}
+
+// For returns the mode of expirations when expired is true and of
+// deletions otherwise. An unset mode is DeleteModeHard.
+func (d DeleteMode) For(expired bool) string {
+	mode := d.Deletion
+	if expired {
+		mode = d.Expiration
+	}
+	if mode == "" {
+		return DeleteModeHard
+	}
+	return mode
+}
+
+// Uses reports whether deletions or expirations use mode.
+func (d DeleteMode) Uses(mode string) bool {
+	return d.For(false) == mode || d.For(true) == mode
+}
+
+// SoftDelete configures the UPDATE written by DeleteModeSoft.
+type SoftDelete struct {
+	// Columns are set to fixed values, e.g. status: DELETED.
+	Columns map[string]interface{} `yaml:"columns,omitempty"`
+	// DeletedAtColumn is set to the event time. Defaults to deleted_at.
+	DeletedAtColumn string `yaml:"deletedAtColumn,omitempty"`
+}
+
+// SoftDeleteColumns returns the columns a soft delete sets, sorted, or nil
+// when the mapping does not soft delete.
+func (m CollectionTableMapping) SoftDeleteColumns() []string {
+	if !m.DeleteMode.Uses(DeleteModeSoft) {
+		return nil
+	}
+	columns := []string{m.SoftDelete.DeletedAtColumn}
+	for column := range m.SoftDelete.Columns {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+func (m *CollectionTableMapping) setDeleteModeDefaults() {
+	m.DeleteMode.Deletion = strings.TrimSpace(strings.ToLower(m.DeleteMode.Deletion))
+	m.DeleteMode.Expiration = strings.TrimSpace(strings.ToLower(m.DeleteMode.Expiration))
+	if m.DeleteMode.Uses(DeleteModeSoft) && m.SoftDelete.DeletedAtColumn == "" {
+		m.SoftDelete.DeletedAtColumn = DefaultDeletedAtColumn
+	}
+}
+
+func validateDeleteMode(m CollectionTableMapping) error {
+	for _, mode := range []string{m.DeleteMode.Deletion, m.DeleteMode.Expiration} {
+		if mode != "" && !validDeleteModes[mode] {
+			return fmt.Errorf("invalid deleteMode %q for table %s: use hard, soft or ignore", mode, m.TableName)
+		}
+	}
+	// Deletions and expirations carry no body, so the keyspace and table of
+	// their row can only be resolved from the event.
+	if m.DeleteMode.For(false) != DeleteModeIgnore || m.DeleteMode.For(true) != DeleteModeIgnore {
+		if placeholder, ok := documentPlaceholder(m); ok {
+			return fmt.Errorf("table %s cannot resolve placeholder {%s} for deletions and expirations, which carry no document: "+
+				"use a key or collection placeholder, or deleteMode ignore", m.TableName, placeholder)
+		}
+	}
+	if !m.DeleteMode.Uses(DeleteModeSoft) {
+		return nil
+	}
+
+	// An UPDATE needs the full primary key, which only primaryKeyFields
+	// guarantees; exploded rows are only known by their parent key.
+	if len(m.PrimaryKeyFields) == 0 {
+		return fmt.Errorf("soft deleteMode of table %s requires primaryKeyFields", m.TableName)
+	}
+	if m.Explode != "" {
+		return fmt.Errorf("soft deleteMode of table %s cannot be combined with explode", m.TableName)
+	}
+	if m.SoftDelete.DeletedAtColumn == "" {
+		return fmt.Errorf("softDelete.deletedAtColumn of table %s must not be empty", m.TableName)
+	}
+	for _, column := range m.SoftDeleteColumns() {
+		for _, pk := range m.PrimaryKeyFields {
+			if column == pk {
+				return fmt.Errorf("softDelete column %s of table %s is a primary key column", column, m.TableName)
+			}
+		}
+	}
+	return nil
+}
+
+// documentPlaceholder returns the first placeholder of the mapping's
+// tableName or keyspace that reads the document body.
+func documentPlaceholder(m CollectionTableMapping) (string, bool) {
+	for _, name := range []string{m.TableName, m.Keyspace} {
+		for _, match := range keyspacePlaceholderPattern.FindAllStringSubmatch(name, -1) {
+			if !isEventPlaceholder(match[1]) {
+				return match[1], true
+			}
+		}
+	}
+	return "", false
+}
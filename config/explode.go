@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Sources of exploded mappings. _element is the array element and
+// _element.<path> or _element[...] a path into it; _index is the element's position and
+// must be the last primary key column, so the rows of elements that no
+// longer exist can be removed with one range delete.
+const (
+	SourceElement       = "_element"
+	ElementSourcePrefix = "_element."
+	SourceIndex         = "_index"
+)
+
+// IsElementSource reports whether source reads the exploded array element
+// or its index rather than the parent document.
+func IsElementSource(source string) bool {
+	return source == SourceElement || source == SourceIndex || strings.HasPrefix(source, ElementSourcePrefix) ||
+		strings.HasPrefix(source, SourceElement+"[")
+}
+
+// IndexColumn returns the column mapped from _index, or "" if there is none.
+func (m CollectionTableMapping) IndexColumn() string {
+	for column, source := range m.FieldMappings {
+		if source == SourceIndex {
+			return column
+		}
+	}
+	return ""
+}
+
+// ParentKeyFields returns the primary key columns that identify the parent
+// document of an exploded mapping: every primary key column but the index.
+func (m CollectionTableMapping) ParentKeyFields() []string {
+	if len(m.PrimaryKeyFields) == 0 {
+		return nil
+	}
+	return m.PrimaryKeyFields[:len(m.PrimaryKeyFields)-1]
+}
+
+func validateExplode(m CollectionTableMapping) error {
+	indexColumns := 0
+	for column, source := range m.FieldMappings {
+		if !IsElementSource(source) {
+			continue
+		}
+		if m.Explode == "" {
+			return fmt.Errorf("source %s of column %s requires explode for table %s", source, column, m.TableName)
+		}
+		if source == SourceIndex {
+			indexColumns++
+		}
+	}
+	if m.Explode == "" {
+		return nil
+	}
+
+	if indexColumns != 1 {
+		return fmt.Errorf("explode of table %s requires exactly one column mapped from %s", m.TableName, SourceIndex)
+	}
+	index := m.IndexColumn()
+	if len(m.PrimaryKeyFields) < 2 || m.PrimaryKeyFields[len(m.PrimaryKeyFields)-1] != index {
+		return fmt.Errorf("explode of table %s requires primaryKeyFields ending with the index column %s", m.TableName, index)
+	}
+	for _, pk := range m.ParentKeyFields() {
+		if source := m.FieldMappings[pk]; IsElementSource(source) || source == "documentData" {
+			return fmt.Errorf("primary key column %s of exploded table %s must identify the parent document", pk, m.TableName)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Trendyol/go-dcp-cassandra/expr"
+)
+
+// expressionDeclarations are the static types of the variables an
+// expression can use besides document fields.
+var expressionDeclarations = map[string]expr.Type{
+	"_key":           expr.String,
+	SourceCas:        expr.Int,
+	SourceVbID:       expr.Int,
+	SourceEventTime:  expr.Timestamp,
+	SourceCollection: expr.String,
+	SourceScope:      expr.String,
+	SourceRevSeqNo:   expr.Int,
+	SourceExpiry:     expr.Dyn,
+	SourceOperation:  expr.String,
+}
+
+// expressionColumnTypes restricts the column types of expressions whose
+// result type is known and cannot be converted to every column type.
+var expressionColumnTypes = map[expr.Type]map[string]bool{
+	expr.Bool: {"boolean": true, "text": true, "varchar": true, "ascii": true},
+	expr.Timestamp: {
+		"timestamp": true, "date": true, "bigint": true, "text": true, "varchar": true, "ascii": true,
+	},
+}
+
+// ExpressionSource returns the expression of a computed column source,
+// written expr("...") or expr(...), and whether source is one.
+func ExpressionSource(source string) (string, bool) {
+	source = strings.TrimSpace(source)
+	if !strings.HasPrefix(source, "expr(") || !strings.HasSuffix(source, ")") {
+		return "", false
+	}
+	expression := strings.TrimSpace(source[len("expr(") : len(source)-1])
+	if unquoted, err := strconv.Unquote(expression); err == nil && strings.HasPrefix(expression, `"`) {
+		return unquoted, true
+	}
+	return expression, true
+}
+
+// IsExpressionSource reports whether source is a computed column.
+func IsExpressionSource(source string) bool {
+	_, ok := ExpressionSource(source)
+	return ok
+}
+
+// CompileExpression compiles a computed column expression with _key and the
+// metadata sources declared.
+func CompileExpression(expression string) (*expr.Program, error) {
+	return expr.Compile(expression, expressionDeclarations)
+}
+
+func validateExpressions(m CollectionTableMapping) error {
+	for column, source := range m.FieldMappings {
+		expression, ok := ExpressionSource(source)
+		if !ok {
+			continue
+		}
+		program, err := CompileExpression(expression)
+		if err != nil {
+			return fmt.Errorf("invalid expression for column %s of table %s: %w", column, m.TableName, err)
+		}
+		columnType := strings.ToLower(strings.TrimSpace(m.ColumnTypes[column]))
+		allowed, restricted := expressionColumnTypes[program.Type()]
+		if restricted && columnType != "" && !allowed[columnType] {
+			return fmt.Errorf("expression for column %s of table %s is %s and cannot be written to a %s column",
+				column, m.TableName, program.Type(), columnType)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,327 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FieldSpec is the structured form of a fieldMappings entry. The string
+// shorthand `column: source` is equivalent to a spec with only Source set.
+type FieldSpec struct {
+	// Default is written when the source field is missing, or when it is
+	// null and NullPolicy is NullPolicyDefault.
+	Default interface{} `yaml:"default,omitempty"`
+	Source  string      `yaml:"source"`
+	// Type is the CQL type of the column. It is copied to columnTypes.
+	Type string `yaml:"type,omitempty"`
+	// Format is the layout of timestamp and date strings: a name such as
+	// RFC3339, a Go reference layout, or epochSeconds/epochMillis for
+	// numeric values.
+	Format     string `yaml:"format,omitempty"`
+	NullPolicy string `yaml:"nullPolicy,omitempty"`
+	// MissingFieldPolicy overrides the mapping's missingFieldPolicy for
+	// this column. It defaults to error for required fields and to default
+	// for fields with a default.
+	MissingFieldPolicy string `yaml:"missingFieldPolicy,omitempty"`
+	// Required makes a document without the source field a mapping error.
+	// It is the same as MissingFieldPolicy error.
+	Required bool `yaml:"required,omitempty"`
+}
+
+// Metadata sources map DCP event metadata instead of a document field.
+const (
+	SourceCas        = "_cas"
+	SourceVbID       = "_vbId"
+	SourceEventTime  = "_eventTime"
+	SourceCollection = "_collection"
+	SourceScope      = "_scope"
+	SourceRevSeqNo   = "_revSeqNo"
+	SourceExpiry     = "_expiry"
+	SourceOperation  = "_operation"
+)
+
+var metadataSources = map[string]bool{
+	SourceCas: true, SourceVbID: true, SourceEventTime: true, SourceCollection: true,
+	SourceScope: true, SourceRevSeqNo: true, SourceExpiry: true, SourceOperation: true,
+}
+
+// IsMetadataSource reports whether source is one of the metadata sources.
+func IsMetadataSource(source string) bool {
+	return metadataSources[source]
+}
+
+const (
+	NullPolicyNull    = "null"
+	NullPolicyDefault = "default"
+	NullPolicyError   = "error"
+)
+
+// Missing field policies choose what an upsert writes for a column whose
+// source field is missing from the document.
+const (
+	// MissingFieldNull writes null, a tombstone in Cassandra.
+	MissingFieldNull = "null"
+	// MissingFieldUnset leaves the column as it is.
+	MissingFieldUnset = "unset"
+	// MissingFieldDefault writes the field spec default, or null for
+	// columns without one.
+	MissingFieldDefault = "default"
+	// MissingFieldError makes the mapper panic.
+	MissingFieldError = "error"
+)
+
+const (
+	TimeFormatEpochSeconds = "epochSeconds"
+	TimeFormatEpochMillis  = "epochMillis"
+)
+
+var (
+	namedTimeLayouts = map[string]string{
+		"RFC3339":     time.RFC3339,
+		"RFC3339Nano": time.RFC3339Nano,
+		"RFC1123":     time.RFC1123,
+		"RFC1123Z":    time.RFC1123Z,
+		"RFC822":      time.RFC822,
+		"RFC822Z":     time.RFC822Z,
+		"ANSIC":       time.ANSIC,
+		"UnixDate":    time.UnixDate,
+		"DateTime":    time.DateTime,
+		"DateOnly":    time.DateOnly,
+	}
+	// fieldSpecTypes are the CQL types the default mapper can convert to.
+	fieldSpecTypes = map[string]bool{
+		"text": true, "varchar": true, "ascii": true, "inet": true,
+		"int": true, "smallint": true, "tinyint": true, "bigint": true, "counter": true, "varint": true,
+		"float": true, "double": true, "decimal": true, "boolean": true,
+		"timestamp": true, "date": true, "time": true, "duration": true, "uuid": true, "timeuuid": true, "blob": true,
+	}
+	validNullPolicies = map[string]bool{
+		NullPolicyNull: true, NullPolicyDefault: true, NullPolicyError: true,
+	}
+	validMissingFieldPolicies = map[string]bool{
+		MissingFieldNull: true, MissingFieldUnset: true, MissingFieldDefault: true, MissingFieldError: true,
+	}
+	// layoutProbeTime shares no element with the reference time, so any
+	// layout element changes its formatted output.
+	layoutProbeTime = time.Date(2011, time.November, 12, 9, 8, 7, 0, time.UTC)
+)
+
+// TimeLayout returns the Go layout of a timestamp format: the layout of a
+// named format, or the format itself when it is a reference layout.
+func TimeLayout(format string) string {
+	if layout, ok := namedTimeLayouts[format]; ok {
+		return layout
+	}
+	return format
+}
+
+// UnmarshalYAML accepts both forms of fieldMappings entries. Sources end up
+// in FieldMappings either way; structured entries are also kept in
+// FieldSpecs.
+func (m *CollectionTableMapping) UnmarshalYAML(value *yaml.Node) error {
+	type plain CollectionTableMapping
+
+	rest := *value
+	rest.Content = nil
+	var fields *yaml.Node
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		if value.Content[i].Value == "fieldMappings" {
+			fields = value.Content[i+1]
+			continue
+		}
+		rest.Content = append(rest.Content, value.Content[i], value.Content[i+1])
+	}
+	if err := rest.Decode((*plain)(m)); err != nil {
+		return err
+	}
+	if fields == nil {
+		return nil
+	}
+
+	var entries map[string]yaml.Node
+	if err := fields.Decode(&entries); err != nil {
+		return err
+	}
+	m.FieldMappings = make(map[string]string, len(entries))
+	for column, entry := range entries {
+		if entry.Kind == yaml.ScalarNode {
+			m.FieldMappings[column] = entry.Value
+			continue
+		}
+		var spec FieldSpec
+		if err := entry.Decode(&spec); err != nil {
+			return fmt.Errorf("fieldMappings.%s: %w", column, err)
+		}
+		if m.FieldSpecs == nil {
+			m.FieldSpecs = make(map[string]FieldSpec)
+		}
+		m.FieldSpecs[column] = spec
+		m.FieldMappings[column] = spec.Source
+	}
+	return nil
+}
+
+// setFieldSpecDefaults normalizes the specs and makes every spec visible to
+// code that only reads FieldMappings and ColumnTypes.
+func (m *CollectionTableMapping) setFieldSpecDefaults() {
+	for column, spec := range m.FieldSpecs {
+		spec.Type = strings.TrimSpace(strings.ToLower(spec.Type))
+		spec.NullPolicy = strings.TrimSpace(strings.ToLower(spec.NullPolicy))
+		if spec.NullPolicy == "" {
+			spec.NullPolicy = NullPolicyNull
+		}
+		spec.MissingFieldPolicy = normalizeMissingFieldPolicy(spec.MissingFieldPolicy)
+		spec.MissingFieldPolicy = spec.missingFieldPolicy()
+		m.FieldSpecs[column] = spec
+
+		if _, exists := m.FieldMappings[column]; !exists && spec.Source != "" {
+			if m.FieldMappings == nil {
+				m.FieldMappings = make(map[string]string)
+			}
+			m.FieldMappings[column] = spec.Source
+		}
+		if _, declared := m.ColumnTypes[column]; !declared && spec.Type != "" {
+			if m.ColumnTypes == nil {
+				m.ColumnTypes = make(map[string]string)
+			}
+			m.ColumnTypes[column] = spec.Type
+		}
+	}
+}
+
+// MissingFieldPolicyOf returns the missing field policy of column: its
+// field spec's, or else the mapping's.
+func (m CollectionTableMapping) MissingFieldPolicyOf(column string) string {
+	if policy := m.FieldSpecs[column].missingFieldPolicy(); policy != "" {
+		return policy
+	}
+	if m.MissingFieldPolicy == "" {
+		return MissingFieldNull
+	}
+	return m.MissingFieldPolicy
+}
+
+// missingFieldPolicy returns the spec's policy, the one implied by Required
+// or Default, or "" to use the mapping's.
+func (s FieldSpec) missingFieldPolicy() string {
+	switch {
+	case s.MissingFieldPolicy != "":
+		return s.MissingFieldPolicy
+	case s.Required:
+		return MissingFieldError
+	case s.Default != nil:
+		return MissingFieldDefault
+	}
+	return ""
+}
+
+func normalizeMissingFieldPolicy(policy string) string {
+	policy = strings.TrimSpace(policy)
+	for valid := range validMissingFieldPolicies {
+		if strings.EqualFold(policy, valid) {
+			return valid
+		}
+	}
+	return policy
+}
+
+func (m *CollectionTableMapping) setMissingFieldDefaults() {
+	m.MissingFieldPolicy = normalizeMissingFieldPolicy(m.MissingFieldPolicy)
+	if m.MissingFieldPolicy == "" {
+		m.MissingFieldPolicy = MissingFieldNull
+	}
+}
+
+func validateMissingFieldPolicy(m CollectionTableMapping) error {
+	if m.MissingFieldPolicy != "" && !validMissingFieldPolicies[m.MissingFieldPolicy] {
+		return fmt.Errorf("invalid missingFieldPolicy %q for table %s", m.MissingFieldPolicy, m.TableName)
+	}
+	return nil
+}
+
+func validateFieldSpecs(m CollectionTableMapping) error {
+	for column, spec := range m.FieldSpecs {
+		scope := fmt.Sprintf("field %s of table %s", column, m.TableName)
+		if spec.Source == "" {
+			return fmt.Errorf("source is required for %s", scope)
+		}
+		if spec.Source != m.FieldMappings[column] {
+			return fmt.Errorf("source %q of %s conflicts with fieldMappings %q", spec.Source, scope, m.FieldMappings[column])
+		}
+		columnType := strings.ToLower(m.ColumnTypes[column])
+		if spec.Type != "" {
+			if !supportedColumnType(spec.Type, m.UserTypes) {
+				return fmt.Errorf("unsupported type %q for %s", spec.Type, scope)
+			}
+			if columnType != spec.Type {
+				return fmt.Errorf("type %q of %s conflicts with columnTypes %q", spec.Type, scope, columnType)
+			}
+		}
+		if spec.Format != "" {
+			if columnType != "timestamp" && columnType != "date" {
+				return fmt.Errorf("format of %s requires type timestamp or date", scope)
+			}
+			if !validTimeFormat(spec.Format) {
+				return fmt.Errorf("invalid format %q for %s", spec.Format, scope)
+			}
+		}
+		if spec.NullPolicy != "" && !validNullPolicies[spec.NullPolicy] {
+			return fmt.Errorf("invalid nullPolicy %q for %s", spec.NullPolicy, scope)
+		}
+		if spec.NullPolicy == NullPolicyDefault && spec.Default == nil {
+			return fmt.Errorf("nullPolicy default requires a default for %s", scope)
+		}
+		if spec.Required && spec.Default != nil {
+			return fmt.Errorf("%s cannot be both required and have a default", scope)
+		}
+		if spec.MissingFieldPolicy != "" && !validMissingFieldPolicies[spec.MissingFieldPolicy] {
+			return fmt.Errorf("invalid missingFieldPolicy %q for %s", spec.MissingFieldPolicy, scope)
+		}
+		if spec.MissingFieldPolicy == MissingFieldDefault && spec.Default == nil {
+			return fmt.Errorf("missingFieldPolicy default requires a default for %s", scope)
+		}
+		if spec.Required && spec.MissingFieldPolicy != MissingFieldError {
+			return fmt.Errorf("%s cannot be required with missingFieldPolicy %s", scope, spec.MissingFieldPolicy)
+		}
+	}
+	return nil
+}
+
+// supportedColumnType reports whether the default mapper can convert to
+// columnType: a fieldSpecTypes type, a user-defined type of userTypes, or
+// a collection or tuple of them.
+func supportedColumnType(columnType string, userTypes map[string]map[string]string) bool {
+	t, err := ParseCQLType(columnType)
+	return err == nil && supportedCQLType(t, userTypes)
+}
+
+func supportedCQLType(t CQLType, userTypes map[string]map[string]string) bool {
+	if t.IsUserType() {
+		_, declared := userTypes[t.Name]
+		return declared
+	}
+	if !t.IsCollection() && t.Name != "tuple" {
+		return fieldSpecTypes[t.Name]
+	}
+	for _, param := range t.Params {
+		if !supportedCQLType(param, userTypes) {
+			return false
+		}
+	}
+	return true
+}
+
+// validTimeFormat reports whether format is an epoch format, a named layout
+// or a layout containing at least one reference time element.
+func validTimeFormat(format string) bool {
+	if format == TimeFormatEpochSeconds || format == TimeFormatEpochMillis {
+		return true
+	}
+	if _, ok := namedTimeLayouts[format]; ok {
+		return true
+	}
+	return layoutProbeTime.Format(format) != format
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/Trendyol/go-dcp-cassandra/jsonpath"
+)
+
+// Event types, as used by filter rules and the _operation source.
+const (
+	EventMutation   = "mutation"
+	EventDeletion   = "deletion"
+	EventExpiration = "expiration"
+)
+
+var validEvents = map[string]bool{EventMutation: true, EventDeletion: true, EventExpiration: true}
+
+// Filter selects the events a mapping writes. An event passes when it
+// matches any Include rule, or there are none, and no Exclude rule.
+// Events that are filtered out by every mapping are acked without writes.
+type Filter struct {
+	Include []FilterRule `yaml:"include,omitempty"`
+	Exclude []FilterRule `yaml:"exclude,omitempty"`
+}
+
+// FilterRule matches an event when all of its conditions match.
+type FilterRule struct {
+	// Equals matches when Field has this value. Numbers are compared by
+	// value, anything else by its text form.
+	Equals interface{} `yaml:"equals,omitempty"`
+	// Exists matches when Field is present (true) or missing (false).
+	Exists    *bool    `yaml:"exists,omitempty"`
+	KeyPrefix string   `yaml:"keyPrefix,omitempty"`
+	KeyRegex  string   `yaml:"keyRegex,omitempty"`
+	Field     string   `yaml:"field,omitempty"`
+	Events    []string `yaml:"events,omitempty"`
+}
+
+func validateFilter(m CollectionTableMapping) error {
+	if m.Filter == nil {
+		return nil
+	}
+	rules := map[string][]FilterRule{"include": m.Filter.Include, "exclude": m.Filter.Exclude}
+	for _, kind := range []string{"include", "exclude"} {
+		for i, rule := range rules[kind] {
+			if err := validateFilterRule(rule); err != nil {
+				return fmt.Errorf("invalid filter.%s[%d] for table %s: %w", kind, i, m.TableName, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateFilterRule(rule FilterRule) error {
+	if rule.KeyRegex != "" {
+		if _, err := regexp.Compile(rule.KeyRegex); err != nil {
+			return fmt.Errorf("keyRegex: %w", err)
+		}
+	}
+	if rule.Field != "" {
+		if _, err := jsonpath.Compile(rule.Field); err != nil {
+			return fmt.Errorf("field: %w", err)
+		}
+	}
+	if rule.Field == "" && (rule.Equals != nil || rule.Exists != nil) {
+		return fmt.Errorf("equals and exists require a field")
+	}
+	if rule.Field != "" && (rule.Equals == nil) == (rule.Exists == nil) {
+		return fmt.Errorf("field %s needs exactly one of equals or exists", rule.Field)
+	}
+	if rule.KeyPrefix == "" && rule.KeyRegex == "" && rule.Field == "" && len(rule.Events) == 0 {
+		return fmt.Errorf("rule has no condition")
+	}
+	for _, event := range rule.Events {
+		if !validEvents[event] {
+			return fmt.Errorf("unknown event %q, use mutation, deletion or expiration", event)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Policies for documents whose body is not a JSON object: binary DCP
+// datatypes, bodies that do not parse, and JSON that is not an object.
+const (
+	// InvalidDocumentSkip logs, counts and acks the event without writing.
+	InvalidDocumentSkip = "skip"
+	// InvalidDocumentRaw writes only the columns that do not read the
+	// body: _key, _key.<group>, metadata sources and documentData.
+	InvalidDocumentRaw = "raw"
+	// InvalidDocumentDeadLetter writes the event to DeadLetterTable.
+	InvalidDocumentDeadLetter = "deadLetter"
+	// InvalidDocumentFail panics, like a failed write does. It is the
+	// default, so documents are only dropped when a table opts in.
+	InvalidDocumentFail = "fail"
+)
+
+// Columns of a dead-letter table.
+const (
+	DeadLetterKeyColumn        = "id"
+	DeadLetterCollectionColumn = "collection"
+	DeadLetterTableColumn      = "table_name"
+	DeadLetterEventTimeColumn  = "event_time"
+	DeadLetterDatatypeColumn   = "datatype"
+	DeadLetterValueColumn      = "value"
+	DeadLetterErrorColumn      = "error"
+)
+
+// DeadLetterColumns lists the columns a dead-letter table must have.
+var DeadLetterColumns = []string{
+	DeadLetterKeyColumn, DeadLetterCollectionColumn, DeadLetterTableColumn, DeadLetterEventTimeColumn,
+	DeadLetterDatatypeColumn, DeadLetterValueColumn, DeadLetterErrorColumn,
+}
+
+var invalidDocumentPolicies = map[string]string{
+	"skip":       InvalidDocumentSkip,
+	"raw":        InvalidDocumentRaw,
+	"deadletter": InvalidDocumentDeadLetter,
+	"fail":       InvalidDocumentFail,
+}
+
+// DeadLetterTarget returns the keyspace and table of DeadLetterTable, which
+// is "[keyspace.]table". An empty keyspace keeps the cluster keyspace.
+func (m CollectionTableMapping) DeadLetterTarget() (keyspace, table string) {
+	keyspace, table, found := strings.Cut(m.DeadLetterTable, ".")
+	if !found {
+		return "", keyspace
+	}
+	return keyspace, table
+}
+
+func (m *CollectionTableMapping) setInvalidDocumentDefaults() {
+	policy := strings.ToLower(strings.TrimSpace(m.InvalidDocumentPolicy))
+	if policy == "" {
+		policy = InvalidDocumentFail
+	}
+	if canonical, ok := invalidDocumentPolicies[policy]; ok {
+		policy = canonical
+	}
+	m.InvalidDocumentPolicy = policy
+	m.DeadLetterTable = strings.TrimSpace(m.DeadLetterTable)
+}
+
+func validateInvalidDocumentPolicy(m CollectionTableMapping) error {
+	switch m.InvalidDocumentPolicy {
+	case "", InvalidDocumentSkip, InvalidDocumentFail:
+	case InvalidDocumentDeadLetter:
+		keyspace, table := m.DeadLetterTarget()
+		if !keyspaceNamePattern.MatchString(table) || (keyspace != "" && !keyspaceNamePattern.MatchString(keyspace)) {
+			return fmt.Errorf("deadLetter invalidDocumentPolicy of table %s requires deadLetterTable as [keyspace.]table, got %q",
+				m.TableName, m.DeadLetterTable)
+		}
+	case InvalidDocumentRaw:
+		return validateRawPolicy(m)
+	default:
+		return fmt.Errorf("invalid invalidDocumentPolicy %q for table %s: use skip, raw, deadLetter or fail",
+			m.InvalidDocumentPolicy, m.TableName)
+	}
+	return nil
+}
+
+// validateRawPolicy checks that a raw row can be written without the body:
+// it must have a documentData column, a primary key that is not read from
+// the body, and a table and keyspace that do not depend on it.
+func validateRawPolicy(m CollectionTableMapping) error {
+	if m.Explode != "" {
+		return fmt.Errorf("raw invalidDocumentPolicy of table %s cannot be combined with explode", m.TableName)
+	}
+	hasDocumentData := false
+	for _, source := range m.FieldMappings {
+		hasDocumentData = hasDocumentData || source == "documentData"
+	}
+	if !hasDocumentData {
+		return fmt.Errorf("raw invalidDocumentPolicy of table %s requires a documentData column", m.TableName)
+	}
+	for _, pk := range m.PrimaryKeyFields {
+		if !IsRawSource(m.FieldMappings[pk]) {
+			return fmt.Errorf("raw invalidDocumentPolicy of table %s requires primary key column %s to be read from the key or metadata",
+				m.TableName, pk)
+		}
+	}
+	if placeholder, ok := documentPlaceholder(m); ok {
+		return fmt.Errorf("raw invalidDocumentPolicy of table %s cannot resolve placeholder {%s} without the document",
+			m.TableName, placeholder)
+	}
+	return nil
+}
+
+// IsRawSource reports whether source is written by the raw policy: it is
+// read from the event rather than from the document body.
+func IsRawSource(source string) bool {
+	return source == "_key" || source == "documentData" || IsMetadataSource(source) ||
+		strings.HasPrefix(source, KeyGroupSourcePrefix)
+}
+
+func isEventPlaceholder(placeholder string) bool {
+	switch {
+	case placeholder == KeyPrefixPlaceholder, placeholder == ScopePlaceholder, placeholder == CollectionPlaceholder:
+		return true
+	}
+	return strings.HasPrefix(placeholder, KeyGroupSourcePrefix) || strings.HasPrefix(placeholder, MatchPlaceholderPrefix)
+}
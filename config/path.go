@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Trendyol/go-dcp-cassandra/jsonpath"
+)
+
+// IsFieldSource reports whether source is a path into the document, or
+// into the exploded element for _element sources, rather than a special
+// source.
+func IsFieldSource(source string) bool {
+	switch {
+	case source == "_key", source == "documentData", source == SourceIndex:
+		return false
+	case IsMetadataSource(source), IsExpressionSource(source), strings.HasPrefix(source, KeyGroupSourcePrefix):
+		return false
+	}
+	return true
+}
+
+// validateFieldPaths checks that the field paths of sources, explode and
+// tableName and keyspace placeholders compile.
+func validateFieldPaths(m CollectionTableMapping) error {
+	for column, source := range m.FieldMappings {
+		if !IsFieldSource(source) {
+			continue
+		}
+		if _, err := jsonpath.Compile(source); err != nil {
+			return fmt.Errorf("invalid source of column %s of table %s: %w", column, m.TableName, err)
+		}
+	}
+	if m.Explode != "" {
+		if _, err := jsonpath.Compile(m.Explode); err != nil {
+			return fmt.Errorf("invalid explode of table %s: %w", m.TableName, err)
+		}
+	}
+	for _, name := range []string{m.TableName, m.Keyspace} {
+		for _, match := range keyspacePlaceholderPattern.FindAllStringSubmatch(name, -1) {
+			if isEventPlaceholder(match[1]) {
+				continue
+			}
+			if _, err := jsonpath.Compile(match[1]); err != nil {
+				return fmt.Errorf("invalid placeholder {%s} of table %s: %w", match[1], m.TableName, err)
+			}
+		}
+	}
+	return nil
+}
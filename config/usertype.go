@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var userTypeNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// setUserTypeDefaults lower-cases user type names and field types, as
+// ParseCQLType does with the column types that refer to them.
+func (m *CollectionTableMapping) setUserTypeDefaults() {
+	if len(m.UserTypes) == 0 {
+		return
+	}
+	normalized := make(map[string]map[string]string, len(m.UserTypes))
+	for name, fields := range m.UserTypes {
+		normalizedFields := make(map[string]string, len(fields))
+		for field, fieldType := range fields {
+			normalizedFields[strings.TrimSpace(field)] = strings.TrimSpace(strings.ToLower(fieldType))
+		}
+		normalized[strings.TrimSpace(strings.ToLower(name))] = normalizedFields
+	}
+	m.UserTypes = normalized
+}
+
+// validateUserTypes checks that every user type has fields of valid types,
+// that the user types they refer to are declared too, and that no type
+// contains itself.
+func validateUserTypes(m CollectionTableMapping) error {
+	names := make([]string, 0, len(m.UserTypes))
+	for name := range m.UserTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		scope := fmt.Sprintf("user type %s of table %s", name, m.TableName)
+		if !userTypeNamePattern.MatchString(name) || nativeCQLTypes[name] {
+			return fmt.Errorf("invalid name of %s", scope)
+		}
+		if len(m.UserTypes[name]) == 0 {
+			return fmt.Errorf("%s has no fields", scope)
+		}
+		for field, fieldType := range m.UserTypes[name] {
+			if field == "" {
+				return fmt.Errorf("%s has a field without a name", scope)
+			}
+			t, err := ParseCQLType(fieldType)
+			if err != nil {
+				return fmt.Errorf("field %s of %s: %w", field, scope, err)
+			}
+			for _, ref := range t.UserTypeNames() {
+				if _, declared := m.UserTypes[ref]; !declared {
+					return fmt.Errorf("field %s of %s refers to undeclared user type %s", field, scope, ref)
+				}
+			}
+		}
+		if containsUserType(m.UserTypes, name, name, make(map[string]bool)) {
+			return fmt.Errorf("%s contains itself", scope)
+		}
+	}
+	return nil
+}
+
+// containsUserType reports whether a field of user type name, at any depth,
+// is of user type target.
+func containsUserType(userTypes map[string]map[string]string, name, target string, visited map[string]bool) bool {
+	if visited[name] {
+		return false
+	}
+	visited[name] = true
+	for _, fieldType := range userTypes[name] {
+		t, err := ParseCQLType(fieldType)
+		if err != nil {
+			continue
+		}
+		for _, ref := range t.UserTypeNames() {
+			if ref == target || containsUserType(userTypes, ref, target, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
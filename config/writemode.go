@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Write modes choose the statement mutations are written with.
+const (
+	// WriteModeUpsert writes every mapped column with an INSERT, creating
+	// or overwriting the row.
+	WriteModeUpsert = "upsert"
+	// WriteModeInsertIfNotExists writes the row with INSERT ... IF NOT
+	// EXISTS, so the first write of a key wins.
+	WriteModeInsertIfNotExists = "insertIfNotExists"
+	// WriteModeUpdateExisting writes the columns that are not in
+	// primaryKeyFields with an UPDATE keyed by primaryKeyFields, IF EXISTS
+	// when IfExists is set.
+	WriteModeUpdateExisting = "updateExisting"
+)
+
+var writeModes = map[string]string{
+	"upsert":            WriteModeUpsert,
+	"insertifnotexists": WriteModeInsertIfNotExists,
+	"updateexisting":    WriteModeUpdateExisting,
+}
+
+// UpdateColumns returns the columns an updateExisting UPDATE sets: the
+// mapped columns that are not in primaryKeyFields, sorted.
+func (m CollectionTableMapping) UpdateColumns() []string {
+	var columns []string
+	for column := range m.FieldMappings {
+		if !slices.Contains(m.PrimaryKeyFields, column) {
+			columns = append(columns, column)
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+func (m *CollectionTableMapping) setWriteModeDefaults() {
+	mode := strings.ToLower(strings.TrimSpace(m.WriteMode))
+	if mode == "" {
+		mode = WriteModeUpsert
+	}
+	if canonical, ok := writeModes[mode]; ok {
+		mode = canonical
+	}
+	m.WriteMode = mode
+}
+
+func validateWriteMode(m CollectionTableMapping) error {
+	switch m.WriteMode {
+	case "", WriteModeUpsert:
+	case WriteModeInsertIfNotExists:
+		if m.Explode != "" {
+			return fmt.Errorf("writeMode %s cannot be combined with explode for table %s", m.WriteMode, m.TableName)
+		}
+	case WriteModeUpdateExisting:
+		if m.Explode != "" {
+			return fmt.Errorf("writeMode %s cannot be combined with explode for table %s", m.WriteMode, m.TableName)
+		}
+		if len(m.PrimaryKeyFields) == 0 {
+			return fmt.Errorf("writeMode updateExisting of table %s requires primaryKeyFields", m.TableName)
+		}
+		if len(m.UpdateColumns()) == 0 {
+			return fmt.Errorf("writeMode updateExisting of table %s requires a column outside primaryKeyFields", m.TableName)
+		}
+	default:
+		return fmt.Errorf("invalid writeMode %q for table %s: use upsert, insertIfNotExists or updateExisting",
+			m.WriteMode, m.TableName)
+	}
+	if m.IfExists && m.WriteMode != WriteModeUpdateExisting {
+		return fmt.Errorf("ifExists of table %s requires writeMode updateExisting", m.TableName)
+	}
+	return nil
+}
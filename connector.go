@@ -6,8 +6,10 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/Trendyol/go-dcp"
+	dcpconfig "github.com/Trendyol/go-dcp/config"
 	"github.com/Trendyol/go-dcp/models"
 	"gopkg.in/yaml.v3"
 
@@ -34,8 +36,10 @@ type connector struct {
 }
 
 type ConnectorBuilder struct {
-	config any
-	mapper Mapper
+	config         any
+	mapper         Mapper
+	session        cassandra.Session
+	sessionFactory cassandra.SessionFactory
 }
 
 func newConnectorConfigFromPath(path string) (*config.Connector, error) {
@@ -64,7 +68,7 @@ func newConfig(cf any) (*config.Connector, error) {
 	}
 }
 
-func newConnector(cf any, mapper Mapper) (Connector, error) {
+func newConnector(cf any, mapper Mapper, bulkOptions ...cassandra.BulkOption) (Connector, error) {
 	cfg, err := newConfig(cf)
 	if err != nil {
 		return nil, err
@@ -75,11 +79,17 @@ func newConnector(cf any, mapper Mapper) (Connector, error) {
 		return nil, err
 	}
 
-	var finalMapper Mapper
+	finalMapper := mapper
+	var defaultMapper *connectorpkg.DefaultMapper
 	if len(cfg.Cassandra.CollectionTableMapping) > 0 {
-		finalMapper = connectorpkg.DefaultMapper
-	} else {
-		finalMapper = mapper
+		defaultMapper, err = connectorpkg.NewDefaultMapper(cfg.Cassandra.CollectionTableMapping)
+		if err != nil {
+			return nil, err
+		}
+		if err := defaultMapper.CheckCollections(dcpScopeName(cfg), dcpCollectionNames(cfg)); err != nil {
+			return nil, err
+		}
+		finalMapper = defaultMapper.Map
 	}
 
 	conn := &connector{
@@ -93,26 +103,51 @@ func newConnector(cf any, mapper Mapper) (Connector, error) {
 	}
 	conn.dcp = dcpClient
 
-	bulk, err := cassandra.NewBulk(cfg, func() { dcpClient.Commit() })
+	bulk, err := cassandra.NewBulk(cfg, func() { dcpClient.Commit() }, bulkOptions...)
 	if err != nil {
 		return nil, err
 	}
 	conn.bulk = bulk
 
+	if defaultMapper != nil {
+		defaultMapper.SetSchemaTypes(bulk.SchemaTypes())
+		if err := defaultMapper.CheckDefaults(); err != nil {
+			return nil, err
+		}
+	}
+
 	conn.dcp.SetEventHandler(
 		&DcpEventHandler{
 			isFinite: cfg.Dcp.IsDcpModeFinite(),
 			bulk:     conn.bulk,
 		})
 
-	connectorpkg.SetCollectionTableMappings(&cfg.Cassandra.CollectionTableMapping)
-
 	metricCollector := metric.NewMetricCollector(conn.bulk)
+	if defaultMapper != nil {
+		metricCollector.SetInvalidDocumentReporter(defaultMapper)
+	}
 	dcpClient.SetMetricCollectors(metricCollector)
 
 	return conn, nil
 }
 
+// dcpScopeName is the scope go-dcp streams, defaulted like go-dcp does.
+func dcpScopeName(cfg *config.Connector) string {
+	if cfg.Dcp.ScopeName == "" {
+		return dcpconfig.DefaultScopeName
+	}
+	return cfg.Dcp.ScopeName
+}
+
+// dcpCollectionNames are the collections go-dcp streams, defaulted like
+// go-dcp does.
+func dcpCollectionNames(cfg *config.Connector) []string {
+	if cfg.Dcp.CollectionNames == nil {
+		return []string{dcpconfig.DefaultCollectionName}
+	}
+	return cfg.Dcp.CollectionNames
+}
+
 func NewConnectorBuilder(config any) ConnectorBuilder {
 	return ConnectorBuilder{
 		config: config,
@@ -138,8 +173,29 @@ func (c ConnectorBuilder) SetMapper(mapper Mapper) ConnectorBuilder {
 	return c
 }
 
+// SetSession makes the connector write through session instead of opening
+// its own, e.g. to wrap it with a proxy or replace it with a fake in tests.
+func (c ConnectorBuilder) SetSession(session cassandra.Session) ConnectorBuilder {
+	c.session = session
+	return c
+}
+
+// SetSessionFactory makes the connector build its sessions with factory,
+// for the primary and dual-write clusters and when a lost session is rebuilt.
+func (c ConnectorBuilder) SetSessionFactory(factory cassandra.SessionFactory) ConnectorBuilder {
+	c.sessionFactory = factory
+	return c
+}
+
 func (c ConnectorBuilder) Build() (Connector, error) {
-	return newConnector(c.config, c.mapper)
+	var bulkOptions []cassandra.BulkOption
+	if c.session != nil {
+		bulkOptions = append(bulkOptions, cassandra.WithSession(c.session))
+	}
+	if c.sessionFactory != nil {
+		bulkOptions = append(bulkOptions, cassandra.WithSessionFactory(c.sessionFactory))
+	}
+	return newConnector(c.config, c.mapper, bulkOptions...)
 }
 
 func (c *connector) Start() {
@@ -170,21 +226,40 @@ func (c *connector) Close() {
 }
 
 func (c *connector) listener(ctx *models.ListenerContext) {
-	var e couchbase.Event
-	switch event := ctx.Event.(type) {
+	e, ok := c.event(ctx.Event)
+	if !ok {
+		ctx.Ack()
+		return
+	}
+
+	actions := c.mapper(e)
+	c.bulk.AddActions(ctx, e.EventTime, actions)
+}
+
+// event converts a go-dcp event to the event mappers get. ok is false for
+// events that are not mutations, deletions or expirations.
+func (c *connector) event(dcpEvent interface{}) (e couchbase.Event, ok bool) {
+	switch event := dcpEvent.(type) {
 	case models.DcpMutation:
 		e = couchbase.NewMutateEvent(event.Key, event.Value, event.CollectionName, event.EventTime, event.Cas, event.VbID)
+		e.RevSeqNo = event.RevNo
+		e.Datatype, e.Binary = event.Datatype, couchbase.IsBinaryDatatype(event.Datatype)
+		if event.Expiry > 0 {
+			e.Expiry = time.Unix(int64(event.Expiry), 0).UTC()
+		}
 	case models.DcpExpiration:
 		e = couchbase.NewExpireEvent(event.Key, nil, event.CollectionName, event.EventTime, event.Cas, event.VbID)
+		e.RevSeqNo = event.RevNo
 	case models.DcpDeletion:
 		e = couchbase.NewDeleteEvent(event.Key, event.Value, event.CollectionName, event.EventTime, event.Cas, event.VbID)
+		e.RevSeqNo = event.RevNo
+		e.Datatype, e.Binary = event.Datatype, couchbase.IsBinaryDatatype(event.Datatype)
 	default:
-		ctx.Ack()
-		return
+		return couchbase.Event{}, false
 	}
-
-	actions := c.mapper(e)
-	c.bulk.AddActions(ctx, e.EventTime, actions)
+	// go-dcp streams a single scope, _default when none is configured.
+	e.ScopeName = dcpScopeName(c.config)
+	return e, true
 }
 
 func (c *connector) GetBulk() *cassandra.Bulk {
@@ -0,0 +1,76 @@
+package connector
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/Trendyol/go-dcp-cassandra/config"
+	"github.com/Trendyol/go-dcp-cassandra/expr"
+	"github.com/Trendyol/go-dcp-cassandra/jsonpath"
+)
+
+// cache holds what a DefaultMapper compiles from its mappings, keyed by
+// source: collection, key and filter patterns, field paths, column types
+// and computed column expressions. Every source comes from the mapper's
+// configuration, which bounds its size.
+type cache struct {
+	regexps  sync.Map
+	paths    sync.Map
+	cqlTypes sync.Map
+	programs sync.Map
+}
+
+// tableMapping is a collectionTableMapping entry with the cache of the
+// mapper it belongs to.
+type tableMapping struct {
+	config.CollectionTableMapping
+	cache *cache
+}
+
+func (c *cache) compileRegexp(source string) (*regexp.Regexp, error) {
+	if cached, ok := c.regexps.Load(source); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	pattern, err := regexp.Compile(source)
+	if err != nil {
+		return nil, err
+	}
+	c.regexps.Store(source, pattern)
+	return pattern, nil
+}
+
+func (c *cache) compilePath(source string) (*jsonpath.Path, error) {
+	if cached, ok := c.paths.Load(source); ok {
+		return cached.(*jsonpath.Path), nil
+	}
+	path, err := jsonpath.Compile(source)
+	if err != nil {
+		return nil, err
+	}
+	c.paths.Store(source, path)
+	return path, nil
+}
+
+func (c *cache) parseCQLType(source string) (config.CQLType, error) {
+	if cached, ok := c.cqlTypes.Load(source); ok {
+		return cached.(config.CQLType), nil
+	}
+	t, err := config.ParseCQLType(source)
+	if err != nil {
+		return config.CQLType{}, err
+	}
+	c.cqlTypes.Store(source, t)
+	return t, nil
+}
+
+func (c *cache) compileExpression(expression string) (*expr.Program, error) {
+	if cached, ok := c.programs.Load(expression); ok {
+		return cached.(*expr.Program), nil
+	}
+	program, err := config.CompileExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+	c.programs.Store(expression, program)
+	return program, nil
+}
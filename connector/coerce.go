@@ -0,0 +1,451 @@
+package connector
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	gocql "github.com/apache/cassandra-gocql-driver/v2"
+	"gopkg.in/inf.v0"
+
+	"github.com/Trendyol/go-dcp-cassandra/config"
+)
+
+// epochMillisThreshold separates epoch seconds from epoch milliseconds in
+// numeric timestamps: 1e11 seconds is in the year 5138, 1e11 ms is in 1973.
+const epochMillisThreshold = 1e11
+
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// isoDurationPattern matches ISO-8601 durations such as P1Y2M3DT4H5M6.5S or
+// P2W, optionally negated, as CQL duration literals accept them.
+var isoDurationPattern = regexp.MustCompile(
+	`^(-)?P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d{1,9})?)S)?)?$`,
+)
+
+// parseDocument decodes a JSON document keeping numbers as json.Number so
+// that large integers survive until they are coerced to a column type.
+// Anything after the document is an error.
+func parseDocument(data []byte) (map[string]interface{}, error) {
+	var document map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unexpected data after the document at offset %d", decoder.InputOffset())
+	}
+	return document, nil
+}
+
+// coerceValue converts a decoded JSON value into the Go type gocql expects
+// for cqlType. Lists, sets, maps and tuples are converted element by
+// element.
+// Without a known type the value is passed through with numbers as
+// float64, as plain encoding/json would decode them.
+//
+//nolint:gocyclo
+func coerceValue(c *cache, cqlType string, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if strings.Contains(cqlType, "<") {
+		t, err := c.parseCQLType(cqlType)
+		if err != nil {
+			return nil, err
+		}
+		return coerceCQLType(c, t, nil, value)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(cqlType)) {
+	case "text", "varchar", "ascii", "inet":
+		return toText(value)
+	case "int":
+		n, err := toInt64(value, 32)
+		return int32(n), err
+	case "smallint":
+		n, err := toInt64(value, 16)
+		return int16(n), err
+	case "tinyint":
+		n, err := toInt64(value, 8)
+		return int8(n), err
+	case "bigint", "counter":
+		return toInt64(value, 64)
+	case "varint":
+		return toBigInt(value)
+	case "float":
+		f, err := toFloat64(value)
+		return float32(f), err
+	case "double":
+		return toFloat64(value)
+	case "decimal":
+		return toDecimal(value)
+	case "boolean":
+		return toBool(value)
+	case "timestamp", "date":
+		return toTime(value)
+	case "time":
+		return toTimeOfDay(value)
+	case "duration":
+		return toDuration(value)
+	case "uuid", "timeuuid":
+		return toUUID(value)
+	case "blob":
+		return toBlob(value)
+	default:
+		return plainValue(value), nil
+	}
+}
+
+// coerceFormattedValue is coerceColumnValue for a column with a field spec
+// format: timestamp and date strings are parsed with the format's layout,
+// and epoch formats fix the unit of numeric values.
+func coerceFormattedValue(
+	c *cache, cqlType, format string, userTypes map[string]map[string]string, value interface{},
+) (interface{}, error) {
+	columnType := strings.ToLower(strings.TrimSpace(cqlType))
+	if format == "" || value == nil || (columnType != "timestamp" && columnType != "date") {
+		return coerceColumnValue(c, cqlType, userTypes, value)
+	}
+
+	switch format {
+	case config.TimeFormatEpochSeconds, config.TimeFormatEpochMillis:
+		epoch, err := toFloat64(value)
+		if err != nil {
+			return nil, err
+		}
+		if format == config.TimeFormatEpochSeconds {
+			epoch *= 1000
+		}
+		return time.UnixMilli(int64(epoch)).UTC(), nil
+	}
+
+	text, ok := value.(string)
+	if !ok {
+		return toTime(value)
+	}
+	t, err := time.Parse(config.TimeLayout(format), strings.TrimSpace(text))
+	if err != nil {
+		return nil, fmt.Errorf("%q does not match format %s", text, format)
+	}
+	return t.UTC(), nil
+}
+
+// plainValue replaces json.Number with float64, recursing into objects and
+// arrays.
+func plainValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return v.String()
+		}
+		return f
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = plainValue(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = plainValue(item)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+func toText(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case map[string]interface{}, []interface{}:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(encoded), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+func toInt64(value interface{}, bits int) (int64, error) {
+	var text string
+	switch v := value.(type) {
+	case json.Number:
+		text = v.String()
+	case string:
+		text = strings.TrimSpace(v)
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("%v is not an integer", v)
+		}
+		text = strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		text = strconv.Itoa(v)
+	case int64:
+		text = strconv.FormatInt(v, 10)
+	case uint16:
+		text = strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		text = strconv.FormatUint(v, 10)
+	case time.Time:
+		// Event metadata times mapped to integer columns are epoch milliseconds.
+		text = strconv.FormatInt(v.UnixMilli(), 10)
+	default:
+		return 0, fmt.Errorf("cannot convert %T to an integer", value)
+	}
+
+	n, err := strconv.ParseInt(text, 10, bits)
+	if err == nil {
+		return n, nil
+	}
+	// Accept integral values written in exponent or decimal notation, e.g. 1e3 or 10.0.
+	f, ferr := strconv.ParseFloat(text, 64)
+	if ferr != nil || f != math.Trunc(f) {
+		return 0, err
+	}
+	return strconv.ParseInt(strconv.FormatFloat(f, 'f', -1, 64), 10, bits)
+}
+
+func toBigInt(value interface{}) (interface{}, error) {
+	text, err := numericText(value)
+	if err != nil {
+		return nil, err
+	}
+	n, ok := new(big.Int).SetString(text, 10)
+	if !ok {
+		return nil, fmt.Errorf("%q is not an integer", text)
+	}
+	return *n, nil
+}
+
+func toFloat64(value interface{}) (float64, error) {
+	text, err := numericText(value)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(text, 64)
+}
+
+func toDecimal(value interface{}) (interface{}, error) {
+	text, err := numericText(value)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := new(inf.Dec).SetString(text)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a decimal", text)
+	}
+	return *d, nil
+}
+
+func numericText(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.String(), nil
+	case string:
+		return strings.TrimSpace(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	default:
+		return "", fmt.Errorf("cannot convert %T to a number", value)
+	}
+}
+
+func toBool(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		return strconv.ParseBool(strings.TrimSpace(v))
+	case json.Number, float64:
+		f, err := toFloat64(v)
+		return f != 0, err
+	default:
+		return nil, fmt.Errorf("cannot convert %T to a boolean", value)
+	}
+}
+
+// toTime accepts ISO-8601 strings and epoch numbers. Epoch values below
+// epochMillisThreshold are seconds, anything larger is milliseconds.
+func toTime(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		text := strings.TrimSpace(v)
+		for _, layout := range timestampLayouts {
+			if t, err := time.Parse(layout, text); err == nil {
+				return t.UTC(), nil
+			}
+		}
+		if _, err := strconv.ParseFloat(text, 64); err == nil {
+			return toTime(json.Number(text))
+		}
+		return nil, fmt.Errorf("%q is not an ISO-8601 timestamp or epoch number", v)
+	default:
+		epoch, err := toFloat64(value)
+		if err != nil {
+			return nil, err
+		}
+		if math.Abs(epoch) < epochMillisThreshold {
+			epoch *= 1000
+		}
+		return time.UnixMilli(int64(epoch)).UTC(), nil
+	}
+}
+
+// toTimeOfDay accepts HH:MM:SS[.fffffffff] strings and nanoseconds since
+// midnight, the CQL time literals. Event times are taken as their UTC time
+// of day.
+func toTimeOfDay(value interface{}) (interface{}, error) {
+	var nanos int64
+	switch v := value.(type) {
+	case time.Duration:
+		nanos = int64(v)
+	case time.Time:
+		v = v.UTC()
+		nanos = int64(v.Sub(time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, time.UTC)))
+	case string:
+		text := strings.TrimSpace(v)
+		if !strings.Contains(text, ":") {
+			return toTimeOfDay(json.Number(text))
+		}
+		t, err := time.Parse("15:04:05.999999999", text)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a HH:MM:SS[.fffffffff] time", v)
+		}
+		nanos = int64(t.Sub(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)))
+	default:
+		n, err := toInt64(value, 64)
+		if err != nil {
+			return nil, err
+		}
+		nanos = n
+	}
+	if nanos < 0 || nanos >= int64(24*time.Hour) {
+		return nil, fmt.Errorf("%d nanoseconds is not a time of day", nanos)
+	}
+	return time.Duration(nanos), nil
+}
+
+// toDuration accepts ISO-8601 durations such as P1DT12H, Go durations such as
+// 1h30m and numbers of nanoseconds.
+func toDuration(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case gocql.Duration:
+		return v, nil
+	case time.Duration:
+		return gocql.Duration{Nanoseconds: int64(v)}, nil
+	case string:
+		text := strings.TrimSpace(v)
+		if match := isoDurationPattern.FindStringSubmatch(text); match != nil {
+			return isoDuration(text, match)
+		}
+		if d, err := time.ParseDuration(text); err == nil {
+			return gocql.Duration{Nanoseconds: int64(d)}, nil
+		}
+		if _, err := strconv.ParseFloat(text, 64); err == nil {
+			return toDuration(json.Number(text))
+		}
+		return nil, fmt.Errorf("%q is not an ISO-8601 or Go duration", v)
+	default:
+		n, err := toInt64(value, 64)
+		if err != nil {
+			return nil, err
+		}
+		return gocql.Duration{Nanoseconds: n}, nil
+	}
+}
+
+// isoDuration converts the submatches of isoDurationPattern in text. Years
+// and months become months and weeks and days become days, which Cassandra
+// keeps apart from the time part since their length varies.
+func isoDuration(text string, match []string) (interface{}, error) {
+	if strings.HasSuffix(text, "P") || strings.HasSuffix(text, "T") {
+		return nil, fmt.Errorf("%q is not an ISO-8601 duration", text)
+	}
+	var n [8]int64
+	for i := 2; i < len(n); i++ {
+		if match[i] == "" {
+			continue
+		}
+		var err error
+		if n[i], err = strconv.ParseInt(match[i], 10, 32); err != nil {
+			return nil, fmt.Errorf("%q is out of range", text)
+		}
+	}
+	months := n[2]*12 + n[3]
+	days := n[4]*7 + n[5]
+	if months > math.MaxInt32 || days > math.MaxInt32 {
+		return nil, fmt.Errorf("%q is out of range", text)
+	}
+	seconds := match[8]
+	if seconds == "" {
+		seconds = "0"
+	}
+	nanos, err := time.ParseDuration(fmt.Sprintf("%dh%dm%ss", n[6], n[7], seconds))
+	if err != nil {
+		return nil, fmt.Errorf("%q is out of range", text)
+	}
+	d := gocql.Duration{Months: int32(months), Days: int32(days), Nanoseconds: int64(nanos)}
+	if match[1] == "-" {
+		d = gocql.Duration{Months: -d.Months, Days: -d.Days, Nanoseconds: -d.Nanoseconds}
+	}
+	return d, nil
+}
+
+func toUUID(value interface{}) (interface{}, error) {
+	text, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("cannot convert %T to a uuid", value)
+	}
+	return gocql.ParseUUID(strings.TrimSpace(text))
+}
+
+func toBlob(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	default:
+		return json.Marshal(plainValue(value))
+	}
+}
@@ -0,0 +1,351 @@
+package connector
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	gocql "github.com/apache/cassandra-gocql-driver/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/inf.v0"
+
+	"github.com/Trendyol/go-dcp-cassandra/config"
+)
+
+func TestCoerceValue_Integers(t *testing.T) {
+	v, err := coerceValue(new(cache), "int", json.Number("42"))
+	require.NoError(t, err)
+	assert.Equal(t, int32(42), v)
+
+	v, err = coerceValue(new(cache), "bigint", json.Number("9007199254740993"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(9007199254740993), v, "bigint must not lose precision through float64")
+
+	v, err = coerceValue(new(cache), "smallint", "12")
+	require.NoError(t, err)
+	assert.Equal(t, int16(12), v)
+
+	v, err = coerceValue(new(cache), "int", json.Number("1e3"))
+	require.NoError(t, err)
+	assert.Equal(t, int32(1000), v)
+
+	_, err = coerceValue(new(cache), "int", json.Number("1.5"))
+	assert.Error(t, err)
+
+	_, err = coerceValue(new(cache), "tinyint", json.Number("300"))
+	assert.Error(t, err)
+}
+
+func TestCoerceValue_VarintAndDecimal(t *testing.T) {
+	v, err := coerceValue(new(cache), "varint", json.Number("123456789012345678901234567890"))
+	require.NoError(t, err)
+	expected, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	n := v.(big.Int)
+	assert.Equal(t, 0, expected.Cmp(&n))
+
+	v, err = coerceValue(new(cache), "decimal", json.Number("19.99"))
+	require.NoError(t, err)
+	d := v.(inf.Dec)
+	assert.Equal(t, "19.99", d.String())
+}
+
+func TestCoerceValue_Timestamps(t *testing.T) {
+	expected := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	v, err := coerceValue(new(cache), "timestamp", "2024-01-02T03:04:05Z")
+	require.NoError(t, err)
+	assert.Equal(t, expected, v)
+
+	v, err = coerceValue(new(cache), "timestamp", "2024-01-02T06:04:05+03:00")
+	require.NoError(t, err)
+	assert.Equal(t, expected, v)
+
+	v, err = coerceValue(new(cache), "timestamp", json.Number("1704164645"))
+	require.NoError(t, err)
+	assert.Equal(t, expected, v, "epoch seconds")
+
+	v, err = coerceValue(new(cache), "timestamp", json.Number("1704164645000"))
+	require.NoError(t, err)
+	assert.Equal(t, expected, v, "epoch milliseconds")
+
+	v, err = coerceValue(new(cache), "date", "2024-01-02")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), v)
+
+	_, err = coerceValue(new(cache), "timestamp", "yesterday")
+	assert.Error(t, err)
+}
+
+func TestCoerceValue_Time(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected time.Duration
+		err      bool
+	}{
+		{name: "seconds", value: "08:12:54", expected: 8*time.Hour + 12*time.Minute + 54*time.Second},
+		{name: "fraction", value: "08:12:54.123456789", expected: 8*time.Hour + 12*time.Minute + 54*time.Second + 123456789},
+		{name: "midnight", value: "00:00:00", expected: 0},
+		{name: "nanoseconds", value: json.Number("29574000000000"), expected: 8*time.Hour + 12*time.Minute + 54*time.Second},
+		{name: "nanoseconds text", value: "1000", expected: time.Microsecond},
+		{name: "event time", value: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), expected: 3*time.Hour + 4*time.Minute + 5*time.Second},
+		{name: "hour out of range", value: "24:00:00", err: true},
+		{name: "missing seconds", value: "08:12", err: true},
+		{name: "negative", value: json.Number("-1"), err: true},
+		{name: "a day", value: json.Number("86400000000000"), err: true},
+		{name: "object", value: map[string]interface{}{}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := coerceValue(new(cache), "time", tt.value)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, v)
+		})
+	}
+}
+
+func TestCoerceValue_Duration(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected gocql.Duration
+		err      bool
+	}{
+		{name: "iso date", value: "P1Y2M3D", expected: gocql.Duration{Months: 14, Days: 3}},
+		{name: "iso weeks", value: "P2W", expected: gocql.Duration{Days: 14}},
+		{
+			name:     "iso date and time",
+			value:    "P1DT12H30M1.5S",
+			expected: gocql.Duration{Days: 1, Nanoseconds: int64(12*time.Hour + 30*time.Minute + 1500*time.Millisecond)},
+		},
+		{name: "iso time", value: "PT90M", expected: gocql.Duration{Nanoseconds: int64(90 * time.Minute)}},
+		{name: "iso negative", value: "-P1DT1H", expected: gocql.Duration{Days: -1, Nanoseconds: -int64(time.Hour)}},
+		{name: "go", value: "1h30m", expected: gocql.Duration{Nanoseconds: int64(90 * time.Minute)}},
+		{name: "go negative", value: "-1.5s", expected: gocql.Duration{Nanoseconds: -int64(1500 * time.Millisecond)}},
+		{name: "nanoseconds", value: json.Number("1000"), expected: gocql.Duration{Nanoseconds: 1000}},
+		{name: "nanoseconds text", value: "1000", expected: gocql.Duration{Nanoseconds: 1000}},
+		{name: "empty iso", value: "P", err: true},
+		{name: "empty iso time", value: "P1DT", err: true},
+		{name: "iso out of range", value: "P99999999999D", err: true},
+		{name: "iso seconds out of range", value: "PT99999999999999S", err: true},
+		{name: "words", value: "an hour", err: true},
+		{name: "fraction", value: json.Number("1.5"), err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := coerceValue(new(cache), "duration", tt.value)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, v)
+		})
+	}
+}
+
+func TestCoerceValue_UUIDBlobBoolText(t *testing.T) {
+	v, err := coerceValue(new(cache), "uuid", "550e8400-e29b-41d4-a716-446655440000")
+	require.NoError(t, err)
+	assert.IsType(t, gocql.UUID{}, v)
+
+	_, err = coerceValue(new(cache), "uuid", "not-a-uuid")
+	assert.Error(t, err)
+
+	v, err = coerceValue(new(cache), "blob", "raw")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("raw"), v)
+
+	v, err = coerceValue(new(cache), "boolean", "true")
+	require.NoError(t, err)
+	assert.Equal(t, true, v)
+
+	v, err = coerceValue(new(cache), "text", json.Number("10"))
+	require.NoError(t, err)
+	assert.Equal(t, "10", v)
+
+	v, err = coerceValue(new(cache), "text", map[string]interface{}{"a": json.Number("1")})
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":1}`, v)
+}
+
+func TestCoerceValue_UntypedKeepsFloat64(t *testing.T) {
+	v, err := coerceValue(new(cache), "", json.Number("10"))
+	require.NoError(t, err)
+	assert.Equal(t, float64(10), v)
+
+	v, err = coerceValue(new(cache), "custom", map[string]interface{}{"a": json.Number("1")})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": float64(1)}, v)
+
+	v, err = coerceValue(new(cache), "int", nil)
+	require.NoError(t, err)
+	assert.Nil(t, v)
+}
+
+func TestCoerceValue_Collections(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected interface{}
+		cqlType  string
+	}{
+		{
+			cqlType:  "list<int>",
+			value:    []interface{}{json.Number("1"), "2"},
+			expected: []interface{}{int32(1), int32(2)},
+		},
+		{
+			cqlType:  "frozen<set<text>>",
+			value:    []interface{}{"a", json.Number("1")},
+			expected: []interface{}{"a", "1"},
+		},
+		{
+			cqlType:  "map<text, bigint>",
+			value:    map[string]interface{}{"a": json.Number("1")},
+			expected: map[interface{}]interface{}{"a": int64(1)},
+		},
+		{
+			cqlType:  "map<int, frozen<list<double>>>",
+			value:    map[string]interface{}{"7": []interface{}{json.Number("1.5")}},
+			expected: map[interface{}]interface{}{int32(7): []interface{}{1.5}},
+		},
+		{cqlType: "list<int>", value: `[1, 2]`, expected: []interface{}{int32(1), int32(2)}},
+		{cqlType: "map<text, text>", value: `{"a": "b"}`, expected: map[interface{}]interface{}{"a": "b"}},
+		{cqlType: "list<text>", value: []interface{}{}, expected: []interface{}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cqlType, func(t *testing.T) {
+			v, err := coerceValue(new(cache), tt.cqlType, tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, v)
+		})
+	}
+
+	errs := []struct {
+		value   interface{}
+		cqlType string
+		errMsg  string
+	}{
+		{cqlType: "list<int>", value: []interface{}{json.Number("1"), nil}, errMsg: "element 1 is null"},
+		{cqlType: "list<int>", value: []interface{}{"x"}, errMsg: "element 0: "},
+		{cqlType: "set<text>", value: "a", errMsg: "cannot convert string to a set"},
+		{cqlType: "map<text, int>", value: []interface{}{}, errMsg: "cannot convert []interface {} to a map"},
+		{cqlType: "map<int, text>", value: map[string]interface{}{"x": "a"}, errMsg: `key "x": `},
+		{cqlType: "map<varint, text>", value: map[string]interface{}{"1": "a"}, errMsg: "map keys of type varint are not supported"},
+		{cqlType: "list<int", value: []interface{}{}, errMsg: `invalid type "list<int"`},
+	}
+	for _, tt := range errs {
+		t.Run(tt.errMsg, func(t *testing.T) {
+			_, err := coerceValue(new(cache), tt.cqlType, tt.value)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
+
+func TestCoerceColumnValue_UserTypes(t *testing.T) {
+	userTypes := map[string]map[string]string{
+		"address": {"street": "text", "zip_code": "int", "geo": "frozen<point>"},
+		"point":   {"lat": "double", "lon": "double"},
+	}
+	tests := []struct {
+		value    interface{}
+		expected interface{}
+		name     string
+		cqlType  string
+	}{
+		{
+			name:    "nested user type",
+			cqlType: "frozen<address>",
+			value: map[string]interface{}{
+				"street": "Main", "ZIP_CODE": json.Number("34000"), "extra": true,
+				"geo": map[string]interface{}{"lat": json.Number("41"), "lon": "29.5"},
+			},
+			expected: map[string]interface{}{
+				"street": "Main", "zip_code": int32(34000),
+				"geo": map[string]interface{}{"lat": 41.0, "lon": 29.5},
+			},
+		},
+		{
+			name:     "missing and null fields",
+			cqlType:  "address",
+			value:    `{"street": null}`,
+			expected: map[string]interface{}{"street": nil},
+		},
+		{
+			name:     "list of user types",
+			cqlType:  "list<frozen<point>>",
+			value:    []interface{}{map[string]interface{}{"lat": json.Number("1")}},
+			expected: []interface{}{map[string]interface{}{"lat": 1.0}},
+		},
+		{
+			name:     "map of user types",
+			cqlType:  "map<text, frozen<point>>",
+			value:    map[string]interface{}{"home": map[string]interface{}{"lon": json.Number("2")}},
+			expected: map[interface{}]interface{}{"home": map[string]interface{}{"lon": 2.0}},
+		},
+		{
+			name:     "tuple",
+			cqlType:  "frozen<tuple<int, text, point>>",
+			value:    []interface{}{json.Number("1"), nil, map[string]interface{}{"lat": json.Number("3")}},
+			expected: []interface{}{int32(1), nil, map[string]interface{}{"lat": 3.0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := coerceColumnValue(new(cache), tt.cqlType, userTypes, tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, v)
+		})
+	}
+
+	errs := []struct {
+		value   interface{}
+		cqlType string
+		errMsg  string
+	}{
+		{cqlType: "address", value: []interface{}{}, errMsg: "cannot convert []interface {} to address"},
+		{cqlType: "address", value: map[string]interface{}{"zip_code": "x"}, errMsg: "field zip_code: "},
+		{cqlType: "address", value: `{"geo": {"lat": "north"}}`, errMsg: "field geo: field lat: "},
+		{cqlType: "tuple<int, int>", value: []interface{}{json.Number("1")}, errMsg: "tuple<int, int> takes 2 elements, got 1"},
+		{cqlType: "tuple<int>", value: map[string]interface{}{}, errMsg: "cannot convert map[string]interface {} to a tuple"},
+	}
+	for _, tt := range errs {
+		t.Run(tt.errMsg, func(t *testing.T) {
+			_, err := coerceColumnValue(new(cache), tt.cqlType, userTypes, tt.value)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
+
+func TestCoerceFormattedValue(t *testing.T) {
+	v, err := coerceFormattedValue(new(cache), "timestamp", "02/01/2006 15:04", nil, "05/03/2024 10:30")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 5, 10, 30, 0, 0, time.UTC), v)
+
+	v, err = coerceFormattedValue(new(cache), "date", "DateOnly", nil, "2024-03-05")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), v)
+
+	v, err = coerceFormattedValue(new(cache), "timestamp", config.TimeFormatEpochMillis, nil, json.Number("1000"))
+	require.NoError(t, err)
+	assert.Equal(t, time.UnixMilli(1000).UTC(), v, "small values are not read as seconds")
+
+	v, err = coerceFormattedValue(new(cache), "timestamp", config.TimeFormatEpochSeconds, nil, json.Number("1e11"))
+	require.NoError(t, err)
+	assert.Equal(t, time.Unix(1e11, 0).UTC(), v, "large values are not read as milliseconds")
+
+	_, err = coerceFormattedValue(new(cache), "timestamp", "RFC3339", nil, "2024-03-05")
+	assert.EqualError(t, err, `"2024-03-05" does not match format RFC3339`)
+
+	v, err = coerceFormattedValue(new(cache), "int", "RFC3339", nil, json.Number("7"))
+	require.NoError(t, err)
+	assert.Equal(t, int32(7), v, "format only applies to time columns")
+}
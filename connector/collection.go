@@ -0,0 +1,178 @@
+package connector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/Trendyol/go-dcp-cassandra/config"
+)
+
+// coerceColumnValue is coerceValue for a column whose type may refer to the
+// user-defined types of userTypes, at any depth.
+func coerceColumnValue(c *cache, cqlType string, userTypes map[string]map[string]string, value interface{}) (interface{}, error) {
+	if value == nil || len(userTypes) == 0 || strings.TrimSpace(cqlType) == "" {
+		return coerceValue(c, cqlType, value)
+	}
+	t, err := c.parseCQLType(cqlType)
+	if err != nil {
+		return nil, err
+	}
+	return coerceCQLType(c, t, userTypes, value)
+}
+
+// coerceCQLType converts value to t, element by element for collections,
+// tuples and user-defined types. Lists, sets and tuples take a JSON array,
+// maps and user-defined types a JSON object, either decoded or as JSON
+// text. Cassandra collections cannot hold nulls, so a null collection
+// element is an error; tuple elements and user type fields may be null.
+func coerceCQLType(c *cache, t config.CQLType, userTypes map[string]map[string]string, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch t.Name {
+	case "list", "set":
+		return coerceList(c, t, userTypes, value)
+	case "map":
+		return coerceMap(c, t, userTypes, value)
+	case "tuple":
+		return coerceTuple(c, t, userTypes, value)
+	}
+	if fields, ok := userTypes[t.Name]; ok && t.IsUserType() {
+		return coerceUserType(c, t.Name, fields, userTypes, value)
+	}
+	if len(t.Params) > 0 {
+		return plainValue(value), nil
+	}
+	return coerceValue(c, t.Name, value)
+}
+
+func coerceList(c *cache, t config.CQLType, userTypes map[string]map[string]string, value interface{}) (interface{}, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		if err := decodeJSONText(value, &items); err != nil {
+			return nil, fmt.Errorf("cannot convert %T to a %s", value, t.Name)
+		}
+	}
+	out := make([]interface{}, len(items))
+	for i, item := range items {
+		if item == nil {
+			return nil, fmt.Errorf("element %d is null", i)
+		}
+		converted, err := coerceCQLType(c, t.Params[0], userTypes, item)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		out[i] = converted
+	}
+	return out, nil
+}
+
+func coerceMap(c *cache, t config.CQLType, userTypes map[string]map[string]string, value interface{}) (interface{}, error) {
+	entries, ok := value.(map[string]interface{})
+	if !ok {
+		if err := decodeJSONText(value, &entries); err != nil {
+			return nil, fmt.Errorf("cannot convert %T to a map", value)
+		}
+	}
+	out := make(map[interface{}]interface{}, len(entries))
+	for key, item := range entries {
+		if item == nil {
+			return nil, fmt.Errorf("value of %q is null", key)
+		}
+		convertedKey, err := coerceCQLType(c, t.Params[0], userTypes, key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", key, err)
+		}
+		if encoded, ok := convertedKey.([]byte); ok {
+			convertedKey = string(encoded)
+		}
+		if !reflect.TypeOf(convertedKey).Comparable() {
+			return nil, fmt.Errorf("map keys of type %s are not supported", t.Params[0])
+		}
+		converted, err := coerceCQLType(c, t.Params[1], userTypes, item)
+		if err != nil {
+			return nil, fmt.Errorf("value of %q: %w", key, err)
+		}
+		out[convertedKey] = converted
+	}
+	return out, nil
+}
+
+func coerceTuple(c *cache, t config.CQLType, userTypes map[string]map[string]string, value interface{}) (interface{}, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		if err := decodeJSONText(value, &items); err != nil {
+			return nil, fmt.Errorf("cannot convert %T to a tuple", value)
+		}
+	}
+	if len(items) != len(t.Params) {
+		return nil, fmt.Errorf("%s takes %d elements, got %d", t, len(t.Params), len(items))
+	}
+	out := make([]interface{}, len(items))
+	for i, item := range items {
+		converted, err := coerceCQLType(c, t.Params[i], userTypes, item)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		out[i] = converted
+	}
+	return out, nil
+}
+
+// coerceUserType converts a JSON object to the fields of a user-defined
+// type. Members are matched to fields by name, or ignoring case when there
+// is no exact match, since unquoted CQL names are lower case. Other members
+// are ignored and missing fields are written as null.
+func coerceUserType(
+	c *cache, name string, fields map[string]string, userTypes map[string]map[string]string, value interface{},
+) (interface{}, error) {
+	members, ok := value.(map[string]interface{})
+	if !ok {
+		if err := decodeJSONText(value, &members); err != nil {
+			return nil, fmt.Errorf("cannot convert %T to %s", value, name)
+		}
+	}
+	out := make(map[string]interface{}, len(fields))
+	for field, fieldType := range fields {
+		member, exists := members[field]
+		if !exists {
+			member, exists = memberIgnoringCase(members, field)
+		}
+		if !exists {
+			continue
+		}
+		t, err := c.parseCQLType(fieldType)
+		if err != nil {
+			return nil, err
+		}
+		converted, err := coerceCQLType(c, t, userTypes, member)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field, err)
+		}
+		out[field] = converted
+	}
+	return out, nil
+}
+
+func memberIgnoringCase(members map[string]interface{}, field string) (interface{}, bool) {
+	for key, member := range members {
+		if strings.EqualFold(key, field) {
+			return member, true
+		}
+	}
+	return nil, false
+}
+
+// decodeJSONText decodes value into target when it is a JSON text string.
+func decodeJSONText(value interface{}, target interface{}) error {
+	text, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("not JSON text")
+	}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(text)))
+	decoder.UseNumber()
+	return decoder.Decode(target)
+}
@@ -0,0 +1,35 @@
+package connector
+
+import (
+	"github.com/Trendyol/go-dcp-cassandra/cassandra"
+	"github.com/Trendyol/go-dcp-cassandra/couchbase"
+)
+
+// buildSoftDeleteModel turns the delete of a deletion or expiration into
+// an UPDATE of the same primary key that sets the deleted-at column to the
+// event time and the other softDelete columns to their values. Like
+// buildDeleteModel it returns false when the key cannot be resolved.
+func buildSoftDeleteModel(
+	mapping tableMapping, event couchbase.Event, sourceDocument map[string]interface{},
+) (cassandra.Raw, bool, error) {
+	model, ok, err := buildDeleteModel(mapping, event, sourceDocument)
+	if err != nil || !ok {
+		return model, false, err
+	}
+
+	deletedAt := mapping.SoftDelete.DeletedAtColumn
+	values := map[string]interface{}{deletedAt: event.EventTime.UTC()}
+	for column, value := range mapping.SoftDelete.Columns {
+		values[column] = value
+	}
+	update := make(map[string]interface{}, len(values))
+	for column, value := range values {
+		if update[column], err = convertFieldValue(mapping, column, value); err != nil {
+			return cassandra.Raw{}, false, err
+		}
+	}
+	model.Document = update
+	model.Operation = cassandra.Update
+	model.Consistency = resolveConsistency(mapping, cassandra.Update)
+	return model, true, nil
+}
@@ -0,0 +1,91 @@
+package connector
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/Trendyol/go-dcp-cassandra/cassandra"
+	"github.com/Trendyol/go-dcp-cassandra/config"
+	"github.com/Trendyol/go-dcp-cassandra/couchbase"
+)
+
+// buildExplodedModels maps a mutation of an exploded mapping to a range
+// delete of the rows from the array's length on, which removes elements the
+// array no longer has, followed by one upsert per element. Both share the
+// parent's key columns; the element position goes to the index column.
+func buildExplodedModels(
+	mapping tableMapping, event couchbase.Event, sourceDocument map[string]interface{},
+) ([]cassandra.Model, error) {
+	elements, err := explodedElements(mapping, sourceDocument)
+	if err != nil {
+		return nil, err
+	}
+	parent, err := buildUpsertModel(mapping, event, sourceDocument)
+	if err != nil {
+		return nil, err
+	}
+	indexColumn := mapping.IndexColumn()
+	length, err := convertFieldValue(mapping, indexColumn, len(elements))
+	if err != nil {
+		return nil, err
+	}
+
+	parentKey := make(map[string]interface{}, len(mapping.PrimaryKeyFields))
+	for _, column := range mapping.ParentKeyFields() {
+		parentKey[column] = parent.Document[column]
+	}
+	models := make([]cassandra.Model, 0, len(elements)+1)
+	models = append(models, &cassandra.Raw{
+		Table:          parent.Table,
+		Keyspace:       parent.Keyspace,
+		RoutedKeyspace: parent.RoutedKeyspace,
+		Filter:         parentKey,
+		FilterFrom:     map[string]interface{}{indexColumn: length},
+		Operation:      cassandra.Delete,
+		Consistency:    resolveConsistency(mapping, cassandra.Delete),
+	})
+
+	for index, element := range elements {
+		// Element sources read the element wrapped as the _element field,
+		// so _element.<path> resolves like any nested field.
+		elementDocument := map[string]interface{}{config.SourceElement: element}
+		row := maps.Clone(parent.Document)
+		for column, source := range mapping.FieldMappings {
+			if !config.IsElementSource(source) {
+				continue
+			}
+			var value interface{} = index
+			if source != config.SourceIndex {
+				if value, _, err = sourceFieldValue(mapping, column, elementDocument, true); err != nil {
+					return nil, err
+				}
+			}
+			if row[column], err = convertFieldValue(mapping, column, value); err != nil {
+				return nil, err
+			}
+		}
+		models = append(models, &cassandra.Raw{
+			Table:          parent.Table,
+			Keyspace:       parent.Keyspace,
+			RoutedKeyspace: parent.RoutedKeyspace,
+			Document:       row,
+			Operation:      cassandra.Upsert,
+			Consistency:    parent.Consistency,
+		})
+	}
+	return models, nil
+}
+
+// explodedElements returns the array the mapping explodes. A missing or
+// null array has no elements; any other value is an error.
+func explodedElements(mapping tableMapping, document map[string]interface{}) ([]interface{}, error) {
+	value, exists := getNestedField(mapping.cache, document, mapping.Explode)
+	if !exists || value == nil {
+		return nil, nil
+	}
+	elements, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot explode field %s: %T is not an array", mapping.Explode, value)
+	}
+	return elements, nil
+}
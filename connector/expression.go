@@ -0,0 +1,50 @@
+package connector
+
+import (
+	"fmt"
+
+	"github.com/Trendyol/go-dcp-cassandra/config"
+	"github.com/Trendyol/go-dcp-cassandra/couchbase"
+)
+
+// expressionValue evaluates the expr(...) source of a computed column.
+// _key and metadata sources resolve from event, any other identifier from
+// the top-level fields of document.
+func expressionValue(c *cache, source string, event couchbase.Event, document map[string]interface{}) (interface{}, error) {
+	expression, _ := config.ExpressionSource(source)
+	program, err := c.compileExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+	return program.Eval(func(name string) (interface{}, bool) {
+		switch {
+		case name == "_key":
+			return string(event.Key), true
+		case config.IsMetadataSource(name):
+			return metadataValue(event, name), true
+		}
+		value, exists := document[name]
+		return value, exists
+	})
+}
+
+// upsertExpressionValue is expressionValue for upserts. A null result takes
+// the field spec default, and is an error under nullPolicy error.
+func upsertExpressionValue(
+	mapping tableMapping, column string, event couchbase.Event, document map[string]interface{},
+) (interface{}, error) {
+	value, err := expressionValue(mapping.cache, mapping.FieldMappings[column], event, document)
+	if err != nil {
+		return nil, fmt.Errorf("cannot evaluate column %s: %w", column, err)
+	}
+	spec := mapping.FieldSpecs[column]
+	switch {
+	case value != nil:
+		return value, nil
+	case spec.Default != nil:
+		return spec.Default, nil
+	case spec.NullPolicy == config.NullPolicyError:
+		return nil, fmt.Errorf("expression for column %s is null", column)
+	}
+	return nil, nil
+}
@@ -0,0 +1,111 @@
+package connector
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"slices"
+
+	"github.com/Trendyol/go-dcp-cassandra/config"
+	"github.com/Trendyol/go-dcp-cassandra/couchbase"
+)
+
+// eventFilter evaluates the mapping filters of one event, parsing the
+// document only once and only if a rule needs a field.
+type eventFilter struct {
+	event    couchbase.Event
+	cache    *cache
+	document map[string]interface{}
+	parsed   bool
+}
+
+// passes reports whether filter lets the event through. A nil filter
+// passes every event.
+func (f *eventFilter) passes(filter *config.Filter) bool {
+	if filter == nil {
+		return true
+	}
+	if len(filter.Include) > 0 && !f.matchesAny(filter.Include) {
+		return false
+	}
+	return !f.matchesAny(filter.Exclude)
+}
+
+func (f *eventFilter) matchesAny(rules []config.FilterRule) bool {
+	for _, rule := range rules {
+		if f.matches(rule) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *eventFilter) matches(rule config.FilterRule) bool {
+	if rule.KeyPrefix != "" && !bytes.HasPrefix(f.event.Key, []byte(rule.KeyPrefix)) {
+		return false
+	}
+	if rule.KeyRegex != "" {
+		// Validated at config load.
+		pattern, err := f.cache.compileRegexp(rule.KeyRegex)
+		if err != nil || !pattern.Match(f.event.Key) {
+			return false
+		}
+	}
+	if len(rule.Events) > 0 && !slices.Contains(rule.Events, eventType(f.event)) {
+		return false
+	}
+	if rule.Field != "" {
+		value, exists := getNestedField(f.cache, f.parsedDocument(), rule.Field)
+		if rule.Exists != nil && exists != *rule.Exists {
+			return false
+		}
+		if rule.Equals != nil && (!exists || !valueEquals(value, rule.Equals)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *eventFilter) parsedDocument() map[string]interface{} {
+	if !f.parsed {
+		f.parsed = true
+		if f.event.Value != nil {
+			f.document, _ = parseDocument(f.event.Value)
+		}
+	}
+	return f.document
+}
+
+// valueEquals compares a document value with a configured one: numbers by
+// value, so that 1 in YAML equals 1.0 in JSON, anything else by text.
+func valueEquals(value, expected interface{}) bool {
+	if value == nil {
+		return false
+	}
+	if isNumber(value) && isNumber(expected) {
+		actualText, actualErr := numericText(value)
+		expectedText, expectedErr := numericText(expected)
+		if actualErr == nil && expectedErr == nil {
+			actual, actualOK := new(big.Rat).SetString(actualText)
+			expectedNumber, expectedOK := new(big.Rat).SetString(expectedText)
+			if actualOK && expectedOK {
+				return actual.Cmp(expectedNumber) == 0
+			}
+		}
+	}
+	actualText, err := toText(value)
+	if err != nil {
+		return false
+	}
+	expectedText, err := toText(expected)
+	return err == nil && actualText == expectedText
+}
+
+func isNumber(value interface{}) bool {
+	switch value.(type) {
+	case json.Number, float64, int, int64, uint64:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,212 @@
+package connector
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Trendyol/go-dcp-cassandra/cassandra"
+	"github.com/Trendyol/go-dcp-cassandra/config"
+	"github.com/Trendyol/go-dcp-cassandra/couchbase"
+)
+
+// Reasons an invalid document is counted under.
+const (
+	invalidReasonBinary     = "binary"
+	invalidReasonMalformed  = "malformed"
+	invalidReasonNotObject  = "not_object"
+	invalidReasonUnmappable = "unmappable"
+)
+
+// InvalidDocumentMetric counts the invalid documents of one table that its
+// invalidDocumentPolicy handled without failing. Policy is what was done
+// with them: a raw row that cannot be written is skipped instead.
+type InvalidDocumentMetric struct {
+	Table  string
+	Reason string
+	Policy string
+	Count  uint64
+}
+
+type invalidDocumentKey struct {
+	table, reason, policy string
+}
+
+// invalidDocumentError is an error of eventDocument, with the reason the
+// body is invalid.
+type invalidDocumentError struct {
+	err    error
+	reason string
+}
+
+func (e *invalidDocumentError) Error() string { return e.err.Error() }
+func (e *invalidDocumentError) Unwrap() error { return e.err }
+
+// invalidReason returns why cause made a document invalid. Errors that are
+// not about the body are about mapping a valid one.
+func invalidReason(cause error) string {
+	var invalid *invalidDocumentError
+	if errors.As(cause, &invalid) {
+		return invalid.reason
+	}
+	return invalidReasonUnmappable
+}
+
+// eventDocument parses the body of event. A body whose DCP datatype is
+// binary, that is not a single JSON value or whose value is not an object
+// is invalid. Deletions and expirations usually carry no body, which maps
+// like an empty document.
+func eventDocument(event couchbase.Event) (map[string]interface{}, error) {
+	if len(event.Value) == 0 && !event.IsMutated {
+		return make(map[string]interface{}), nil
+	}
+	if event.Binary {
+		return nil, &invalidDocumentError{fmt.Errorf("datatype 0x%02x is not plain JSON", event.Datatype), invalidReasonBinary}
+	}
+	document, err := parseDocument(event.Value)
+	if err != nil {
+		return nil, &invalidDocumentError{err, invalidReasonMalformed}
+	}
+	if document == nil {
+		return nil, &invalidDocumentError{errors.New("body is not a JSON object"), invalidReasonNotObject}
+	}
+	return document, nil
+}
+
+// invalidDocumentModels applies the mapping's invalidDocumentPolicy to an
+// event whose body eventDocument rejected, or that mappingModels could not
+// map, with cause. Under raw, deletions and expirations are mapped as if
+// they had no body. Every document that does not fail is counted.
+func (m *DefaultMapper) invalidDocumentModels(mapping tableMapping, event couchbase.Event, cause error) []cassandra.Model {
+	reason := invalidReason(cause)
+	switch mapping.InvalidDocumentPolicy {
+	case config.InvalidDocumentFail, "":
+		panic(fmt.Sprintf("invalid document %s for table %s: %v", event.Key, mapping.TableName, cause))
+	case config.InvalidDocumentDeadLetter:
+		m.countInvalidDocument(mapping.TableName, reason, config.InvalidDocumentDeadLetter)
+		return []cassandra.Model{deadLetterModel(mapping, event, cause)}
+	case config.InvalidDocumentRaw:
+		var models []cassandra.Model
+		var err error
+		if event.IsMutated {
+			models, err = rawModels(mapping, event)
+		} else {
+			models, err = mappingModels(mapping, event, make(map[string]interface{}))
+		}
+		if err == nil {
+			m.countInvalidDocument(mapping.TableName, reason, config.InvalidDocumentRaw)
+			return models
+		}
+		cause = err
+	}
+	m.countInvalidDocument(mapping.TableName, reason, config.InvalidDocumentSkip)
+	log.Printf("skipping invalid document %s for table %s: %v", event.Key, mapping.TableName, cause)
+	return nil
+}
+
+func (m *DefaultMapper) countInvalidDocument(table, reason, policy string) {
+	m.invalidDocumentsMu.Lock()
+	defer m.invalidDocumentsMu.Unlock()
+	if m.invalidDocuments == nil {
+		m.invalidDocuments = make(map[invalidDocumentKey]uint64)
+	}
+	m.invalidDocuments[invalidDocumentKey{table, reason, policy}]++
+}
+
+// InvalidDocumentMetrics returns how many invalid documents each table
+// handled without failing, by reason and policy, sorted by table.
+func (m *DefaultMapper) InvalidDocumentMetrics() []InvalidDocumentMetric {
+	m.invalidDocumentsMu.Lock()
+	defer m.invalidDocumentsMu.Unlock()
+	metrics := make([]InvalidDocumentMetric, 0, len(m.invalidDocuments))
+	for key, count := range m.invalidDocuments {
+		metrics = append(metrics, InvalidDocumentMetric{Table: key.table, Reason: key.reason, Policy: key.policy, Count: count})
+	}
+	slices.SortFunc(metrics, func(a, b InvalidDocumentMetric) int {
+		return cmp.Or(cmp.Compare(a.Table, b.Table), cmp.Compare(a.Reason, b.Reason), cmp.Compare(a.Policy, b.Policy))
+	})
+	return metrics
+}
+
+// rawModels maps a mutation under the raw policy to the row of buildRawModel,
+// written with the mapping's writeMode.
+func rawModels(mapping tableMapping, event couchbase.Event) ([]cassandra.Model, error) {
+	model, err := buildRawModel(mapping, event)
+	if err != nil {
+		return nil, err
+	}
+	model = writeModel(mapping, model)
+	return []cassandra.Model{&model}, nil
+}
+
+// buildRawModel writes the columns of mapping that do not read the body:
+// the key, its groups, metadata and documentData. A body that is not valid
+// UTF-8 can only be written to a blob documentData column.
+func buildRawModel(mapping tableMapping, event couchbase.Event) (cassandra.Raw, error) {
+	targetDocument := make(map[string]interface{})
+	for column, source := range mapping.FieldMappings {
+		var value interface{}
+		switch {
+		case source == "_key":
+			value = string(event.Key)
+		case source == "documentData":
+			cqlType := strings.ToLower(strings.TrimSpace(mapping.ColumnTypes[column]))
+			if cqlType != "blob" && !utf8.Valid(event.Value) {
+				return cassandra.Raw{}, fmt.Errorf("body is not valid UTF-8 and column %s is not a blob", column)
+			}
+			value = string(event.Value)
+		case config.IsMetadataSource(source):
+			value = metadataValue(event, source)
+		case isKeyGroupSource(source):
+			var err error
+			if value, err = columnKeyGroupValue(mapping, column, event.Key, source); err != nil {
+				return cassandra.Raw{}, err
+			}
+		default:
+			continue
+		}
+		converted, err := convertFieldValue(mapping, column, value)
+		if err != nil {
+			return cassandra.Raw{}, err
+		}
+		targetDocument[column] = converted
+	}
+
+	table, keyspace, err := resolveTarget(mapping, event, nil)
+	if err != nil {
+		return cassandra.Raw{}, err
+	}
+	return cassandra.Raw{
+		Table:          table,
+		Keyspace:       keyspace,
+		RoutedKeyspace: isRoutedKeyspace(mapping),
+		Document:       targetDocument,
+		Operation:      cassandra.Upsert,
+		Consistency:    resolveConsistency(mapping, cassandra.Upsert),
+	}, nil
+}
+
+// deadLetterModel records event in the mapping's dead-letter table, with
+// the table it was meant for and why it was rejected.
+func deadLetterModel(mapping tableMapping, event couchbase.Event, cause error) *cassandra.Raw {
+	keyspace, table := mapping.DeadLetterTarget()
+	return &cassandra.Raw{
+		Table:    table,
+		Keyspace: keyspace,
+		Document: map[string]interface{}{
+			config.DeadLetterKeyColumn:        string(event.Key),
+			config.DeadLetterCollectionColumn: qualifiedCollection(event.ScopeName, event.CollectionName),
+			config.DeadLetterTableColumn:      mapping.TableName,
+			config.DeadLetterEventTimeColumn:  event.EventTime.UTC(),
+			config.DeadLetterDatatypeColumn:   int32(event.Datatype),
+			config.DeadLetterValueColumn:      event.Value,
+			config.DeadLetterErrorColumn:      cause.Error(),
+		},
+		Operation:   cassandra.Upsert,
+		Consistency: resolveConsistency(mapping, cassandra.Upsert),
+	}
+}
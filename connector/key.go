@@ -0,0 +1,45 @@
+package connector
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Trendyol/go-dcp-cassandra/config"
+)
+
+// isKeyGroupSource reports whether source reads a named group of the
+// mapping's keyPattern.
+func isKeyGroupSource(source string) bool {
+	return strings.HasPrefix(source, config.KeyGroupSourcePrefix)
+}
+
+// keyGroupValue returns the named group of the mapping's keyPattern in key.
+// A group that did not participate in the match is nil.
+func keyGroupValue(mapping tableMapping, key []byte, source string) (interface{}, error) {
+	group := strings.TrimPrefix(source, config.KeyGroupSourcePrefix)
+	pattern, err := mapping.cache.compileRegexp(mapping.KeyPattern)
+	if err != nil {
+		return nil, err
+	}
+	index := pattern.SubexpIndex(group)
+	if index < 0 {
+		return nil, fmt.Errorf("keyPattern has no group named %q", group)
+	}
+	match := pattern.FindSubmatchIndex(key)
+	if match == nil {
+		return nil, fmt.Errorf("key does not match keyPattern %s", mapping.KeyPattern)
+	}
+	if match[2*index] < 0 {
+		return nil, nil
+	}
+	return string(key[match[2*index]:match[2*index+1]]), nil
+}
+
+// columnKeyGroupValue is keyGroupValue for the source of column.
+func columnKeyGroupValue(mapping tableMapping, column string, key []byte, source string) (interface{}, error) {
+	value, err := keyGroupValue(mapping, key, source)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve %s for column %s: %w", source, column, err)
+	}
+	return value, nil
+}
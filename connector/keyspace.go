@@ -0,0 +1,121 @@
+package connector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Trendyol/go-dcp-cassandra/config"
+	"github.com/Trendyol/go-dcp-cassandra/couchbase"
+)
+
+// resolveTarget returns the table and keyspace a document of mapping is
+// written to. An empty keyspace keeps the cluster keyspace. Placeholders in
+// the mapping's table name and keyspace are replaced by the document key
+// prefix ({_keyPrefix}), a keyPattern group ({_key.<group>}), the event's
+// scope or collection ({_scope}, {_collection}, {_match.<group>}) or a
+// document field. It fails when a placeholder cannot be resolved or a
+// result is not a valid name.
+func resolveTarget(
+	mapping tableMapping, event couchbase.Event, document map[string]interface{},
+) (table, keyspace string, err error) {
+	table, err = resolveName("table", mapping.TableName, mapping, event, document)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot resolve table %s: %w", mapping.TableName, err)
+	}
+	keyspace, err = resolveName("keyspace", mapping.Keyspace, mapping, event, document)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot resolve keyspace %s: %w", mapping.Keyspace, err)
+	}
+	return table, keyspace, nil
+}
+
+// isRoutedKeyspace reports whether the keyspace of mapping is resolved per
+// document, in which case every dual-write cluster writes to it.
+func isRoutedKeyspace(mapping tableMapping) bool {
+	_, static := mapping.StaticKeyspace("")
+	return !static
+}
+
+func resolveName(
+	kind, template string, mapping tableMapping, event couchbase.Event, document map[string]interface{},
+) (string, error) {
+	if !strings.Contains(template, "{") {
+		return template, nil
+	}
+
+	var resolveErr error
+	name := config.ReplacePlaceholders(template, func(source string) string {
+		value, err := placeholderValue(mapping, source, event, document)
+		if err != nil && resolveErr == nil {
+			resolveErr = err
+		}
+		return value
+	})
+	if resolveErr == nil && !config.IsValidName(name) {
+		resolveErr = fmt.Errorf("%q is not a valid %s name", name, kind)
+	}
+	return name, resolveErr
+}
+
+func placeholderValue(
+	mapping tableMapping, source string, event couchbase.Event, document map[string]interface{},
+) (string, error) {
+	switch {
+	case source == config.KeyPrefixPlaceholder:
+		prefix, _, found := strings.Cut(string(event.Key), mapping.KeyPrefixSeparator)
+		if !found {
+			return "", fmt.Errorf("key has no %q separator", mapping.KeyPrefixSeparator)
+		}
+		return prefix, nil
+	case source == config.ScopePlaceholder:
+		return event.ScopeName, nil
+	case source == config.CollectionPlaceholder:
+		return event.CollectionName, nil
+	case strings.HasPrefix(source, config.MatchPlaceholderPrefix):
+		return collectionGroup(mapping, event, strings.TrimPrefix(source, config.MatchPlaceholderPrefix))
+	case isKeyGroupSource(source):
+		value, err := keyGroupValue(mapping, event.Key, source)
+		if err != nil {
+			return "", err
+		}
+		if value == nil {
+			return "", fmt.Errorf("group %s is empty", source)
+		}
+		return value.(string), nil
+	}
+
+	value, exists := getNestedField(mapping.cache, document, source)
+	if !exists || value == nil {
+		return "", fmt.Errorf("field %s is missing", source)
+	}
+	text, err := toText(value)
+	if err != nil {
+		return "", err
+	}
+	return text.(string), nil
+}
+
+// collectionGroup returns a group, by name or number, that the mapping's
+// collection pattern captured from the event's scope and collection.
+func collectionGroup(mapping tableMapping, event couchbase.Event, group string) (string, error) {
+	pattern, err := mapping.cache.compileRegexp(mapping.CollectionPattern())
+	if err != nil {
+		return "", err
+	}
+	index, err := strconv.Atoi(group)
+	if err != nil {
+		index = pattern.SubexpIndex(group)
+	}
+	match := pattern.FindStringSubmatch(qualifiedCollection(event.ScopeName, event.CollectionName))
+	if match == nil || index < 1 || index >= len(match) || match[index] == "" {
+		return "", fmt.Errorf("collection group %s is empty", group)
+	}
+	return match[index], nil
+}
+
+// qualifiedCollection is the "scope.collection" name collection patterns
+// are matched against.
+func qualifiedCollection(scopeName, collectionName string) string {
+	return scopeName + "." + collectionName
+}
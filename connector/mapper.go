@@ -1,10 +1,11 @@
 package connector
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"strconv"
-	"strings"
+	"log"
+	"maps"
+	"slices"
 	"sync"
 
 	"github.com/Trendyol/go-dcp-cassandra/cassandra"
@@ -12,190 +13,447 @@ import (
 	"github.com/Trendyol/go-dcp-cassandra/couchbase"
 )
 
-var (
-	collectionTableMappings *[]config.CollectionTableMapping
-	mappingCache            = make(map[string]config.CollectionTableMapping)
-	mappingCacheMu          sync.RWMutex
-)
+// DefaultMapper maps events to the rows of their collection's
+// collectionTableMapping entries. It is safe for concurrent use.
+type DefaultMapper struct {
+	mappings []config.CollectionTableMapping
+	cache    cache
+	// matches caches the indexes into mappings of each scope.collection.
+	matches   map[string][]int
+	matchesMu sync.RWMutex
+	// invalidDocuments counts the invalid documents that did not fail.
+	invalidDocuments   map[invalidDocumentKey]uint64
+	invalidDocumentsMu sync.Mutex
+}
 
-func SetCollectionTableMappings(mappings *[]config.CollectionTableMapping) {
-	mappingCacheMu.Lock()
-	defer mappingCacheMu.Unlock()
-	collectionTableMappings = mappings
-	mappingCache = make(map[string]config.CollectionTableMapping)
+// NewDefaultMapper returns a mapper for mappings, which should have passed
+// config validation. Collection and key patterns are compiled up front.
+// Column types read from the schema are added with SetSchemaTypes.
+func NewDefaultMapper(mappings []config.CollectionTableMapping) (*DefaultMapper, error) {
+	if len(mappings) == 0 {
+		return nil, errors.New("no collectionTableMapping configured")
+	}
+	mapper := &DefaultMapper{mappings: slices.Clone(mappings), matches: make(map[string][]int)}
+	for _, mapping := range mappings {
+		for _, source := range []string{mapping.CollectionPattern(), mapping.KeyPattern} {
+			if source == "" {
+				continue
+			}
+			if _, err := mapper.cache.compileRegexp(source); err != nil {
+				return nil, fmt.Errorf("invalid pattern of table %s: %w", mapping.TableName, err)
+			}
+		}
+	}
+	if err := mapper.CheckDefaults(); err != nil {
+		return nil, err
+	}
+	return mapper, nil
 }
 
-func DefaultMapper(event couchbase.Event) []cassandra.Model {
-	if event.IsMutated {
-		mapping := findCollectionTableMapping(event.CollectionName)
-		model := buildUpsertModel(mapping, event)
-		return []cassandra.Model{&model}
-	} else if event.IsDeleted || event.IsExpired {
-		mapping := findCollectionTableMapping(event.CollectionName)
-		model := buildDeleteModel(mapping, event)
-		return []cassandra.Model{&model}
+// SetSchemaTypes adds the column and user-defined types cassandra.ValidateSchema
+// read for each mapping, in the order NewDefaultMapper got them. Types
+// declared in config win. The mappings passed to NewDefaultMapper are not
+// modified. It must be called before Map.
+func (m *DefaultMapper) SetSchemaTypes(types []cassandra.SchemaTypes) {
+	mappings := slices.Clone(m.mappings)
+	for i := range min(len(types), len(mappings)) {
+		mappings[i].ColumnTypes = mergeTypes(types[i].ColumnTypes, mappings[i].ColumnTypes)
+		mappings[i].UserTypes = mergeTypes(types[i].UserTypes, mappings[i].UserTypes)
 	}
-	return nil
+	m.mappings = mappings
 }
 
-func findCollectionTableMapping(collectionName string) config.CollectionTableMapping {
-	mappingCacheMu.RLock()
-	if mapping, exists := mappingCache[collectionName]; exists {
-		mappingCacheMu.RUnlock()
-		return mapping
+// mergeTypes returns declared with the entries of discovered it lacks, in a
+// new map when there are any.
+func mergeTypes[V any](discovered, declared map[string]V) map[string]V {
+	if len(discovered) == 0 {
+		return declared
 	}
-	mappingCacheMu.RUnlock()
+	merged := maps.Clone(discovered)
+	maps.Copy(merged, declared)
+	return merged
+}
 
-	mappingCacheMu.Lock()
-	defer mappingCacheMu.Unlock()
+// CheckDefaults returns an error when a field spec default cannot be
+// converted to the type of its column. NewDefaultMapper checks the types
+// declared in config; call it again after SetSchemaTypes.
+func (m *DefaultMapper) CheckDefaults() error {
+	for i := range m.mappings {
+		mapping := m.mapping(i)
+		for column, spec := range mapping.FieldSpecs {
+			if spec.Default == nil {
+				continue
+			}
+			if _, err := convertFieldValue(mapping, column, spec.Default); err != nil {
+				return fmt.Errorf("invalid default of table %s: %w", mapping.TableName, err)
+			}
+		}
+	}
+	return nil
+}
 
-	if mapping, exists := mappingCache[collectionName]; exists {
-		return mapping
+// CheckCollections returns an error when a collection of scopeName has no
+// mapping, not even one of the default collection.
+func (m *DefaultMapper) CheckCollections(scopeName string, collectionNames []string) error {
+	for _, collectionName := range collectionNames {
+		if len(m.match(scopeName, collectionName)) == 0 {
+			return fmt.Errorf("no collectionTableMapping for collection %s", qualifiedCollection(scopeName, collectionName))
+		}
 	}
+	return nil
+}
 
-	if collectionTableMappings == nil {
-		panic("collectionTableMappings is not initialized. Call SetCollectionTableMappings first.")
+// Map returns one model per table mapped to the event's collection whose
+// filter passes the event, in configuration order. Deletions and
+// expirations follow each table's deleteMode. A document that cannot be
+// parsed, or mapped to a table, is handled by the table's
+// invalidDocumentPolicy.
+func (m *DefaultMapper) Map(event couchbase.Event) []cassandra.Model {
+	if !event.IsMutated && !event.IsDeleted && !event.IsExpired {
+		return nil
 	}
 
-	for _, mapping := range *collectionTableMappings {
-		if mapping.Collection == collectionName {
-			mappingCache[collectionName] = mapping
-			return mapping
+	indexes := m.match(event.ScopeName, event.CollectionName)
+	models := make([]cassandra.Model, 0, len(indexes))
+	filter := eventFilter{event: event, cache: &m.cache}
+	document, invalid := eventDocument(event)
+	for _, i := range indexes {
+		mapping := m.mapping(i)
+		if !filter.passes(mapping.Filter) {
+			continue
 		}
+		if !event.IsMutated && mapping.DeleteMode.For(event.IsExpired) == config.DeleteModeIgnore {
+			continue
+		}
+		if invalid != nil {
+			models = append(models, m.invalidDocumentModels(mapping, event, invalid)...)
+			continue
+		}
+		mapped, err := mappingModels(mapping, event, document)
+		if err != nil {
+			mapped = m.invalidDocumentModels(mapping, event, err)
+		}
+		models = append(models, mapped...)
 	}
+	return models
+}
 
-	for _, mapping := range *collectionTableMappings {
-		if mapping.Collection == "" || mapping.Collection == "_default" {
-			mappingCache[collectionName] = mapping
-			return mapping
-		}
+// mappingModels maps an event with a valid document to the models of one
+// table. It fails when a value of the document cannot be mapped.
+func mappingModels(
+	mapping tableMapping, event couchbase.Event, document map[string]interface{},
+) ([]cassandra.Model, error) {
+	var (
+		model cassandra.Raw
+		ok    = true
+		err   error
+	)
+	switch {
+	case event.IsMutated && mapping.Explode != "":
+		return buildExplodedModels(mapping, event, document)
+	case event.IsMutated:
+		model, err = buildUpsertModel(mapping, event, document)
+		model = writeModel(mapping, model)
+	case mapping.DeleteMode.For(event.IsExpired) == config.DeleteModeSoft:
+		model, ok, err = buildSoftDeleteModel(mapping, event, document)
+	default:
+		model, ok, err = buildDeleteModel(mapping, event, document)
+	}
+	if err != nil || !ok {
+		return nil, err
 	}
+	return []cassandra.Model{&model}, nil
+}
 
-	panic(fmt.Sprintf("no mapping found for collection: %s", collectionName))
+// mapping returns the mapping at index i with the mapper's cache. It is
+// read from mappings on every call, so that the types added by
+// SetSchemaTypes are used.
+func (m *DefaultMapper) mapping(i int) tableMapping {
+	return tableMapping{CollectionTableMapping: m.mappings[i], cache: &m.cache}
 }
 
-func getNestedField(document map[string]interface{}, fieldPath string) (interface{}, bool) {
-	if strings.Contains(fieldPath, ".") {
-		parts := strings.Split(fieldPath, ".")
-		current := document
+// match returns the indexes of every mapping whose collection pattern
+// matches scopeName.collectionName, or of the mappings of the default
+// collection when there is none. A collection without mappings is logged
+// once and then acked without writes.
+func (m *DefaultMapper) match(scopeName, collectionName string) []int {
+	name := qualifiedCollection(scopeName, collectionName)
+	m.matchesMu.RLock()
+	if indexes, exists := m.matches[name]; exists {
+		m.matchesMu.RUnlock()
+		return indexes
+	}
+	m.matchesMu.RUnlock()
 
-		for i, part := range parts {
-			if i == len(parts)-1 {
-				if value, exists := current[part]; exists {
-					return value, true
-				}
-				return nil, false
-			} else {
-				if value, exists := current[part]; exists {
-					if nested, ok := value.(map[string]interface{}); ok {
-						current = nested
-					} else {
-						return nil, false
-					}
-				} else {
-					return nil, false
-				}
-			}
-		}
+	m.matchesMu.Lock()
+	defer m.matchesMu.Unlock()
+
+	if indexes, exists := m.matches[name]; exists {
+		return indexes
 	}
 
-	if value, exists := document[fieldPath]; exists {
-		return value, true
+	var matched []int
+	for i, mapping := range m.mappings {
+		source := mapping.CollectionPattern()
+		if source == "" {
+			continue
+		}
+		// Patterns are compiled by NewDefaultMapper.
+		if pattern, err := m.cache.compileRegexp(source); err == nil && pattern.MatchString(name) {
+			matched = append(matched, i)
+		}
 	}
-	return nil, false
-}
 
-func convertFieldValue(fieldPath string, value interface{}) interface{} {
-	if fieldPath == "date" {
-		switch v := value.(type) {
-		case float64:
-			return v
-		case string:
-			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
-				return parsed
+	if len(matched) == 0 {
+		for i, mapping := range m.mappings {
+			if mapping.IsDefaultCollectionMapping() {
+				matched = append(matched, i)
 			}
-			return 0.0
-		case int:
-			return float64(v)
-		case int64:
-			return float64(v)
 		}
 	}
 
-	return value
+	if len(matched) == 0 {
+		log.Printf("no collectionTableMapping for collection %s, its events are not written", name)
+	}
+	m.matches[name] = matched
+	return matched
 }
 
-func buildUpsertModel(mapping config.CollectionTableMapping, event couchbase.Event) cassandra.Raw {
-	var sourceDocument map[string]interface{}
-	if err := json.Unmarshal(event.Value, &sourceDocument); err != nil {
-		sourceDocument = make(map[string]interface{})
+// convertFieldValue coerces value to the column's CQL type, taken from the
+// mapping's columnTypes (declared in config or read from system_schema at
+// startup). A value that cannot be represented in that type is an error
+// rather than being silently written as something else. cassandra.Unset is
+// kept as it is.
+func convertFieldValue(mapping tableMapping, column string, value interface{}) (interface{}, error) {
+	if value == cassandra.Unset {
+		return value, nil
+	}
+	cqlType := mapping.ColumnTypes[column]
+	converted, err := coerceFormattedValue(mapping.cache, cqlType, mapping.FieldSpecs[column].Format, mapping.UserTypes, value)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert column %s to %s: %w", column, cqlType, err)
 	}
+	return converted, nil
+}
 
+// buildUpsertModel maps sourceDocument to a row. Element sources of exploded
+// mappings are left out; buildExplodedModels fills them per element.
+func buildUpsertModel(
+	mapping tableMapping, event couchbase.Event, sourceDocument map[string]interface{},
+) (cassandra.Raw, error) {
 	targetDocument := make(map[string]interface{})
 
 	for cassandraColumn, sourceField := range mapping.FieldMappings {
-		switch sourceField {
-		case "_key":
-			targetDocument[cassandraColumn] = string(event.Key)
-		case "documentData":
-			targetDocument[cassandraColumn] = string(event.Value)
-		default:
-			if fieldValue, exists := getNestedField(sourceDocument, sourceField); exists {
-				targetDocument[cassandraColumn] = convertFieldValue(sourceField, fieldValue)
-			} else {
-				targetDocument[cassandraColumn] = nil
-			}
+		if config.IsElementSource(sourceField) {
+			continue
+		}
+		value, err := upsertSourceValue(mapping, cassandraColumn, event, sourceDocument)
+		if err != nil {
+			return cassandra.Raw{}, err
+		}
+		if targetDocument[cassandraColumn], err = convertFieldValue(mapping, cassandraColumn, value); err != nil {
+			return cassandra.Raw{}, err
 		}
 	}
 
+	table, keyspace, err := resolveTarget(mapping, event, sourceDocument)
+	if err != nil {
+		return cassandra.Raw{}, err
+	}
 	return cassandra.Raw{
-		Table:     mapping.TableName,
-		Document:  targetDocument,
-		Operation: cassandra.Upsert,
+		Table:          table,
+		Keyspace:       keyspace,
+		RoutedKeyspace: isRoutedKeyspace(mapping),
+		Document:       targetDocument,
+		Operation:      cassandra.Upsert,
+		Consistency:    resolveConsistency(mapping, cassandra.Upsert),
+	}, nil
+}
+
+// upsertSourceValue reads the value an upsert writes to column from its
+// source, before conversion.
+func upsertSourceValue(
+	mapping tableMapping, column string, event couchbase.Event, sourceDocument map[string]interface{},
+) (interface{}, error) {
+	switch sourceField := mapping.FieldMappings[column]; {
+	case sourceField == "_key":
+		return string(event.Key), nil
+	case sourceField == "documentData":
+		return string(event.Value), nil
+	case config.IsMetadataSource(sourceField):
+		return metadataValue(event, sourceField), nil
+	case isKeyGroupSource(sourceField):
+		return columnKeyGroupValue(mapping, column, event.Key, sourceField)
+	case config.IsExpressionSource(sourceField):
+		return upsertExpressionValue(mapping, column, event, sourceDocument)
 	}
+	value, _, err := sourceFieldValue(mapping, column, sourceDocument, true)
+	return value, err
 }
 
-func buildDeleteModel(mapping config.CollectionTableMapping, event couchbase.Event) cassandra.Raw {
-	var sourceDocument map[string]interface{}
-	if event.Value != nil {
-		err := json.Unmarshal(event.Value, &sourceDocument)
-		if err != nil {
-			panic(err)
+// writeModel turns the upsert row of mapping into the statement of its
+// writeMode: an INSERT IF NOT EXISTS, or an UPDATE of the columns outside
+// primaryKeyFields keyed by them.
+func writeModel(mapping tableMapping, row cassandra.Raw) cassandra.Raw {
+	switch mapping.WriteMode {
+	case config.WriteModeInsertIfNotExists:
+		row.Operation = cassandra.Insert
+	case config.WriteModeUpdateExisting:
+		row.Operation, row.IfExists = cassandra.Update, mapping.IfExists
+		row.Filter = make(map[string]interface{}, len(mapping.PrimaryKeyFields))
+		for _, column := range mapping.PrimaryKeyFields {
+			row.Filter[column] = row.Document[column]
+			delete(row.Document, column)
 		}
+	default:
+		return row
 	}
-	if sourceDocument == nil {
-		sourceDocument = make(map[string]interface{})
-	}
+	row.Consistency = resolveConsistency(mapping, row.Operation)
+	return row
+}
 
+// buildDeleteModel returns false when the mapping has primaryKeyFields and
+// not all of them can be resolved from the event: a DELETE by a partial
+// primary key is rejected by Cassandra and would fail the whole flush.
+func buildDeleteModel(
+	mapping tableMapping, event couchbase.Event, sourceDocument map[string]interface{},
+) (cassandra.Raw, bool, error) {
 	filter := make(map[string]interface{})
 
-	for cassandraColumn, sourceField := range mapping.FieldMappings {
-		switch sourceField {
-		case "_key":
-			filter[cassandraColumn] = string(event.Key)
-		case "documentData":
-		default:
-			if fieldValue, exists := getNestedField(sourceDocument, sourceField); exists {
-				filter[cassandraColumn] = convertFieldValue(sourceField, fieldValue)
-			}
+	for cassandraColumn := range mapping.FieldMappings {
+		value, exists, err := deleteSourceValue(mapping, cassandraColumn, event, sourceDocument)
+		if err != nil {
+			return cassandra.Raw{}, false, err
+		}
+		if !exists {
+			continue
+		}
+		if filter[cassandraColumn], err = convertFieldValue(mapping, cassandraColumn, value); err != nil {
+			return cassandra.Raw{}, false, err
 		}
 	}
 
-	if len(mapping.PrimaryKeyFields) > 0 {
-		pkSet := make(map[string]struct{}, len(mapping.PrimaryKeyFields))
-		for _, pk := range mapping.PrimaryKeyFields {
-			pkSet[pk] = struct{}{}
+	// The rows of an exploded document share its parent key, so deleting by
+	// it removes all of them.
+	keyFields := mapping.PrimaryKeyFields
+	if mapping.Explode != "" {
+		keyFields = mapping.ParentKeyFields()
+	}
+	if !restrictToKey(filter, keyFields) {
+		log.Printf("skipping delete of document %s from table %s: primary key %v cannot be resolved from the event",
+			event.Key, mapping.TableName, keyFields)
+		return cassandra.Raw{}, false, nil
+	}
+
+	table, keyspace, err := resolveTarget(mapping, event, sourceDocument)
+	if err != nil {
+		return cassandra.Raw{}, false, err
+	}
+	return cassandra.Raw{
+		Table:          table,
+		Keyspace:       keyspace,
+		RoutedKeyspace: isRoutedKeyspace(mapping),
+		Filter:         filter,
+		Operation:      cassandra.Delete,
+		Consistency:    resolveConsistency(mapping, cassandra.Delete),
+	}, true, nil
+}
+
+// deleteSourceValue reads the value a delete filters column by from its
+// source, before conversion. exists is false for columns that cannot
+// identify the row.
+func deleteSourceValue(
+	mapping tableMapping, column string, event couchbase.Event, sourceDocument map[string]interface{},
+) (value interface{}, exists bool, err error) {
+	switch sourceField := mapping.FieldMappings[column]; {
+	case sourceField == "_key":
+		return string(event.Key), true, nil
+	case sourceField == "documentData", config.IsElementSource(sourceField):
+		return nil, false, nil
+	case config.IsMetadataSource(sourceField):
+		// Metadata never identifies a row unless it is part of the
+		// configured primary key.
+		return metadataValue(event, sourceField), len(mapping.PrimaryKeyFields) > 0, nil
+	case isKeyGroupSource(sourceField):
+		value, err = columnKeyGroupValue(mapping, column, event.Key, sourceField)
+		return value, err == nil, err
+	case config.IsExpressionSource(sourceField):
+		// Like metadata, computed columns only identify a row as part of
+		// the primary key; one that cannot be evaluated from the delete
+		// event leaves the key unresolved.
+		if len(mapping.PrimaryKeyFields) == 0 {
+			return nil, false, nil
 		}
-		for col := range filter {
-			if _, ok := pkSet[col]; !ok {
-				delete(filter, col)
-			}
+		value, err = expressionValue(mapping.cache, sourceField, event, sourceDocument)
+		return value, err == nil && value != nil, nil
+	}
+	return sourceFieldValue(mapping, column, sourceDocument, false)
+}
+
+// restrictToKey removes the columns that are not in keyFields from filter,
+// unless keyFields is empty, and reports whether every key column is left.
+func restrictToKey(filter map[string]interface{}, keyFields []string) bool {
+	if len(keyFields) == 0 {
+		return true
+	}
+	pkSet := make(map[string]struct{}, len(keyFields))
+	for _, pk := range keyFields {
+		pkSet[pk] = struct{}{}
+	}
+	for col := range filter {
+		if _, ok := pkSet[col]; !ok {
+			delete(filter, col)
 		}
 	}
+	return len(filter) == len(pkSet)
+}
+
+// sourceFieldValue reads the source field of column from document and
+// applies the column's field spec and missing field policy: a missing field
+// is unset, takes the spec default, or is an error under missingFieldPolicy
+// error, and a null one is handled by nullPolicy. Only the default applies
+// without strict, which deletes pass since their documents are usually
+// empty. exists is false when there is neither a value nor a default.
+func sourceFieldValue(
+	mapping tableMapping, column string, document map[string]interface{}, strict bool,
+) (value interface{}, exists bool, err error) {
+	spec := mapping.FieldSpecs[column]
+	source := mapping.FieldMappings[column]
+
+	value, exists = getNestedField(mapping.cache, document, source)
+	if !exists {
+		return missingFieldValue(mapping, column, strict)
+	}
+	switch {
+	case value == nil && spec.NullPolicy == config.NullPolicyDefault:
+		return spec.Default, true, nil
+	case value == nil && spec.NullPolicy == config.NullPolicyError && strict:
+		return nil, false, fmt.Errorf("field %s for column %s is null", source, column)
+	}
+	return value, true, nil
+}
 
-	return cassandra.Raw{
-		Table:     mapping.TableName,
-		Filter:    filter,
-		Operation: cassandra.Delete,
+func missingFieldValue(mapping tableMapping, column string, strict bool) (interface{}, bool, error) {
+	switch policy := mapping.MissingFieldPolicyOf(column); {
+	case policy == config.MissingFieldDefault && mapping.FieldSpecs[column].Default != nil:
+		return mapping.FieldSpecs[column].Default, true, nil
+	case !strict:
+	case policy == config.MissingFieldError:
+		return nil, false, fmt.Errorf("required field %s for column %s is missing", mapping.FieldMappings[column], column)
+	case policy == config.MissingFieldUnset:
+		return cassandra.Unset, true, nil
+	}
+	return nil, false, nil
+}
+
+// resolveConsistency returns the mapping's consistency for operation: the
+// per-operation override first, then the table-wide one. Both are validated
+// at config load, so parse errors cannot occur here.
+func resolveConsistency(mapping tableMapping, operation cassandra.OperationType) cassandra.Consistency {
+	name, ok := mapping.OperationConsistency[string(operation)]
+	if !ok {
+		name = mapping.Consistency
 	}
+	consistency, _ := cassandra.ParseConsistency(name)
+	return consistency
 }
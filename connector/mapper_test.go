@@ -13,6 +13,13 @@ import (
 	"github.com/Trendyol/go-dcp-cassandra/couchbase"
 )
 
+func newTestMapper(t *testing.T, mappings []config.CollectionTableMapping) *DefaultMapper {
+	t.Helper()
+	mapper, err := NewDefaultMapper(mappings)
+	require.NoError(t, err)
+	return mapper
+}
+
 func TestDefaultMapper_Mutation(t *testing.T) {
 	mappings := []config.CollectionTableMapping{
 		{
@@ -24,7 +31,7 @@ func TestDefaultMapper_Mutation(t *testing.T) {
 			},
 		},
 	}
-	SetCollectionTableMappings(&mappings)
+	mapper := newTestMapper(t, mappings)
 
 	jsonData := `{"id": "meta_id_123", "partitionId": "part_123", "status": "active", "meta": {"id": "meta_id_123", "version": "1.0"}}`
 	event := couchbase.NewMutateEvent(
@@ -36,7 +43,7 @@ func TestDefaultMapper_Mutation(t *testing.T) {
 		1,
 	)
 
-	result := DefaultMapper(event)
+	result := mapper.Map(event)
 
 	assert.Len(t, result, 1)
 
@@ -60,7 +67,7 @@ func TestDefaultMapper_Deletion(t *testing.T) {
 			},
 		},
 	}
-	SetCollectionTableMappings(&mappings)
+	mapper := newTestMapper(t, mappings)
 
 	jsonData := `{"partitionId": "part_123"}`
 	event := couchbase.NewDeleteEvent(
@@ -72,7 +79,7 @@ func TestDefaultMapper_Deletion(t *testing.T) {
 		1,
 	)
 
-	result := DefaultMapper(event)
+	result := mapper.Map(event)
 
 	assert.Len(t, result, 1)
 
@@ -95,7 +102,7 @@ func TestDefaultMapper_Expiration(t *testing.T) {
 			},
 		},
 	}
-	SetCollectionTableMappings(&mappings)
+	mapper := newTestMapper(t, mappings)
 
 	event := couchbase.NewExpireEvent(
 		[]byte("doc_key"),
@@ -106,7 +113,7 @@ func TestDefaultMapper_Expiration(t *testing.T) {
 		1,
 	)
 
-	result := DefaultMapper(event)
+	result := mapper.Map(event)
 
 	assert.Len(t, result, 1)
 
@@ -118,7 +125,7 @@ func TestDefaultMapper_Expiration(t *testing.T) {
 	assert.Equal(t, "doc_key", rawModel.Filter["id"])
 }
 
-// Regression: concurrent mapper access must not race on mappingCache.
+// Regression: concurrent mapper access must not race on the mapping cache.
 func TestConcurrentMapperAccess(t *testing.T) {
 	mappings := []config.CollectionTableMapping{
 		{
@@ -132,7 +139,7 @@ func TestConcurrentMapperAccess(t *testing.T) {
 			FieldMappings: map[string]string{"id": "_key"},
 		},
 	}
-	SetCollectionTableMappings(&mappings)
+	mapper := newTestMapper(t, mappings)
 
 	var wg sync.WaitGroup
 	for i := 0; i < 20; i++ {
@@ -145,16 +152,16 @@ func TestConcurrentMapperAccess(t *testing.T) {
 				[]byte("key"), []byte(`{"id":"1"}`), col,
 				time.Now(), 1, 1,
 			)
-			result := DefaultMapper(event)
+			result := mapper.Map(event)
 			assert.NotNil(t, result)
 		})
 	}
 	wg.Wait()
 }
 
-// Regression: SetCollectionTableMappings must clear the cache so
-// changed mappings take effect.
-func TestSetCollectionTableMappings_ClearsCache(t *testing.T) {
+// Mappers do not share state: each maps with its own mappings and cache.
+func TestNewDefaultMapper_Independent(t *testing.T) {
+	t.Parallel()
 	mappings1 := []config.CollectionTableMapping{
 		{
 			Collection:    "items",
@@ -162,27 +169,101 @@ func TestSetCollectionTableMappings_ClearsCache(t *testing.T) {
 			FieldMappings: map[string]string{"id": "_key"},
 		},
 	}
-	SetCollectionTableMappings(&mappings1)
+	mappings2 := []config.CollectionTableMapping{
+		{
+			Collection:    "items",
+			TableName:     "items_v2",
+			FieldMappings: map[string]string{"id": "_key"},
+		},
+	}
+	mapper1 := newTestMapper(t, mappings1)
+	mapper2 := newTestMapper(t, mappings2)
 
 	event := couchbase.NewMutateEvent(
 		[]byte("key"), []byte(`{}`), "items",
 		time.Now(), 1, 0,
 	)
-	result1 := DefaultMapper(event)
-	assert.Equal(t, "items_v1", result1[0].(*cassandra.Raw).Table)
+	assert.Equal(t, "items_v1", mapper1.Map(event)[0].(*cassandra.Raw).Table)
+	assert.Equal(t, "items_v2", mapper2.Map(event)[0].(*cassandra.Raw).Table)
+	assert.Equal(t, "items_v1", mapper1.Map(event)[0].(*cassandra.Raw).Table)
 
-	mappings2 := []config.CollectionTableMapping{
+	mappings1[0].FieldMappings["name"] = "profile.name"
+	mapper1.Map(couchbase.NewMutateEvent([]byte("key"), []byte(`{"profile": {"name": "a"}}`), "items", time.Now(), 1, 0))
+	_, cached := mapper1.cache.paths.Load("profile.name")
+	assert.True(t, cached)
+	_, cached = mapper2.cache.paths.Load("profile.name")
+	assert.False(t, cached, "caches are not shared between mappers")
+}
+
+func TestNewDefaultMapper_Validation(t *testing.T) {
+	_, err := NewDefaultMapper(nil)
+	assert.EqualError(t, err, "no collectionTableMapping configured")
+
+	_, err = NewDefaultMapper([]config.CollectionTableMapping{{CollectionRegex: "orders(", TableName: "orders"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid pattern of table orders")
+
+	mapper := newTestMapper(t, []config.CollectionTableMapping{
+		{Collection: "inventory.*", TableName: "inventory", FieldMappings: map[string]string{"id": "_key"}},
+		{Collection: "orders", TableName: "orders", FieldMappings: map[string]string{"id": "_key"}},
+	})
+	assert.NoError(t, mapper.CheckCollections("inventory", []string{"items", "orders"}))
+	assert.EqualError(t, mapper.CheckCollections("_default", []string{"orders", "users"}),
+		"no collectionTableMapping for collection _default.users")
+
+	event := couchbase.NewMutateEvent([]byte("u1"), []byte(`{}`), "users", time.Now(), 1, 0)
+	event.ScopeName = "_default"
+	assert.Empty(t, mapper.Map(event), "unmapped collections are not written")
+}
+
+func TestDefaultMapper_CheckDefaults(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
 		{
-			Collection:    "items",
-			TableName:     "items_v2",
-			FieldMappings: map[string]string{"id": "_key"},
+			Collection:    "orders",
+			TableName:     "orders_table",
+			FieldMappings: map[string]string{"id": "_key", "amount": "amount"},
+			FieldSpecs:    map[string]config.FieldSpec{"amount": {Source: "amount", Default: "abc"}},
+			ColumnTypes:   map[string]string{"amount": "int"},
 		},
 	}
-	SetCollectionTableMappings(&mappings2)
+	_, err := NewDefaultMapper(mappings)
+	assert.EqualError(t, err,
+		`invalid default of table orders_table: cannot convert column amount to int: strconv.ParseInt: parsing "abc": invalid syntax`)
 
-	result2 := DefaultMapper(event)
-	assert.Equal(t, "items_v2", result2[0].(*cassandra.Raw).Table,
-		"cache must be cleared when mappings are updated")
+	mappings[0].ColumnTypes = nil
+	mapper := newTestMapper(t, mappings)
+	mapper.SetSchemaTypes([]cassandra.SchemaTypes{{ColumnTypes: map[string]string{"amount": "int"}}})
+	assert.Error(t, mapper.CheckDefaults(), "types read from the schema are checked again")
+
+	mappings[0].FieldSpecs = map[string]config.FieldSpec{"amount": {Source: "amount", Default: "42"}}
+	mapper = newTestMapper(t, mappings)
+	mapper.SetSchemaTypes([]cassandra.SchemaTypes{{ColumnTypes: map[string]string{"amount": "int"}}})
+	assert.NoError(t, mapper.CheckDefaults())
+}
+
+func TestDefaultMapper_SetSchemaTypes(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{
+			Collection:    "orders",
+			TableName:     "orders_table",
+			FieldMappings: map[string]string{"id": "_key", "amount": "amount", "status": "status"},
+			ColumnTypes:   map[string]string{"status": "text"},
+		},
+	}
+	mapper := newTestMapper(t, mappings)
+	mapper.SetSchemaTypes([]cassandra.SchemaTypes{{ColumnTypes: map[string]string{"amount": "int", "status": "int"}}})
+
+	assert.Equal(t, map[string]string{"status": "text"}, mappings[0].ColumnTypes, "config is not modified")
+	models := mapper.Map(couchbase.Event{
+		CollectionName: "orders",
+		Key:            []byte("o1"),
+		Value:          []byte(`{"amount":"7","status":"new"}`),
+		IsMutated:      true,
+	})
+	require.Len(t, models, 1)
+	raw := models[0].(*cassandra.Raw)
+	assert.Equal(t, int32(7), raw.Document["amount"], "schema types are coerced to")
+	assert.Equal(t, "new", raw.Document["status"], "declared types win over the schema")
 }
 
 // Regression: connector mapper falls back to default/empty collection
@@ -195,44 +276,36 @@ func TestDefaultMapper_FallbackToDefaultCollection(t *testing.T) {
 			FieldMappings: map[string]string{"id": "_key"},
 		},
 	}
-	SetCollectionTableMappings(&mappings)
+	mapper := newTestMapper(t, mappings)
 
 	event := couchbase.NewMutateEvent(
 		[]byte("key"), []byte(`{}`), "any_unknown_collection",
 		time.Now(), 1, 0,
 	)
-	result := DefaultMapper(event)
+	result := mapper.Map(event)
 	assert.Len(t, result, 1)
 	assert.Equal(t, "fallback_table", result[0].(*cassandra.Raw).Table)
 }
 
-// Regression: concurrent mapping updates + reads must not race.
-func TestConcurrentMapperAccess_WithMappingUpdate(t *testing.T) {
+// Regression: mappers used concurrently must not race.
+func TestConcurrentMapperAccess_ManyMappers(t *testing.T) {
 	mappings := []config.CollectionTableMapping{
 		{Collection: "col", TableName: "table", FieldMappings: map[string]string{"id": "_key"}},
 	}
-	SetCollectionTableMappings(&mappings)
 
 	var wg sync.WaitGroup
-
-	for i := 0; i < 50; i++ {
-		wg.Go(func() {
-			event := couchbase.NewMutateEvent(
-				[]byte("key"), []byte(`{}`), "col",
-				time.Now(), 1, 1,
-			)
-			result := DefaultMapper(event)
-			assert.Len(t, result, 1)
-		})
-	}
-
 	for i := 0; i < 10; i++ {
-		wg.Go(func() {
-			m := []config.CollectionTableMapping{
-				{Collection: "col", TableName: "table", FieldMappings: map[string]string{"id": "_key"}},
-			}
-			SetCollectionTableMappings(&m)
-		})
+		mapper := newTestMapper(t, mappings)
+		for j := 0; j < 5; j++ {
+			wg.Go(func() {
+				event := couchbase.NewMutateEvent(
+					[]byte("key"), []byte(`{}`), "col",
+					time.Now(), 1, 1,
+				)
+				result := mapper.Map(event)
+				assert.Len(t, result, 1)
+			})
+		}
 	}
 
 	wg.Wait()
@@ -249,7 +322,7 @@ func TestDefaultMapper_UnknownEvent(t *testing.T) {
 			},
 		},
 	}
-	SetCollectionTableMappings(&mappings)
+	mapper := newTestMapper(t, mappings)
 
 	event := couchbase.Event{
 		CollectionName: "test_collection",
@@ -263,7 +336,7 @@ func TestDefaultMapper_UnknownEvent(t *testing.T) {
 		IsMutated:      false,
 	}
 
-	result := DefaultMapper(event)
+	result := mapper.Map(event)
 
 	assert.Len(t, result, 0, "Expected 0 models for unknown event")
 }
@@ -283,7 +356,7 @@ func TestDefaultMapper_PrimaryKeyFields_Delete(t *testing.T) {
 			},
 		},
 	}
-	SetCollectionTableMappings(&mappings)
+	mapper := newTestMapper(t, mappings)
 
 	event := couchbase.NewDeleteEvent(
 		[]byte("order_1"),
@@ -291,7 +364,7 @@ func TestDefaultMapper_PrimaryKeyFields_Delete(t *testing.T) {
 		"orders", time.Now(), 1, 0,
 	)
 
-	result := DefaultMapper(event)
+	result := mapper.Map(event)
 	require.Len(t, result, 1)
 
 	raw := result[0].(*cassandra.Raw)
@@ -311,7 +384,7 @@ func TestDefaultMapper_NoPrimaryKeyFields_Delete(t *testing.T) {
 			FieldMappings: map[string]string{"id": "_key", "status": "status"},
 		},
 	}
-	SetCollectionTableMappings(&mappings)
+	mapper := newTestMapper(t, mappings)
 
 	event := couchbase.NewDeleteEvent(
 		[]byte("item_1"),
@@ -319,7 +392,7 @@ func TestDefaultMapper_NoPrimaryKeyFields_Delete(t *testing.T) {
 		"items", time.Now(), 1, 0,
 	)
 
-	result := DefaultMapper(event)
+	result := mapper.Map(event)
 	require.Len(t, result, 1)
 
 	raw := result[0].(*cassandra.Raw)
@@ -337,7 +410,7 @@ func TestDefaultMapper_PrimaryKeyFields_AllPK(t *testing.T) {
 			FieldMappings:    map[string]string{"id": "_key", "ts": "timestamp"},
 		},
 	}
-	SetCollectionTableMappings(&mappings)
+	mapper := newTestMapper(t, mappings)
 
 	event := couchbase.NewDeleteEvent(
 		[]byte("ev_1"),
@@ -345,7 +418,7 @@ func TestDefaultMapper_PrimaryKeyFields_AllPK(t *testing.T) {
 		"events", time.Now(), 1, 0,
 	)
 
-	result := DefaultMapper(event)
+	result := mapper.Map(event)
 	require.Len(t, result, 1)
 
 	raw := result[0].(*cassandra.Raw)
@@ -361,14 +434,14 @@ func TestDefaultMapper_PrimaryKeyFields_Expiration(t *testing.T) {
 			FieldMappings:    map[string]string{"id": "_key", "status": "status"},
 		},
 	}
-	SetCollectionTableMappings(&mappings)
+	mapper := newTestMapper(t, mappings)
 
 	event := couchbase.NewExpireEvent(
 		[]byte("order_1"), nil,
 		"orders", time.Now(), 1, 0,
 	)
 
-	result := DefaultMapper(event)
+	result := mapper.Map(event)
 	require.Len(t, result, 1)
 
 	raw := result[0].(*cassandra.Raw)
@@ -386,7 +459,7 @@ func TestDefaultMapper_PrimaryKeyFields_SinglePK(t *testing.T) {
 			FieldMappings:    map[string]string{"id": "_key", "status": "status"},
 		},
 	}
-	SetCollectionTableMappings(&mappings)
+	mapper := newTestMapper(t, mappings)
 
 	event := couchbase.NewDeleteEvent(
 		[]byte("doc_1"),
@@ -394,10 +467,879 @@ func TestDefaultMapper_PrimaryKeyFields_SinglePK(t *testing.T) {
 		"docs", time.Now(), 1, 0,
 	)
 
-	result := DefaultMapper(event)
+	result := mapper.Map(event)
 	require.Len(t, result, 1)
 
 	raw := result[0].(*cassandra.Raw)
 	assert.Len(t, raw.Filter, 1, "only PK field should remain")
 	assert.Equal(t, "doc_1", raw.Filter["id"])
 }
+
+func TestDefaultMapper_ConsistencyOverrides(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{
+			Collection:           "orders",
+			TableName:            "orders_table",
+			FieldMappings:        map[string]string{"id": "_key"},
+			Consistency:          "LOCAL_ONE",
+			OperationConsistency: map[string]string{"delete": "LOCAL_QUORUM"},
+		},
+	}
+	mapper := newTestMapper(t, mappings)
+
+	upsert := mapper.Map(couchbase.NewMutateEvent([]byte("o1"), []byte(`{}`), "orders", time.Now(), 1, 0))
+	require.Len(t, upsert, 1)
+	assert.Equal(t, cassandra.ConsistencyLocalOne, upsert[0].(*cassandra.Raw).Consistency)
+
+	del := mapper.Map(couchbase.NewDeleteEvent([]byte("o1"), nil, "orders", time.Now(), 1, 0))
+	require.Len(t, del, 1)
+	assert.Equal(t, cassandra.ConsistencyLocalQuorum, del[0].(*cassandra.Raw).Consistency)
+}
+
+func TestDefaultMapper_NoConsistencyOverride(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{Collection: "items", TableName: "items_table", FieldMappings: map[string]string{"id": "_key"}},
+	}
+	mapper := newTestMapper(t, mappings)
+
+	result := mapper.Map(couchbase.NewMutateEvent([]byte("i1"), []byte(`{}`), "items", time.Now(), 1, 0))
+	require.Len(t, result, 1)
+	assert.Equal(t, cassandra.DefaultConsistency, result[0].(*cassandra.Raw).Consistency)
+}
+
+func TestDefaultMapper_CoercesToColumnTypes(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{
+			Collection: "orders",
+			TableName:  "orders_table",
+			FieldMappings: map[string]string{
+				"id":         "_key",
+				"amount":     "amount",
+				"created_at": "createdAt",
+				"note":       "note",
+			},
+			ColumnTypes: map[string]string{
+				"amount":     "bigint",
+				"created_at": "timestamp",
+			},
+		},
+	}
+	mapper := newTestMapper(t, mappings)
+
+	event := couchbase.NewMutateEvent(
+		[]byte("o1"),
+		[]byte(`{"amount": 9007199254740993, "createdAt": "2024-01-02T03:04:05Z", "note": 1.5}`),
+		"orders", time.Now(), 1, 0,
+	)
+	result := mapper.Map(event)
+	require.Len(t, result, 1)
+
+	raw := result[0].(*cassandra.Raw)
+	assert.Equal(t, int64(9007199254740993), raw.Document["amount"])
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), raw.Document["created_at"])
+	assert.Equal(t, 1.5, raw.Document["note"], "untyped numbers stay float64")
+}
+
+func TestDefaultMapper_CoercionFailure(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{
+			Collection:            "orders",
+			TableName:             "orders_skip",
+			FieldMappings:         map[string]string{"id": "_key", "amount": "amount"},
+			ColumnTypes:           map[string]string{"amount": "int"},
+			InvalidDocumentPolicy: config.InvalidDocumentSkip,
+		},
+		{
+			Collection:            "orders",
+			TableName:             "orders_dlq",
+			FieldMappings:         map[string]string{"id": "_key", "amount": "amount"},
+			ColumnTypes:           map[string]string{"amount": "int"},
+			InvalidDocumentPolicy: config.InvalidDocumentDeadLetter,
+			DeadLetterTable:       "invalid_documents",
+		},
+	}
+	mapper := newTestMapper(t, mappings)
+
+	event := couchbase.NewMutateEvent([]byte("o1"), []byte(`{"amount": "lots"}`), "orders", time.Now(), 1, 0)
+	var models []cassandra.Model
+	require.NotPanics(t, func() { models = mapper.Map(event) })
+	require.Len(t, models, 1, "skipped by orders_skip")
+	deadLetter := models[0].(*cassandra.Raw)
+	assert.Equal(t, "invalid_documents", deadLetter.Table)
+	assert.Equal(t, "orders_dlq", deadLetter.Document["table_name"])
+	assert.Equal(t, `cannot convert column amount to int: strconv.ParseInt: parsing "lots": invalid syntax`,
+		deadLetter.Document["error"])
+	assert.Equal(t, []InvalidDocumentMetric{
+		{Table: "orders_dlq", Reason: "unmappable", Policy: config.InvalidDocumentDeadLetter, Count: 1},
+		{Table: "orders_skip", Reason: "unmappable", Policy: config.InvalidDocumentSkip, Count: 1},
+	}, mapper.InvalidDocumentMetrics())
+
+	mappings[0].InvalidDocumentPolicy = config.InvalidDocumentFail
+	mapper = newTestMapper(t, mappings)
+	assert.PanicsWithValue(t,
+		`invalid document o1 for table orders_skip: cannot convert column amount to int: strconv.ParseInt: parsing "lots": invalid syntax`,
+		func() { mapper.Map(event) })
+}
+
+func TestDefaultMapper_MappingFailuresDeadLetter(t *testing.T) {
+	deadLettered := func(mapping config.CollectionTableMapping) config.CollectionTableMapping {
+		mapping.InvalidDocumentPolicy = config.InvalidDocumentDeadLetter
+		mapping.DeadLetterTable = "invalid_documents"
+		return mapping
+	}
+	tests := []struct {
+		name    string
+		mapping config.CollectionTableMapping
+		key     string
+		body    string
+		err     string
+	}{
+		{
+			name: "explode field is not an array",
+			mapping: config.CollectionTableMapping{
+				TableName:     "order_items",
+				Explode:       "items",
+				FieldMappings: map[string]string{"order_id": "_key", "sku": "_element.sku"},
+			},
+			key: "o1", body: `{"items": "A"}`,
+			err: "cannot explode field items: string is not an array",
+		},
+		{
+			name: "key does not match keyPattern",
+			mapping: config.CollectionTableMapping{
+				TableName:     "orders_table",
+				KeyPattern:    `^order::(?P<id>\d+)$`,
+				FieldMappings: map[string]string{"id": "_key.id"},
+			},
+			key: "invoice::1", body: `{}`,
+			err: `cannot resolve _key.id for column id: key does not match keyPattern ^order::(?P<id>\d+)$`,
+		},
+		{
+			name: "keyspace cannot be resolved",
+			mapping: config.CollectionTableMapping{
+				TableName:     "orders_table",
+				Keyspace:      "region_{region}",
+				FieldMappings: map[string]string{"id": "_key"},
+			},
+			key: "o1", body: `{}`,
+			err: "cannot resolve keyspace region_{region}: field region is missing",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mapping.Collection = "orders"
+			mapper := newTestMapper(t, []config.CollectionTableMapping{deadLettered(tt.mapping)})
+			event := couchbase.NewMutateEvent([]byte(tt.key), []byte(tt.body), "orders", time.Now(), 1, 0)
+
+			var models []cassandra.Model
+			require.NotPanics(t, func() { models = mapper.Map(event) })
+			require.Len(t, models, 1)
+			deadLetter := models[0].(*cassandra.Raw)
+			assert.Equal(t, "invalid_documents", deadLetter.Table)
+			assert.Equal(t, tt.err, deadLetter.Document["error"])
+		})
+	}
+}
+
+func TestDefaultMapper_FieldSpecs(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{
+			Collection:    "orders",
+			TableName:     "orders_table",
+			FieldMappings: map[string]string{"id": "_key", "created_at": "createdAt", "status": "status", "region": "region"},
+			ColumnTypes:   map[string]string{"created_at": "timestamp"},
+			FieldSpecs: map[string]config.FieldSpec{
+				"created_at": {Source: "createdAt", Format: "02.01.2006"},
+				"status":     {Source: "status", Default: "new", NullPolicy: config.NullPolicyDefault},
+				"region":     {Source: "region", Default: "eu"},
+			},
+		},
+	}
+	mapper := newTestMapper(t, mappings)
+
+	event := couchbase.NewMutateEvent([]byte("o1"), []byte(`{"createdAt": "05.03.2024", "status": null}`), "orders", time.Now(), 1, 0)
+	raw := mapper.Map(event)[0].(*cassandra.Raw)
+	assert.Equal(t, time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), raw.Document["created_at"])
+	assert.Equal(t, "new", raw.Document["status"], "null replaced by nullPolicy default")
+	assert.Equal(t, "eu", raw.Document["region"], "missing field takes the default")
+
+	event = couchbase.NewMutateEvent([]byte("o2"), []byte(`{"status": "paid", "region": null}`), "orders", time.Now(), 1, 0)
+	raw = mapper.Map(event)[0].(*cassandra.Raw)
+	assert.Equal(t, "paid", raw.Document["status"])
+	assert.Nil(t, raw.Document["region"], "explicit null kept under nullPolicy null")
+	assert.Nil(t, raw.Document["created_at"])
+}
+
+func TestDefaultMapper_FieldSpecErrors(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{
+			Collection:            "orders",
+			TableName:             "orders_table",
+			InvalidDocumentPolicy: config.InvalidDocumentFail,
+			PrimaryKeyFields:      []string{"id"},
+			FieldMappings:         map[string]string{"id": "_key", "amount": "amount", "status": "status"},
+			FieldSpecs: map[string]config.FieldSpec{
+				"amount": {Source: "amount", Required: true},
+				"status": {Source: "status", NullPolicy: config.NullPolicyError},
+			},
+		},
+	}
+	mapper := newTestMapper(t, mappings)
+
+	event := couchbase.NewMutateEvent([]byte("o1"), []byte(`{"status": "new"}`), "orders", time.Now(), 1, 0)
+	assert.PanicsWithValue(t,
+		"invalid document o1 for table orders_table: required field amount for column amount is missing",
+		func() { mapper.Map(event) })
+
+	event = couchbase.NewMutateEvent([]byte("o1"), []byte(`{"amount": 1, "status": null}`), "orders", time.Now(), 1, 0)
+	assert.PanicsWithValue(t,
+		"invalid document o1 for table orders_table: field status for column status is null",
+		func() { mapper.Map(event) })
+
+	deletion := couchbase.NewDeleteEvent([]byte("o1"), nil, "orders", time.Now(), 1, 0)
+	raw := mapper.Map(deletion)[0].(*cassandra.Raw)
+	assert.Equal(t, map[string]interface{}{"id": "o1"}, raw.Filter, "deletes do not enforce required fields")
+}
+
+func TestDefaultMapper_FanOut(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{
+			Collection:       "orders",
+			TableName:        "orders_by_id",
+			PrimaryKeyFields: []string{"order_id"},
+			FieldMappings:    map[string]string{"order_id": "_key", "customer_id": "customerId", "total": "total"},
+		},
+		{
+			Collection:       "orders",
+			TableName:        "orders_by_customer",
+			PrimaryKeyFields: []string{"customer_id", "order_id"},
+			FieldMappings:    map[string]string{"customer_id": "customerId", "order_id": "_key"},
+		},
+		{Collection: "customers", TableName: "customers", FieldMappings: map[string]string{"id": "_key"}},
+	}
+	mapper := newTestMapper(t, mappings)
+
+	document := []byte(`{"customerId": "c1", "total": 10}`)
+	result := mapper.Map(couchbase.NewMutateEvent([]byte("o1"), document, "orders", time.Now(), 1, 0))
+	require.Len(t, result, 2)
+	assert.Equal(t, "orders_by_id", result[0].(*cassandra.Raw).Table)
+	assert.Equal(t, map[string]interface{}{"order_id": "o1", "customer_id": "c1", "total": float64(10)},
+		result[0].(*cassandra.Raw).Document)
+	assert.Equal(t, "orders_by_customer", result[1].(*cassandra.Raw).Table)
+	assert.Equal(t, map[string]interface{}{"customer_id": "c1", "order_id": "o1"}, result[1].(*cassandra.Raw).Document)
+
+	result = mapper.Map(couchbase.NewDeleteEvent([]byte("o1"), document, "orders", time.Now(), 1, 0))
+	require.Len(t, result, 2)
+	assert.Equal(t, map[string]interface{}{"order_id": "o1"}, result[0].(*cassandra.Raw).Filter)
+	assert.Equal(t, map[string]interface{}{"customer_id": "c1", "order_id": "o1"}, result[1].(*cassandra.Raw).Filter)
+
+	result = mapper.Map(couchbase.NewExpireEvent([]byte("o1"), nil, "orders", time.Now(), 1, 0))
+	require.Len(t, result, 1, "tables whose primary key needs the document body are skipped")
+	assert.Equal(t, "orders_by_id", result[0].(*cassandra.Raw).Table)
+}
+
+func TestDefaultMapper_MetadataSources(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{
+			Collection:       "orders",
+			TableName:        "orders_table",
+			PrimaryKeyFields: []string{"id", "vb"},
+			FieldMappings: map[string]string{
+				"id": "_key", "cas": "_cas", "vb": "_vbId", "event_time": "_eventTime", "event_ms": "_eventTime",
+				"collection": "_collection", "scope": "_scope", "rev": "_revSeqNo", "expiry": "_expiry", "op": "_operation",
+			},
+			ColumnTypes: map[string]string{
+				"cas": "bigint", "vb": "int", "event_time": "timestamp", "event_ms": "bigint", "rev": "text", "expiry": "timestamp",
+			},
+		},
+	}
+	mapper := newTestMapper(t, mappings)
+
+	eventTime := time.Date(2024, 3, 5, 10, 30, 0, 0, time.UTC)
+	event := couchbase.NewMutateEvent([]byte("o1"), []byte(`{}`), "orders", eventTime, 1709634600000000000, 42)
+	event.ScopeName = "sales"
+	event.RevSeqNo = 7
+	event.Expiry = eventTime.Add(time.Hour)
+
+	raw := mapper.Map(event)[0].(*cassandra.Raw)
+	assert.Equal(t, map[string]interface{}{
+		"id":         "o1",
+		"cas":        int64(1709634600000000000),
+		"vb":         int32(42),
+		"event_time": eventTime,
+		"event_ms":   eventTime.UnixMilli(),
+		"collection": "orders",
+		"scope":      "sales",
+		"rev":        "7",
+		"expiry":     eventTime.Add(time.Hour),
+		"op":         "mutation",
+	}, raw.Document)
+
+	event = couchbase.NewExpireEvent([]byte("o1"), nil, "orders", eventTime, 1, 42)
+	raw = mapper.Map(event)[0].(*cassandra.Raw)
+	assert.Equal(t, map[string]interface{}{"id": "o1", "vb": int32(42)}, raw.Filter, "metadata in the primary key is kept")
+
+	mappings[0].PrimaryKeyFields = nil
+	mapper = newTestMapper(t, mappings)
+	raw = mapper.Map(event)[0].(*cassandra.Raw)
+	assert.Equal(t, map[string]interface{}{"id": "o1"}, raw.Filter, "metadata is not a delete condition by default")
+}
+
+func TestDefaultMapper_KeyPattern(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{
+			Collection:       "orders",
+			TableName:        "orders_table",
+			Keyspace:         "tenant_{_key.tenant}",
+			KeyPattern:       `^order::(?P<tenant>[a-z]+)::(?P<id>\d+)(::(?P<suffix>\w+))?$`,
+			PrimaryKeyFields: []string{"tenant", "id"},
+			FieldMappings:    map[string]string{"tenant": "_key.tenant", "id": "_key.id", "suffix": "_key.suffix", "status": "status"},
+			ColumnTypes:      map[string]string{"id": "bigint"},
+			// Keys that do not match keyPattern are invalid documents.
+			InvalidDocumentPolicy: config.InvalidDocumentSkip,
+		},
+	}
+	mapper := newTestMapper(t, mappings)
+
+	event := couchbase.NewMutateEvent([]byte("order::acme::42"), []byte(`{"status": "new"}`), "orders", time.Now(), 1, 0)
+	raw := mapper.Map(event)[0].(*cassandra.Raw)
+	assert.Equal(t, "tenant_acme", raw.Keyspace)
+	assert.Equal(t, map[string]interface{}{"tenant": "acme", "id": int64(42), "suffix": nil, "status": "new"}, raw.Document)
+
+	event = couchbase.NewExpireEvent([]byte("order::acme::42::v2"), nil, "orders", time.Now(), 1, 0)
+	raw = mapper.Map(event)[0].(*cassandra.Raw)
+	assert.Equal(t, map[string]interface{}{"tenant": "acme", "id": int64(42)}, raw.Filter, "expirations get the full primary key")
+
+	event = couchbase.NewMutateEvent([]byte("invoice::1"), []byte(`{}`), "orders", time.Now(), 1, 0)
+	assert.Empty(t, mapper.Map(event), "keys that do not match keyPattern are skipped")
+}
+
+func TestDefaultMapper_Filter(t *testing.T) {
+	missing := false
+	mappings := []config.CollectionTableMapping{
+		{
+			Collection:    "orders",
+			TableName:     "orders_table",
+			FieldMappings: map[string]string{"id": "_key"},
+			Filter: &config.Filter{
+				Include: []config.FilterRule{{KeyPrefix: "order::"}, {KeyRegex: `^legacy_\d+$`}},
+				Exclude: []config.FilterRule{
+					{Field: "meta.temporary", Equals: true},
+					{Field: "version", Equals: 1},
+					{Field: "customerId", Exists: &missing, Events: []string{config.EventMutation}},
+					{Events: []string{config.EventExpiration}},
+				},
+			},
+		},
+		{Collection: "orders", TableName: "orders_audit", FieldMappings: map[string]string{"id": "_key"}},
+	}
+	mapper := newTestMapper(t, mappings)
+
+	tables := func(event couchbase.Event) []string {
+		var names []string
+		for _, model := range mapper.Map(event) {
+			names = append(names, model.(*cassandra.Raw).Table)
+		}
+		return names
+	}
+	mutation := func(key, body string) couchbase.Event {
+		return couchbase.NewMutateEvent([]byte(key), []byte(body), "orders", time.Now(), 1, 0)
+	}
+
+	assert.Equal(t, []string{"orders_table", "orders_audit"}, tables(mutation("order::1", `{"customerId": "c1"}`)))
+	assert.Equal(t, []string{"orders_table", "orders_audit"}, tables(mutation("legacy_7", `{"customerId": "c1"}`)))
+	assert.Equal(t, []string{"orders_audit"}, tables(mutation("_sync:rev", `{"customerId": "c1"}`)), "not included")
+	assert.Equal(t, []string{"orders_audit"}, tables(mutation("order::2", `{"customerId": "c1", "meta": {"temporary": true}}`)))
+	assert.Equal(t, []string{"orders_audit"}, tables(mutation("order::3", `{"customerId": "c1", "version": 1.0}`)))
+	assert.Equal(t, []string{"orders_table", "orders_audit"}, tables(mutation("order::4", `{"customerId": "c1", "version": 2}`)))
+	assert.Equal(t, []string{"orders_audit"}, tables(mutation("order::5", `{}`)), "customerId missing")
+
+	deletion := couchbase.NewDeleteEvent([]byte("order::5"), nil, "orders", time.Now(), 1, 0)
+	assert.Equal(t, []string{"orders_table", "orders_audit"}, tables(deletion), "field rule limited to mutations")
+	expiration := couchbase.NewExpireEvent([]byte("order::5"), nil, "orders", time.Now(), 1, 0)
+	assert.Equal(t, []string{"orders_audit"}, tables(expiration))
+
+	mappings = mappings[:1]
+	mapper = newTestMapper(t, mappings)
+	assert.Empty(t, mapper.Map(expiration), "filtered-out events produce no models")
+}
+
+func TestDefaultMapper_KeyspaceRouting(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{
+			Collection:         "orders",
+			TableName:          "orders_table",
+			FieldMappings:      map[string]string{"id": "_key"},
+			Keyspace:           "tenant_{_keyPrefix}",
+			KeyPrefixSeparator: "::",
+		},
+		{
+			Collection:    "users",
+			TableName:     "users_table",
+			FieldMappings: map[string]string{"id": "_key"},
+			Keyspace:      "region_{meta.region}",
+		},
+		{
+			Collection:    "items",
+			TableName:     "items_table",
+			FieldMappings: map[string]string{"id": "_key"},
+			Keyspace:      "archive",
+		},
+	}
+	mapper := newTestMapper(t, mappings)
+
+	upsert := mapper.Map(couchbase.NewMutateEvent([]byte("acme::o1"), []byte(`{}`), "orders", time.Now(), 1, 0))
+	assert.Equal(t, "tenant_acme", upsert[0].(*cassandra.Raw).Keyspace)
+	assert.True(t, upsert[0].(*cassandra.Raw).RoutedKeyspace)
+
+	deleted := mapper.Map(couchbase.NewDeleteEvent([]byte("acme::o1"), nil, "orders", time.Now(), 1, 0))
+	assert.Equal(t, "tenant_acme", deleted[0].(*cassandra.Raw).Keyspace)
+	assert.True(t, deleted[0].(*cassandra.Raw).RoutedKeyspace)
+
+	upsert = mapper.Map(couchbase.NewMutateEvent([]byte("u1"), []byte(`{"meta":{"region":"eu"}}`), "users", time.Now(), 1, 0))
+	assert.Equal(t, "region_eu", upsert[0].(*cassandra.Raw).Keyspace)
+
+	upsert = mapper.Map(couchbase.NewMutateEvent([]byte("i1"), []byte(`{}`), "items", time.Now(), 1, 0))
+	assert.Equal(t, "archive", upsert[0].(*cassandra.Raw).Keyspace)
+	assert.False(t, upsert[0].(*cassandra.Raw).RoutedKeyspace, "a static keyspace only overrides the primary cluster")
+}
+
+func TestDefaultMapper_KeyspaceRoutingFailures(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{
+			Collection:            "users",
+			TableName:             "users_table",
+			InvalidDocumentPolicy: config.InvalidDocumentFail,
+			FieldMappings:         map[string]string{"id": "_key"},
+			Keyspace:              "region_{region}",
+		},
+	}
+	mapper := newTestMapper(t, mappings)
+
+	assert.PanicsWithValue(t,
+		"invalid document u1 for table users_table: cannot resolve keyspace region_{region}: field region is missing",
+		func() {
+			mapper.Map(couchbase.NewMutateEvent([]byte("u1"), []byte(`{}`), "users", time.Now(), 1, 0))
+		})
+	assert.PanicsWithValue(t,
+		`invalid document u1 for table users_table: cannot resolve keyspace region_{region}: "region_eu;drop" is not a valid keyspace name`,
+		func() {
+			mapper.Map(couchbase.NewMutateEvent([]byte("u1"), []byte(`{"region":"eu;drop"}`), "users", time.Now(), 1, 0))
+		})
+}
+
+func TestDefaultMapper_Expressions(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{
+			Collection:       "users",
+			TableName:        "users_table",
+			PrimaryKeyFields: []string{"bucket", "id"},
+			FieldMappings: map[string]string{
+				"id":        "_key",
+				"bucket":    `expr("hash(_key) % 16")`,
+				"full_name": `expr("first + ' ' + last")`,
+				"is_active": `expr("status == 'ACTIVE'")`,
+				"tier":      `expr("coalesce(tier, 'free')")`,
+			},
+			FieldSpecs:            map[string]config.FieldSpec{"tier": {Source: `expr("coalesce(tier, 'free')")`}},
+			ColumnTypes:           map[string]string{"bucket": "int", "is_active": "boolean"},
+			InvalidDocumentPolicy: config.InvalidDocumentSkip,
+		},
+	}
+	mapper := newTestMapper(t, mappings)
+
+	body := []byte(`{"first": "Ada", "last": "Lovelace", "status": "ACTIVE"}`)
+	raw := mapper.Map(couchbase.NewMutateEvent([]byte("user::1"), body, "users", time.Now(), 1, 0))[0].(*cassandra.Raw)
+	assert.Equal(t, map[string]interface{}{
+		"id": "user::1", "bucket": int32(5), "full_name": "Ada Lovelace", "is_active": true, "tier": "free",
+	}, raw.Document)
+
+	raw = mapper.Map(couchbase.NewDeleteEvent([]byte("user::1"), nil, "users", time.Now(), 1, 0))[0].(*cassandra.Raw)
+	assert.Equal(t, map[string]interface{}{"id": "user::1", "bucket": int32(5)}, raw.Filter, "key-only expressions resolve on delete")
+
+	models := mapper.Map(couchbase.NewMutateEvent([]byte("user::2"), []byte(`{"status": "ACTIVE"}`), "users", time.Now(), 1, 0))
+	assert.Empty(t, models, "documents whose expressions fail are skipped")
+}
+
+func TestDefaultMapper_Explode(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{
+			Collection:       "orders",
+			TableName:        "order_items",
+			Explode:          "items",
+			PrimaryKeyFields: []string{"order_id", "item_index"},
+			FieldMappings: map[string]string{
+				"order_id":   "_key",
+				"item_index": "_index",
+				"customer":   "customerId",
+				"sku":        "_element.sku",
+				"qty":        "_element.qty",
+			},
+			FieldSpecs:            map[string]config.FieldSpec{"qty": {Source: "_element.qty", Default: 1}},
+			ColumnTypes:           map[string]string{"item_index": "int", "qty": "int"},
+			InvalidDocumentPolicy: config.InvalidDocumentSkip,
+		},
+	}
+	mapper := newTestMapper(t, mappings)
+
+	body := []byte(`{"customerId": "c1", "items": [{"sku": "A", "qty": 2}, {"sku": "B"}]}`)
+	models := mapper.Map(couchbase.NewMutateEvent([]byte("o1"), body, "orders", time.Now(), 1, 0))
+	require.Len(t, models, 3)
+
+	stale := models[0].(*cassandra.Raw)
+	assert.Equal(t, cassandra.Delete, stale.Operation)
+	assert.Equal(t, map[string]interface{}{"order_id": "o1"}, stale.Filter)
+	assert.Equal(t, map[string]interface{}{"item_index": int32(2)}, stale.FilterFrom, "rows past the last element are removed")
+
+	assert.Equal(t, map[string]interface{}{
+		"order_id": "o1", "item_index": int32(0), "customer": "c1", "sku": "A", "qty": int32(2),
+	}, models[1].(*cassandra.Raw).Document)
+	assert.Equal(t, map[string]interface{}{
+		"order_id": "o1", "item_index": int32(1), "customer": "c1", "sku": "B", "qty": int32(1),
+	}, models[2].(*cassandra.Raw).Document)
+
+	models = mapper.Map(couchbase.NewMutateEvent([]byte("o1"), []byte(`{"customerId": "c1"}`), "orders", time.Now(), 1, 0))
+	require.Len(t, models, 1, "without the array every row of the parent is removed")
+	assert.Equal(t, map[string]interface{}{"item_index": int32(0)}, models[0].(*cassandra.Raw).FilterFrom)
+
+	raw := mapper.Map(couchbase.NewDeleteEvent([]byte("o1"), nil, "orders", time.Now(), 1, 0))[0].(*cassandra.Raw)
+	assert.Equal(t, map[string]interface{}{"order_id": "o1"}, raw.Filter, "deletes remove every row of the parent")
+	assert.Nil(t, raw.FilterFrom)
+
+	models = mapper.Map(couchbase.NewMutateEvent([]byte("o2"), []byte(`{"items": "A"}`), "orders", time.Now(), 1, 0))
+	assert.Empty(t, models, "documents whose explode field is not an array are skipped")
+}
+
+func TestDefaultMapper_DeleteMode(t *testing.T) {
+	eventTime := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+	mappings := []config.CollectionTableMapping{
+		{
+			Collection:       "orders",
+			TableName:        "orders_audit",
+			PrimaryKeyFields: []string{"id"},
+			FieldMappings:    map[string]string{"id": "_key", "status": "status"},
+			DeleteMode:       config.DeleteMode{Deletion: config.DeleteModeHard, Expiration: config.DeleteModeSoft},
+			SoftDelete:       config.SoftDelete{DeletedAtColumn: "deleted_at", Columns: map[string]interface{}{"status": "EXPIRED"}},
+			ColumnTypes:      map[string]string{"deleted_at": "timestamp"},
+		},
+		{
+			Collection:    "orders",
+			TableName:     "orders_history",
+			FieldMappings: map[string]string{"id": "_key"},
+			DeleteMode:    config.DeleteMode{Deletion: config.DeleteModeIgnore, Expiration: config.DeleteModeIgnore},
+		},
+	}
+	mapper := newTestMapper(t, mappings)
+
+	models := mapper.Map(couchbase.NewExpireEvent([]byte("o1"), nil, "orders", eventTime, 1, 0))
+	require.Len(t, models, 1, "ignored by orders_history")
+	raw := models[0].(*cassandra.Raw)
+	assert.Equal(t, cassandra.Update, raw.Operation)
+	assert.Equal(t, map[string]interface{}{"id": "o1"}, raw.Filter)
+	assert.Equal(t, map[string]interface{}{"deleted_at": eventTime, "status": "EXPIRED"}, raw.Document)
+
+	models = mapper.Map(couchbase.NewDeleteEvent([]byte("o1"), nil, "orders", eventTime, 1, 0))
+	require.Len(t, models, 1)
+	raw = models[0].(*cassandra.Raw)
+	assert.Equal(t, cassandra.Delete, raw.Operation)
+	assert.Nil(t, raw.Document)
+}
+
+func TestDefaultMapper_ScopedCollections(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{
+			Collection:    "tenant_*.orders",
+			TableName:     "orders",
+			Keyspace:      "{_scope}",
+			FieldMappings: map[string]string{"id": "_key"},
+		},
+		{
+			CollectionRegex: `(?P<tenant>[a-z]+)_prod\.orders`,
+			TableName:       "orders_{_match.tenant}",
+			FieldMappings:   map[string]string{"id": "_key"},
+		},
+		{
+			Collection:    "orders",
+			TableName:     "all_orders",
+			FieldMappings: map[string]string{"id": "_key", "scope": "_scope"},
+		},
+	}
+	mapper := newTestMapper(t, mappings)
+
+	mutation := func(scope string) []cassandra.Model {
+		event := couchbase.NewMutateEvent([]byte("o1"), []byte(`{}`), "orders", time.Now(), 1, 0)
+		event.ScopeName = scope
+		return mapper.Map(event)
+	}
+	targets := func(models []cassandra.Model) []string {
+		var names []string
+		for _, model := range models {
+			raw := model.(*cassandra.Raw)
+			names = append(names, raw.Keyspace+"."+raw.Table)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"tenant_a.orders", ".all_orders"}, targets(mutation("tenant_a")))
+	assert.Equal(t, []string{".orders_acme", ".all_orders"}, targets(mutation("acme_prod")))
+	assert.Equal(t, []string{".all_orders"}, targets(mutation("_default")))
+
+	deleted := couchbase.NewDeleteEvent([]byte("o1"), nil, "orders", time.Now(), 1, 0)
+	deleted.ScopeName = "acme_prod"
+	assert.Equal(t, []string{".orders_acme", ".all_orders"}, targets(mapper.Map(deleted)))
+}
+
+func TestDefaultMapper_InvalidDocumentPolicy(t *testing.T) {
+	eventTime := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+	fieldMappings := map[string]string{"id": "_key", "body": "documentData", "status": "status"}
+	mappings := []config.CollectionTableMapping{
+		{
+			Collection:            "orders",
+			TableName:             "orders_skip",
+			FieldMappings:         fieldMappings,
+			InvalidDocumentPolicy: config.InvalidDocumentSkip,
+		},
+		{
+			Collection:            "orders",
+			TableName:             "orders_raw",
+			PrimaryKeyFields:      []string{"id"},
+			FieldMappings:         fieldMappings,
+			ColumnTypes:           map[string]string{"body": "blob"},
+			InvalidDocumentPolicy: config.InvalidDocumentRaw,
+		},
+		{
+			Collection:            "orders",
+			TableName:             "orders_dlq",
+			FieldMappings:         fieldMappings,
+			InvalidDocumentPolicy: config.InvalidDocumentDeadLetter,
+			DeadLetterTable:       "ops.invalid_documents",
+		},
+	}
+	mapper := newTestMapper(t, mappings)
+
+	binary := couchbase.NewMutateEvent([]byte("o1"), []byte{0xff, 0x00}, "orders", eventTime, 1, 0)
+	binary.ScopeName, binary.Datatype, binary.Binary = "_default", 0, true
+	models := mapper.Map(binary)
+	require.Len(t, models, 2, "skipped by orders_skip")
+
+	raw := models[0].(*cassandra.Raw)
+	assert.Equal(t, "orders_raw", raw.Table)
+	assert.Equal(t, map[string]interface{}{"id": "o1", "body": []byte{0xff, 0x00}}, raw.Document)
+
+	deadLetter := models[1].(*cassandra.Raw)
+	assert.Equal(t, "ops", deadLetter.Keyspace)
+	assert.Equal(t, "invalid_documents", deadLetter.Table)
+	assert.Equal(t, cassandra.Upsert, deadLetter.Operation)
+	assert.Equal(t, map[string]interface{}{
+		"id":         "o1",
+		"collection": "_default.orders",
+		"table_name": "orders_dlq",
+		"event_time": eventTime,
+		"datatype":   int32(0),
+		"value":      []byte{0xff, 0x00},
+		"error":      "datatype 0x00 is not plain JSON",
+	}, deadLetter.Document)
+
+	for _, body := range []string{`{"status": "new"`, `{"status": "new"} trailing`, `["new"]`, `null`, ``} {
+		models = mapper.Map(couchbase.NewMutateEvent([]byte("o2"), []byte(body), "orders", eventTime, 1, 0))
+		require.Len(t, models, 2, body)
+		assert.NotContains(t, models[0].(*cassandra.Raw).Document, "status", body)
+	}
+
+	deletion := couchbase.NewDeleteEvent([]byte("o3"), []byte("not json"), "orders", eventTime, 1, 0)
+	models = mapper.Map(deletion)
+	require.Len(t, models, 2, "invalid delete bodies no longer panic")
+	assert.Equal(t, cassandra.Delete, models[0].(*cassandra.Raw).Operation)
+	assert.Equal(t, map[string]interface{}{"id": "o3"}, models[0].(*cassandra.Raw).Filter)
+	assert.Equal(t, "invalid_documents", models[1].(*cassandra.Raw).Table)
+
+	count := func(table, reason, policy string) uint64 {
+		for _, metric := range mapper.InvalidDocumentMetrics() {
+			if metric.Table == table && metric.Reason == reason && metric.Policy == policy {
+				return metric.Count
+			}
+		}
+		return 0
+	}
+	assert.Equal(t, uint64(1), count("orders_skip", "binary", config.InvalidDocumentSkip))
+	assert.Equal(t, uint64(5), count("orders_skip", "malformed", config.InvalidDocumentSkip), "four bodies and the delete body")
+	assert.Equal(t, uint64(5), count("orders_raw", "malformed", config.InvalidDocumentRaw))
+	assert.Equal(t, uint64(1), count("orders_dlq", "not_object", config.InvalidDocumentDeadLetter), "null")
+
+	mappings[0].InvalidDocumentPolicy = config.InvalidDocumentFail
+	mapper = newTestMapper(t, mappings)
+	assert.PanicsWithValue(t, "invalid document o1 for table orders_skip: datatype 0x00 is not plain JSON", func() {
+		mapper.Map(binary)
+	})
+}
+
+func TestDefaultMapper_FieldPaths(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{
+			Collection: "orders",
+			TableName:  "orders_table",
+			FieldMappings: map[string]string{
+				"id":         "_key",
+				"order_id":   `['order.id']`,
+				"first_sku":  "items[0].sku",
+				"last_sku":   "$.items[-1].sku",
+				"skus":       "items[*].sku",
+				"tags":       "tags",
+				"quantities": "quantities",
+				"items_json": "items",
+				"missing":    "items[5].sku",
+			},
+			ColumnTypes: map[string]string{
+				"skus":       "list<text>",
+				"tags":       "frozen<set<text>>",
+				"quantities": "map<text, int>",
+				"items_json": "text",
+			},
+			InvalidDocumentPolicy: config.InvalidDocumentSkip,
+		},
+	}
+	mapper := newTestMapper(t, mappings)
+
+	event := couchbase.NewMutateEvent([]byte("o1"), []byte(`{
+		"order.id": 7,
+		"items": [{"sku": "a", "qty": 1}, {"sku": "b", "qty": 2}],
+		"tags": ["x", "y"],
+		"quantities": {"a": 1, "b": 2}
+	}`), "orders", time.Now(), 1, 0)
+	raw := mapper.Map(event)[0].(*cassandra.Raw)
+	assert.Equal(t, 7.0, raw.Document["order_id"])
+	assert.Equal(t, "a", raw.Document["first_sku"])
+	assert.Equal(t, "b", raw.Document["last_sku"])
+	assert.Equal(t, []interface{}{"a", "b"}, raw.Document["skus"])
+	assert.Equal(t, []interface{}{"x", "y"}, raw.Document["tags"])
+	assert.Equal(t, map[interface{}]interface{}{"a": int32(1), "b": int32(2)}, raw.Document["quantities"])
+	assert.Equal(t, `[{"qty":1,"sku":"a"},{"qty":2,"sku":"b"}]`, raw.Document["items_json"])
+	assert.Nil(t, raw.Document["missing"])
+
+	event = couchbase.NewMutateEvent([]byte("o2"), []byte(`{"tags": ["x", null]}`), "orders", time.Now(), 1, 0)
+	assert.Empty(t, mapper.Map(event), "a null set element cannot be written")
+}
+
+func TestDefaultMapper_UserTypes(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{
+			Collection:    "customers",
+			TableName:     "customers_table",
+			FieldMappings: map[string]string{"id": "_key", "address": "address", "history": "addresses", "location": "location"},
+			ColumnTypes: map[string]string{
+				"address":  "frozen<address>",
+				"history":  "list<frozen<address>>",
+				"location": "tuple<double, double>",
+			},
+			UserTypes: map[string]map[string]string{"address": {"city": "text", "zip_code": "int"}},
+		},
+	}
+	mapper := newTestMapper(t, mappings)
+
+	event := couchbase.NewMutateEvent([]byte("c1"), []byte(`{
+		"address": {"city": "Istanbul", "zipCode": 34000},
+		"addresses": [{"city": "Ankara"}],
+		"location": [41.0, 29.0]
+	}`), "customers", time.Now(), 1, 0)
+	raw := mapper.Map(event)[0].(*cassandra.Raw)
+	assert.Equal(t, map[string]interface{}{"city": "Istanbul"}, raw.Document["address"], "zipCode is not zip_code")
+	assert.Equal(t, []interface{}{map[string]interface{}{"city": "Ankara"}}, raw.Document["history"])
+	assert.Equal(t, []interface{}{41.0, 29.0}, raw.Document["location"])
+
+	mappings[0].InvalidDocumentPolicy = config.InvalidDocumentFail
+	mapper = newTestMapper(t, mappings)
+	event = couchbase.NewMutateEvent([]byte("c2"), []byte(`{"address": {"zip_code": "unknown"}}`), "customers", time.Now(), 1, 0)
+	assert.PanicsWithValue(t,
+		`invalid document c2 for table customers_table: cannot convert column address to frozen<address>: `+
+			`field zip_code: strconv.ParseInt: parsing "unknown": invalid syntax`,
+		func() { mapper.Map(event) })
+}
+
+func TestDefaultMapper_MissingFieldPolicy(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{
+			Collection:         "orders",
+			TableName:          "orders_table",
+			MissingFieldPolicy: config.MissingFieldUnset,
+			PrimaryKeyFields:   []string{"id"},
+			FieldMappings:      map[string]string{"id": "_key", "note": "note", "status": "status", "region": "region"},
+			FieldSpecs: map[string]config.FieldSpec{
+				"status": {Source: "status", Default: "new"},
+				"region": {Source: "region", MissingFieldPolicy: config.MissingFieldNull},
+			},
+			ColumnTypes: map[string]string{"note": "text"},
+		},
+		{
+			Collection:            "orders",
+			TableName:             "orders_strict",
+			MissingFieldPolicy:    config.MissingFieldError,
+			InvalidDocumentPolicy: config.InvalidDocumentFail,
+			FieldMappings:         map[string]string{"id": "_key", "note": "note"},
+		},
+	}
+	mapper := newTestMapper(t, mappings[:1])
+
+	event := couchbase.NewMutateEvent([]byte("o1"), []byte(`{"note": null}`), "orders", time.Now(), 1, 0)
+	raw := mapper.Map(event)[0].(*cassandra.Raw)
+	assert.Nil(t, raw.Document["note"], "explicit null is not missing")
+	assert.Equal(t, "new", raw.Document["status"])
+	assert.Nil(t, raw.Document["region"])
+	assert.Contains(t, raw.Document, "region")
+
+	event = couchbase.NewMutateEvent([]byte("o2"), []byte(`{}`), "orders", time.Now(), 1, 0)
+	raw = mapper.Map(event)[0].(*cassandra.Raw)
+	assert.Equal(t, cassandra.Unset, raw.Document["note"])
+
+	deletion := couchbase.NewDeleteEvent([]byte("o2"), nil, "orders", time.Now(), 1, 0)
+	raw = mapper.Map(deletion)[0].(*cassandra.Raw)
+	assert.Equal(t, map[string]interface{}{"id": "o2"}, raw.Filter, "unset never reaches a delete")
+
+	mapper = newTestMapper(t, mappings[1:])
+	assert.PanicsWithValue(t,
+		"invalid document o2 for table orders_strict: required field note for column note is missing",
+		func() { mapper.Map(event) })
+	assert.NotPanics(t, func() { mapper.Map(deletion) })
+}
+
+func TestDefaultMapper_WriteMode(t *testing.T) {
+	mappings := []config.CollectionTableMapping{
+		{
+			Collection:    "orders",
+			TableName:     "orders_log",
+			FieldMappings: map[string]string{"id": "_key", "status": "status"},
+			WriteMode:     config.WriteModeInsertIfNotExists,
+		},
+		{
+			Collection:           "orders",
+			TableName:            "orders_status",
+			PrimaryKeyFields:     []string{"id"},
+			FieldMappings:        map[string]string{"id": "_key", "status": "status"},
+			WriteMode:            config.WriteModeUpdateExisting,
+			IfExists:             true,
+			OperationConsistency: map[string]string{"update": "LOCAL_QUORUM"},
+		},
+		{
+			Collection:    "orders",
+			TableName:     "orders",
+			FieldMappings: map[string]string{"id": "_key", "status": "status"},
+		},
+	}
+	mapper := newTestMapper(t, mappings)
+
+	event := couchbase.NewMutateEvent([]byte("o1"), []byte(`{"status": "paid"}`), "orders", time.Now(), 1, 0)
+	result := mapper.Map(event)
+	require.Len(t, result, 3)
+
+	insert := result[0].(*cassandra.Raw)
+	assert.Equal(t, cassandra.Insert, insert.Operation)
+	assert.Equal(t, map[string]interface{}{"id": "o1", "status": "paid"}, insert.Document)
+
+	update := result[1].(*cassandra.Raw)
+	assert.Equal(t, cassandra.Update, update.Operation)
+	assert.True(t, update.IfExists)
+	assert.Equal(t, map[string]interface{}{"status": "paid"}, update.Document)
+	assert.Equal(t, map[string]interface{}{"id": "o1"}, update.Filter)
+	assert.Equal(t, cassandra.ConsistencyLocalQuorum, update.Consistency)
+
+	assert.Equal(t, cassandra.Upsert, result[2].(*cassandra.Raw).Operation)
+}
@@ -0,0 +1,49 @@
+package connector
+
+import (
+	"github.com/Trendyol/go-dcp-cassandra/config"
+	"github.com/Trendyol/go-dcp-cassandra/couchbase"
+)
+
+// metadataValue returns the value of a metadata source for event. Times are
+// UTC; an event without expiry maps _expiry to null.
+func metadataValue(event couchbase.Event, source string) interface{} {
+	switch source {
+	case config.SourceCas:
+		return event.Cas
+	case config.SourceVbID:
+		return event.VbID
+	case config.SourceEventTime:
+		return event.EventTime.UTC()
+	case config.SourceCollection:
+		return event.CollectionName
+	case config.SourceScope:
+		return event.ScopeName
+	case config.SourceRevSeqNo:
+		return event.RevSeqNo
+	case config.SourceExpiry:
+		if event.Expiry.IsZero() {
+			return nil
+		}
+		return event.Expiry.UTC()
+	case config.SourceOperation:
+		if eventType := eventType(event); eventType != "" {
+			return eventType
+		}
+	}
+	return nil
+}
+
+// eventType returns the config.Event* name of event, or "" for events the
+// mapper does not handle.
+func eventType(event couchbase.Event) string {
+	switch {
+	case event.IsMutated:
+		return config.EventMutation
+	case event.IsDeleted:
+		return config.EventDeletion
+	case event.IsExpired:
+		return config.EventExpiration
+	}
+	return ""
+}
@@ -0,0 +1,14 @@
+package connector
+
+import "fmt"
+
+// getNestedField evaluates the field path fieldPath against document. Paths
+// that match several values, such as items[*].sku, return them as a list.
+// Paths are validated at config load, so one that does not compile panics.
+func getNestedField(c *cache, document map[string]interface{}, fieldPath string) (interface{}, bool) {
+	path, err := c.compilePath(fieldPath)
+	if err != nil {
+		panic(fmt.Sprintf("invalid field path %s: %v", fieldPath, err))
+	}
+	return path.Get(document)
+}
@@ -0,0 +1,46 @@
+package dcpcassandra
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Trendyol/go-dcp/models"
+	"github.com/couchbase/gocbcore/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Trendyol/go-dcp-cassandra/cassandra"
+	"github.com/Trendyol/go-dcp-cassandra/config"
+	connectorpkg "github.com/Trendyol/go-dcp-cassandra/connector"
+	"github.com/Trendyol/go-dcp-cassandra/couchbase"
+)
+
+// Events of an unnamed scope are mapped as _default, the scope the mappings
+// are validated against at startup.
+func TestConnector_EventDefaultsScope(t *testing.T) {
+	cfg := &config.Connector{}
+	mapper, err := connectorpkg.NewDefaultMapper([]config.CollectionTableMapping{
+		{Collection: "_default.orders", TableName: "orders", FieldMappings: map[string]string{"id": "_key", "scope": "_scope"}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, mapper.CheckCollections(dcpScopeName(cfg), []string{"orders"}))
+	c := &connector{config: cfg, mapper: mapper.Map}
+
+	e, ok := c.event(models.DcpMutation{
+		DcpMutation:    &gocbcore.DcpMutation{Key: []byte("o1"), Value: []byte(`{}`), Datatype: couchbase.DatatypeJSON},
+		CollectionName: "orders",
+		EventTime:      time.Now(),
+	})
+	require.True(t, ok)
+	assert.Equal(t, "_default", e.ScopeName)
+
+	mapped := c.mapper(e)
+	require.Len(t, mapped, 1)
+	assert.Equal(t, "_default", mapped[0].(*cassandra.Raw).Document["scope"])
+
+	cfg.Dcp.ScopeName = "sales"
+	e, _ = c.event(models.DcpDeletion{
+		DcpDeletion: &gocbcore.DcpDeletion{Key: []byte("o1")}, CollectionName: "orders", EventTime: time.Now(),
+	})
+	assert.Equal(t, "sales", e.ScopeName)
+}
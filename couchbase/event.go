@@ -2,16 +2,40 @@ package couchbase
 
 import "time"
 
+// DCP datatype bits of a document body.
+const (
+	DatatypeJSON   uint8 = 0x01
+	DatatypeSnappy uint8 = 0x02
+	DatatypeXattr  uint8 = 0x04
+)
+
+// IsBinaryDatatype reports whether a body of datatype is not plain JSON:
+// the server did not flag it as JSON, or it is still compressed or
+// prefixed with extended attributes.
+func IsBinaryDatatype(datatype uint8) bool {
+	return datatype&DatatypeJSON == 0 || datatype&(DatatypeSnappy|DatatypeXattr) != 0
+}
+
 type Event struct {
 	CollectionName string
+	ScopeName      string
 	EventTime      time.Time
-	Key            []byte
-	Value          []byte
-	Cas            uint64
-	VbID           uint16
-	IsDeleted      bool
-	IsExpired      bool
-	IsMutated      bool
+	// Expiry is the document's expiration time, zero when it does not
+	// expire. Only mutations carry it.
+	Expiry    time.Time
+	Key       []byte
+	Value     []byte
+	Cas       uint64
+	RevSeqNo  uint64
+	VbID      uint16
+	IsDeleted bool
+	IsExpired bool
+	IsMutated bool
+	// Datatype is the DCP datatype of Value.
+	Datatype uint8
+	// Binary is true when Datatype says Value is not plain JSON, whatever
+	// its bytes look like.
+	Binary bool
 }
 
 func NewDeleteEvent(key, value []byte, collectionName string, eventTime time.Time, cas uint64, vbID uint16) Event {
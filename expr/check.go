@@ -0,0 +1,286 @@
+package expr
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// function is a built-in function. params lists the accepted types of each
+// parameter; the last entry applies to any further arguments when variadic.
+type function struct {
+	call     func(args []interface{}) (interface{}, error)
+	params   [][]Type
+	result   Type
+	variadic bool
+}
+
+var (
+	anyType    = []Type{Dyn}
+	stringType = []Type{String}
+)
+
+var functions = map[string]function{
+	"has":      {params: [][]Type{anyType}, result: Bool},
+	"coalesce": {params: [][]Type{anyType}, result: Dyn, variadic: true},
+	"hash": {params: [][]Type{{String, Int}}, result: Int, call: func(args []interface{}) (interface{}, error) {
+		text, err := formatValue(args[0])
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(text))
+		return int64(h.Sum32()), err
+	}},
+	"lower": stringFunction(strings.ToLower),
+	"upper": stringFunction(strings.ToUpper),
+	"trim":  stringFunction(strings.TrimSpace),
+	"size": {params: [][]Type{{String, List, Map}}, result: Int, call: func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case string:
+			return int64(utf8.RuneCountInString(v)), nil
+		case []interface{}:
+			return int64(len(v)), nil
+		case map[string]interface{}:
+			return int64(len(v)), nil
+		}
+		return nil, fmt.Errorf("unsupported %s", typeOf(args[0]))
+	}},
+	"startsWith": stringPredicate(strings.HasPrefix),
+	"endsWith":   stringPredicate(strings.HasSuffix),
+	"contains":   stringPredicate(strings.Contains),
+	"string": {params: [][]Type{anyType}, result: String, call: func(args []interface{}) (interface{}, error) {
+		return formatValue(args[0])
+	}},
+	"int":       {params: [][]Type{{Int, Double, String, Timestamp}}, result: Int, call: toInt},
+	"double":    {params: [][]Type{{Int, Double, String}}, result: Double, call: toDoubleFunction},
+	"timestamp": {params: [][]Type{{String, Int, Timestamp}}, result: Timestamp, call: toTimestamp},
+}
+
+func stringFunction(fn func(string) string) function {
+	return function{params: [][]Type{stringType}, result: String, call: func(args []interface{}) (interface{}, error) {
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string, got %s", typeOf(args[0]))
+		}
+		return fn(s), nil
+	}}
+}
+
+func stringPredicate(fn func(string, string) bool) function {
+	return function{params: [][]Type{stringType, stringType}, result: Bool, call: func(args []interface{}) (interface{}, error) {
+		s, ok := args[0].(string)
+		other, otherOK := args[1].(string)
+		if !ok || !otherOK {
+			return nil, fmt.Errorf("expected strings, got %s and %s", typeOf(args[0]), typeOf(args[1]))
+		}
+		return fn(s, other), nil
+	}}
+}
+
+func toInt(args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case int64:
+		return v, nil
+	case float64:
+		if math.IsNaN(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return nil, fmt.Errorf("%v is out of int range", v)
+		}
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	case time.Time:
+		return v.Unix(), nil
+	}
+	return nil, fmt.Errorf("cannot convert %s to int", typeOf(args[0]))
+}
+
+func toDoubleFunction(args []interface{}) (interface{}, error) {
+	if f, ok := toDouble(args[0]); ok {
+		return f, nil
+	}
+	if s, ok := args[0].(string); ok {
+		return strconv.ParseFloat(strings.TrimSpace(s), 64)
+	}
+	return nil, fmt.Errorf("cannot convert %s to double", typeOf(args[0]))
+}
+
+// toTimestamp parses RFC 3339 strings; ints are epoch seconds.
+func toTimestamp(args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case time.Time:
+		return v, nil
+	case int64:
+		return time.Unix(v, 0).UTC(), nil
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(v))
+		return t.UTC(), err
+	}
+	return nil, fmt.Errorf("cannot convert %s to timestamp", typeOf(args[0]))
+}
+
+// checker computes static types and rejects expressions that cannot
+// succeed whatever the data.
+type checker struct {
+	declarations map[string]Type
+}
+
+func (c *checker) check(n node) (Type, error) {
+	switch n := n.(type) {
+	case *literalNode:
+		return typeOf(n.value), nil
+	case *identNode:
+		if typ, ok := c.declarations[n.name]; ok {
+			return typ, nil
+		}
+		return Dyn, nil
+	case *indexNode:
+		target, err := c.check(n.target)
+		if err != nil {
+			return Dyn, err
+		}
+		if !oneOf(target, Dyn, Map, List, Null) {
+			return Dyn, fmt.Errorf("cannot access a field of %s", target)
+		}
+		_, err = c.check(n.index)
+		return Dyn, err
+	case *unaryNode:
+		return c.checkUnary(n)
+	case *binaryNode:
+		return c.checkBinary(n)
+	case *conditionalNode:
+		return c.checkConditional(n)
+	case *callNode:
+		return c.checkCall(n)
+	}
+	return Dyn, fmt.Errorf("unknown expression")
+}
+
+func (c *checker) checkUnary(n *unaryNode) (Type, error) {
+	operand, err := c.check(n.operand)
+	if err != nil {
+		return Dyn, err
+	}
+	if n.op == "!" && oneOf(operand, Bool, Dyn) {
+		return Bool, nil
+	}
+	if n.op == "-" && oneOf(operand, Int, Double, Dyn) {
+		return operand, nil
+	}
+	return Dyn, fmt.Errorf("cannot apply %s to %s", n.op, operand)
+}
+
+//nolint:gocyclo
+func (c *checker) checkBinary(n *binaryNode) (Type, error) {
+	left, err := c.check(n.left)
+	if err != nil {
+		return Dyn, err
+	}
+	right, err := c.check(n.right)
+	if err != nil {
+		return Dyn, err
+	}
+	mismatch := fmt.Errorf("cannot apply %s to %s and %s", n.op, left, right)
+	numeric := func(t Type) bool { return oneOf(t, Int, Double, Dyn) }
+
+	switch n.op {
+	case "&&", "||":
+		if !oneOf(left, Bool, Dyn) || !oneOf(right, Bool, Dyn) {
+			return Dyn, mismatch
+		}
+		return Bool, nil
+	case "==", "!=":
+		return Bool, nil
+	case "<", "<=", ">", ">=":
+		if left == Dyn || right == Dyn || (numeric(left) && numeric(right)) ||
+			(left == right && oneOf(left, String, Timestamp)) {
+			return Bool, nil
+		}
+		return Dyn, mismatch
+	case "+":
+		if left == String || right == String {
+			if !oneOf(left, String, Dyn) || !oneOf(right, String, Dyn) {
+				return Dyn, mismatch
+			}
+			return String, nil
+		}
+	case "%":
+		if !oneOf(left, Int, Dyn) || !oneOf(right, Int, Dyn) {
+			return Dyn, mismatch
+		}
+		return Int, nil
+	}
+
+	if !numeric(left) || !numeric(right) {
+		return Dyn, mismatch
+	}
+	switch {
+	case left == Dyn || right == Dyn:
+		return Dyn, nil
+	case left == Int && right == Int:
+		return Int, nil
+	default:
+		return Double, nil
+	}
+}
+
+func (c *checker) checkConditional(n *conditionalNode) (Type, error) {
+	cond, err := c.check(n.cond)
+	if err != nil {
+		return Dyn, err
+	}
+	if !oneOf(cond, Bool, Dyn) {
+		return Dyn, fmt.Errorf("condition must be a bool, not %s", cond)
+	}
+	then, err := c.check(n.then)
+	if err != nil {
+		return Dyn, err
+	}
+	otherwise, err := c.check(n.otherwise)
+	if err != nil {
+		return Dyn, err
+	}
+	if then == otherwise {
+		return then, nil
+	}
+	return Dyn, nil
+}
+
+func (c *checker) checkCall(n *callNode) (Type, error) {
+	fn, ok := functions[n.name]
+	if !ok {
+		return Dyn, fmt.Errorf("unknown function %s at position %d", n.name, n.pos)
+	}
+	if len(n.args) < len(fn.params) || (!fn.variadic && len(n.args) > len(fn.params)) {
+		return Dyn, fmt.Errorf("%s expects %d argument(s), got %d", n.name, len(fn.params), len(n.args))
+	}
+	if n.name == "has" {
+		switch n.args[0].(type) {
+		case *identNode, *indexNode:
+		default:
+			return Dyn, fmt.Errorf("has expects a field, such as has(a.b)")
+		}
+	}
+
+	for i, arg := range n.args {
+		typ, err := c.check(arg)
+		if err != nil {
+			return Dyn, err
+		}
+		accepted := fn.params[min(i, len(fn.params)-1)]
+		if typ != Dyn && !oneOf(Dyn, accepted...) && !oneOf(typ, accepted...) {
+			return Dyn, fmt.Errorf("%s does not accept %s as argument %d", n.name, typ, i+1)
+		}
+	}
+	return fn.result, nil
+}
+
+func oneOf(t Type, types ...Type) bool {
+	for _, candidate := range types {
+		if t == candidate {
+			return true
+		}
+	}
+	return false
+}
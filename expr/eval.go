@@ -0,0 +1,413 @@
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+type node interface {
+	eval(vars Resolver) (interface{}, error)
+}
+
+type literalNode struct{ value interface{} }
+
+type identNode struct{ name string }
+
+// indexNode is both `target.field` and `target[index]`.
+type indexNode struct{ target, index node }
+
+type unaryNode struct {
+	operand node
+	op      string
+}
+
+type binaryNode struct {
+	left, right node
+	op          string
+}
+
+type conditionalNode struct{ cond, then, otherwise node }
+
+type callNode struct {
+	name string
+	args []node
+	pos  int
+}
+
+func (n *literalNode) eval(Resolver) (interface{}, error) {
+	return n.value, nil
+}
+
+func (n *identNode) eval(vars Resolver) (interface{}, error) {
+	value, _, err := lookup(n, vars)
+	return value, err
+}
+
+func (n *indexNode) eval(vars Resolver) (interface{}, error) {
+	value, _, err := lookup(n, vars)
+	return value, err
+}
+
+// lookup resolves an identifier or field access and reports whether the
+// field exists. Accessing a field of a missing or null value yields a
+// missing field rather than an error.
+func lookup(n node, vars Resolver) (interface{}, bool, error) {
+	switch n := n.(type) {
+	case *identNode:
+		value, exists := vars(n.name)
+		return normalize(value), exists, nil
+	case *indexNode:
+		target, exists, err := lookup(n.target, vars)
+		if err != nil || !exists || target == nil {
+			return nil, false, err
+		}
+		index, err := n.index.eval(vars)
+		if err != nil {
+			return nil, false, err
+		}
+		return indexValue(target, index)
+	default:
+		value, err := n.eval(vars)
+		return value, value != nil, err
+	}
+}
+
+func indexValue(target, index interface{}) (interface{}, bool, error) {
+	switch container := target.(type) {
+	case map[string]interface{}:
+		key, ok := index.(string)
+		if !ok {
+			return nil, false, fmt.Errorf("map key must be a string, not %s", typeOf(index))
+		}
+		value, exists := container[key]
+		return normalize(value), exists, nil
+	case []interface{}:
+		i, ok := index.(int64)
+		if !ok {
+			return nil, false, fmt.Errorf("list index must be an int, not %s", typeOf(index))
+		}
+		if i < 0 || i >= int64(len(container)) {
+			return nil, false, nil
+		}
+		return normalize(container[i]), true, nil
+	default:
+		return nil, false, fmt.Errorf("cannot access a field of %s", typeOf(target))
+	}
+}
+
+func (n *unaryNode) eval(vars Resolver) (interface{}, error) {
+	value, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch v := value.(type) {
+	case bool:
+		if n.op == "!" {
+			return !v, nil
+		}
+	case int64:
+		if n.op == "-" {
+			return -v, nil
+		}
+	case float64:
+		if n.op == "-" {
+			return -v, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot apply %s to %s", n.op, typeOf(value))
+}
+
+func (n *binaryNode) eval(vars Resolver) (interface{}, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "&&" || n.op == "||" {
+		return n.evalLogical(left, vars)
+	}
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "<", "<=", ">", ">=":
+		cmp, err := compare(left, right)
+		if err != nil {
+			return nil, fmt.Errorf("cannot compare %s and %s", typeOf(left), typeOf(right))
+		}
+		switch n.op {
+		case "<":
+			return cmp < 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">":
+			return cmp > 0, nil
+		default:
+			return cmp >= 0, nil
+		}
+	default:
+		return arithmetic(n.op, left, right)
+	}
+}
+
+func (n *binaryNode) evalLogical(left interface{}, vars Resolver) (interface{}, error) {
+	l, ok := left.(bool)
+	if !ok {
+		return nil, fmt.Errorf("cannot apply %s to %s", n.op, typeOf(left))
+	}
+	if (n.op == "&&" && !l) || (n.op == "||" && l) {
+		return l, nil
+	}
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := right.(bool)
+	if !ok {
+		return nil, fmt.Errorf("cannot apply %s to %s", n.op, typeOf(right))
+	}
+	return r, nil
+}
+
+func (n *conditionalNode) eval(vars Resolver) (interface{}, error) {
+	cond, err := n.cond.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := cond.(bool)
+	if !ok {
+		return nil, fmt.Errorf("condition must be a bool, not %s", typeOf(cond))
+	}
+	if c {
+		return n.then.eval(vars)
+	}
+	return n.otherwise.eval(vars)
+}
+
+func (n *callNode) eval(vars Resolver) (interface{}, error) {
+	switch n.name {
+	case "has":
+		_, exists, err := lookup(n.args[0], vars)
+		return exists, err
+	case "coalesce":
+		for _, arg := range n.args {
+			value, err := arg.eval(vars)
+			if err != nil || value != nil {
+				return value, err
+			}
+		}
+		return nil, nil
+	}
+
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			return nil, fmt.Errorf("%s: argument %d is null", n.name, i+1)
+		}
+		args[i] = value
+	}
+	result, err := functions[n.name].call(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", n.name, err)
+	}
+	return result, nil
+}
+
+// normalize converts decoded JSON and event metadata values to the types
+// of the language.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return v.String()
+		}
+		return f
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		if v > math.MaxInt64 {
+			return float64(v)
+		}
+		return int64(v)
+	case float32:
+		return float64(v)
+	default:
+		return value
+	}
+}
+
+func typeOf(value interface{}) Type {
+	switch value.(type) {
+	case nil:
+		return Null
+	case bool:
+		return Bool
+	case int64:
+		return Int
+	case float64:
+		return Double
+	case string:
+		return String
+	case time.Time:
+		return Timestamp
+	case []interface{}:
+		return List
+	case map[string]interface{}:
+		return Map
+	default:
+		return Dyn
+	}
+}
+
+func arithmetic(op string, left, right interface{}) (interface{}, error) {
+	if l, ok := left.(int64); ok {
+		if r, ok := right.(int64); ok {
+			return intArithmetic(op, l, r)
+		}
+	}
+	if l, ok := left.(string); ok && op == "+" {
+		if r, ok := right.(string); ok {
+			return l + r, nil
+		}
+	}
+	l, lok := toDouble(left)
+	r, rok := toDouble(right)
+	if !lok || !rok || op == "%" {
+		return nil, fmt.Errorf("cannot apply %s to %s and %s", op, typeOf(left), typeOf(right))
+	}
+	switch op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	default:
+		return l / r, nil
+	}
+}
+
+func intArithmetic(op string, l, r int64) (interface{}, error) {
+	switch op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	}
+	if r == 0 {
+		return nil, fmt.Errorf("division by zero")
+	}
+	if op == "/" {
+		return l / r, nil
+	}
+	return l % r, nil
+}
+
+func toDouble(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// equal compares numbers by value, timestamps by instant and anything else
+// deeply. Values of different types are not equal.
+func equal(left, right interface{}) bool {
+	if l, ok := toDouble(left); ok {
+		r, ok := toDouble(right)
+		if li, lok := left.(int64); lok {
+			if ri, rok := right.(int64); rok {
+				return li == ri
+			}
+		}
+		return ok && l == r
+	}
+	if l, ok := left.(time.Time); ok {
+		r, ok := right.(time.Time)
+		return ok && l.Equal(r)
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+func compare(left, right interface{}) (int, error) {
+	if l, ok := left.(int64); ok {
+		if r, ok := right.(int64); ok {
+			return compareOrdered(l, r), nil
+		}
+	}
+	if l, ok := toDouble(left); ok {
+		if r, ok := toDouble(right); ok {
+			return compareOrdered(l, r), nil
+		}
+	}
+	if l, ok := left.(string); ok {
+		if r, ok := right.(string); ok {
+			return compareOrdered(l, r), nil
+		}
+	}
+	if l, ok := left.(time.Time); ok {
+		if r, ok := right.(time.Time); ok {
+			return l.Compare(r), nil
+		}
+	}
+	return 0, fmt.Errorf("not comparable")
+}
+
+func compareOrdered[T int64 | float64 | string](l, r T) int {
+	switch {
+	case l < r:
+		return -1
+	case l > r:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// formatValue is the string() conversion.
+func formatValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano), nil
+	default:
+		encoded, err := json.Marshal(v)
+		return string(encoded), err
+	}
+}
@@ -0,0 +1,106 @@
+// Package expr implements the small, CEL-like expression language used by
+// computed columns in fieldMappings.
+//
+// An expression combines identifiers, literals, operators and functions:
+//
+//	first + ' ' + last
+//	hash(_key) % 16
+//	status == 'ACTIVE' && has(paidAt)
+//
+// Identifiers name top-level document fields or declared variables; nested
+// fields are reached with `.` or `[...]`. Missing fields are null, and
+// accessing a field of null is null. Values are null, bool, int (int64),
+// double (float64), string, timestamp (time.Time), list and map.
+//
+// Operators, from lowest to highest precedence: `?:`, `||`, `&&`,
+// `== != < <= > >=`, `+ -`, `* / %`, unary `! -`. `+` adds numbers and
+// concatenates strings; mixing ints and doubles yields a double.
+//
+// Functions: has(field), coalesce(a, b, ...), hash(s), lower(s), upper(s),
+// trim(s), size(x), startsWith(s, prefix), endsWith(s, suffix),
+// contains(s, substr), string(x), int(x), double(x), timestamp(x).
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type is the static type of an expression or variable.
+type Type int
+
+const (
+	// Dyn is the type of values only known at evaluation, such as document
+	// fields.
+	Dyn Type = iota
+	Null
+	Bool
+	Int
+	Double
+	String
+	Timestamp
+	List
+	Map
+)
+
+var typeNames = map[Type]string{
+	Dyn: "dyn", Null: "null", Bool: "bool", Int: "int", Double: "double",
+	String: "string", Timestamp: "timestamp", List: "list", Map: "map",
+}
+
+func (t Type) String() string {
+	return typeNames[t]
+}
+
+// Resolver returns the value of a top-level identifier and whether it
+// exists.
+type Resolver func(name string) (interface{}, bool)
+
+// Program is a compiled, type-checked expression. It is safe for
+// concurrent use.
+type Program struct {
+	root   node
+	source string
+	typ    Type
+}
+
+// Compile parses and type-checks source. declarations gives the static type
+// of known variables; any other identifier is a Dyn document field.
+func Compile(source string, declarations map[string]Type) (*Program, error) {
+	p := &parser{lexer: lexer{input: source}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if p.token.kind != tokenEOF {
+		return nil, p.errorf("unexpected %s", p.token)
+	}
+
+	typ, err := (&checker{declarations: declarations}).check(root)
+	if err != nil {
+		return nil, err
+	}
+	return &Program{root: root, source: source, typ: typ}, nil
+}
+
+// Type returns the static result type of the program, Dyn when it depends
+// on the data.
+func (p *Program) Type() Type {
+	return p.typ
+}
+
+func (p *Program) String() string {
+	return p.source
+}
+
+// Eval evaluates the program with identifiers resolved by vars.
+func (p *Program) Eval(vars Resolver) (interface{}, error) {
+	value, err := p.root.eval(vars)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", strings.TrimSpace(p.source), err)
+	}
+	return value, nil
+}
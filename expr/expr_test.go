@@ -0,0 +1,158 @@
+package expr
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func documentResolver(doc map[string]interface{}) Resolver {
+	return func(name string) (interface{}, bool) {
+		value, exists := doc[name]
+		return value, exists
+	}
+}
+
+func TestProgram_Eval(t *testing.T) {
+	doc := map[string]interface{}{
+		"first":   "Ada",
+		"last":    "Lovelace",
+		"status":  "ACTIVE",
+		"age":     json.Number("36"),
+		"score":   json.Number("9.5"),
+		"tags":    []interface{}{"a", "b"},
+		"address": map[string]interface{}{"city": "London", "zip": nil},
+		"created": "2024-01-02T03:04:05Z",
+	}
+
+	tests := []struct {
+		expected interface{}
+		source   string
+	}{
+		{source: "first + ' ' + last", expected: "Ada Lovelace"},
+		{source: "status == 'ACTIVE'", expected: true},
+		{source: "status != \"ACTIVE\"", expected: false},
+		{source: "age + 1", expected: int64(37)},
+		{source: "age * score", expected: 342.0},
+		{source: "age / 5", expected: int64(7)},
+		{source: "age % 5", expected: int64(1)},
+		{source: "-age", expected: int64(-36)},
+		{source: "age == 36.0", expected: true},
+		{source: "age >= 18 && status == 'ACTIVE'", expected: true},
+		{source: "age < 18 || !has(missing)", expected: true},
+		{source: "age > 40 ? 'senior' : 'junior'", expected: "junior"},
+		{source: "(1 + 2) * 3", expected: int64(9)},
+		{source: "address.city", expected: "London"},
+		{source: "address['city']", expected: "London"},
+		{source: "tags[1]", expected: "b"},
+		{source: "tags[5]", expected: nil},
+		{source: "missing.deeper", expected: nil},
+		{source: "has(address.zip)", expected: true},
+		{source: "has(address.street)", expected: false},
+		{source: "coalesce(missing, address.zip, 'none')", expected: "none"},
+		{source: "hash('user::1') % 16", expected: int64(5)},
+		{source: "lower(first) + upper(last)", expected: "adaLOVELACE"},
+		{source: "trim('  x  ')", expected: "x"},
+		{source: "size(tags) + size(first) + size(address)", expected: int64(7)},
+		{source: "startsWith(first, 'A') && endsWith(last, 'ce') && contains(last, 'vel')", expected: true},
+		{source: "string(age) + string(true)", expected: "36true"},
+		{source: "int('42') + int(2.9)", expected: int64(44)},
+		{source: "double('1.5')", expected: 1.5},
+		{source: "timestamp(created) > timestamp(0)", expected: true},
+		{source: "int(timestamp(created))", expected: int64(1704164645)},
+		{source: "1e3", expected: 1000.0},
+		{source: "'it\\'s'", expected: "it's"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			program, err := Compile(tt.source, nil)
+			require.NoError(t, err)
+
+			value, err := program.Eval(documentResolver(doc))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, value)
+		})
+	}
+}
+
+func TestProgram_EvalDeclarations(t *testing.T) {
+	eventTime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	vars := map[string]interface{}{"_key": "user::1", "_cas": uint64(12), "_eventTime": eventTime}
+	declarations := map[string]Type{"_key": String, "_cas": Int, "_eventTime": Timestamp}
+
+	program, err := Compile("_key + ':' + string(_cas) + ':' + string(_eventTime)", declarations)
+	require.NoError(t, err)
+	assert.Equal(t, String, program.Type())
+
+	value, err := program.Eval(documentResolver(vars))
+	require.NoError(t, err)
+	assert.Equal(t, "user::1:12:2024-05-06T07:08:09Z", value)
+}
+
+func TestCompile_Errors(t *testing.T) {
+	declarations := map[string]Type{"_key": String, "_cas": Int}
+
+	tests := []struct {
+		source string
+		errMsg string
+	}{
+		{source: "", errMsg: "unexpected end of expression at position 0"},
+		{source: "first +", errMsg: "unexpected end of expression"},
+		{source: "first last", errMsg: `unexpected "last" at position 6`},
+		{source: "(first", errMsg: `expected ")"`},
+		{source: "'open", errMsg: "unterminated string"},
+		{source: "first # last", errMsg: "unexpected character '#'"},
+		{source: "nope(first)", errMsg: "unknown function nope"},
+		{source: "lower(first, last)", errMsg: "lower expects 1 argument(s), got 2"},
+		{source: "has('x')", errMsg: "has expects a field"},
+		{source: "_key % 16", errMsg: "cannot apply % to string and int"},
+		{source: "_key + 1", errMsg: "cannot apply + to string and int"},
+		{source: "_cas && true", errMsg: "cannot apply && to int and bool"},
+		{source: "!_key", errMsg: "cannot apply ! to string"},
+		{source: "_key < 1", errMsg: "cannot apply < to string and int"},
+		{source: "_key.tenant", errMsg: "cannot access a field of string"},
+		{source: "_cas ? 1 : 2", errMsg: "condition must be a bool, not int"},
+		{source: "lower(_cas)", errMsg: "lower does not accept int as argument 1"},
+		{source: "1.5 % 2", errMsg: "cannot apply % to double and int"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			_, err := Compile(tt.source, declarations)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
+
+func TestProgram_EvalErrors(t *testing.T) {
+	doc := map[string]interface{}{"name": "x", "zero": json.Number("0"), "count": json.Number("3")}
+
+	tests := []struct {
+		source string
+		errMsg string
+	}{
+		{source: "count / zero", errMsg: "count / zero: division by zero"},
+		{source: "name + count", errMsg: "cannot apply + to string and int"},
+		{source: "name < count", errMsg: "cannot compare string and int"},
+		{source: "lower(missing)", errMsg: "lower: argument 1 is null"},
+		{source: "name.first", errMsg: "cannot access a field of string"},
+		{source: "name ? 1 : 2", errMsg: "condition must be a bool, not string"},
+		{source: "int(name)", errMsg: "int: strconv.ParseInt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			program, err := Compile(tt.source, nil)
+			require.NoError(t, err)
+
+			_, err = program.Eval(documentResolver(doc))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenInt
+	tokenDouble
+	tokenString
+	tokenOperator
+)
+
+type token struct {
+	text  string
+	value interface{}
+	kind  tokenKind
+	pos   int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// operators lists the operator tokens, longest first.
+var operators = []string{
+	"==", "!=", "<=", ">=", "&&", "||",
+	"+", "-", "*", "/", "%", "<", ">", "!", "?", ":", "(", ")", "[", "]", ".", ",",
+}
+
+type lexer struct {
+	input string
+	pos   int
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.input) && unicode.IsSpace(rune(l.input[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.input) {
+		return token{kind: tokenEOF, pos: start}, nil
+	}
+
+	c := l.input[l.pos]
+	switch {
+	case c == '_' || unicode.IsLetter(rune(c)):
+		for l.pos < len(l.input) && (l.input[l.pos] == '_' || isAlphaNumeric(l.input[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokenIdent, text: l.input[start:l.pos], pos: start}, nil
+	case c >= '0' && c <= '9':
+		return l.number()
+	case c == '\'' || c == '"':
+		return l.string(c)
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(l.input[l.pos:], op) {
+			l.pos += len(op)
+			return token{kind: tokenOperator, text: op, pos: start}, nil
+		}
+	}
+	return token{}, fmt.Errorf("unexpected character %q at position %d", c, start)
+}
+
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	isDouble := false
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		switch {
+		case c >= '0' && c <= '9':
+		case c == '.' || c == 'e' || c == 'E':
+			isDouble = true
+		case (c == '+' || c == '-') && (l.input[l.pos-1] == 'e' || l.input[l.pos-1] == 'E'):
+		default:
+			return l.numberToken(start, isDouble)
+		}
+		l.pos++
+	}
+	return l.numberToken(start, isDouble)
+}
+
+func (l *lexer) numberToken(start int, isDouble bool) (token, error) {
+	text := l.input[start:l.pos]
+	if isDouble {
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return token{}, fmt.Errorf("invalid number %s at position %d", text, start)
+		}
+		return token{kind: tokenDouble, text: text, value: f, pos: start}, nil
+	}
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return token{}, fmt.Errorf("invalid number %s at position %d", text, start)
+	}
+	return token{kind: tokenInt, text: text, value: n, pos: start}, nil
+}
+
+func (l *lexer) string(quote byte) (token, error) {
+	start := l.pos
+	var b strings.Builder
+	for l.pos++; l.pos < len(l.input); l.pos++ {
+		c := l.input[l.pos]
+		switch {
+		case c == quote:
+			l.pos++
+			return token{kind: tokenString, text: l.input[start:l.pos], value: b.String(), pos: start}, nil
+		case c == '\\' && l.pos+1 < len(l.input):
+			l.pos++
+			switch escaped := l.input[l.pos]; escaped {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case '\\', '\'', '"':
+				b.WriteByte(escaped)
+			default:
+				return token{}, fmt.Errorf("invalid escape \\%c at position %d", escaped, l.pos-1)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return token{}, fmt.Errorf("unterminated string at position %d", start)
+}
+
+func isAlphaNumeric(c byte) bool {
+	return unicode.IsLetter(rune(c)) || (c >= '0' && c <= '9')
+}
+
+// parser is a recursive descent parser with one token of lookahead.
+type parser struct {
+	lexer lexer
+	token token
+}
+
+func (p *parser) advance() error {
+	t, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.token = t
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s at position %d", fmt.Sprintf(format, args...), p.token.pos)
+}
+
+func (p *parser) isOperator(ops ...string) bool {
+	if p.token.kind != tokenOperator {
+		return false
+	}
+	for _, op := range ops {
+		if p.token.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.isOperator(op) {
+		return p.errorf("expected %q, found %s", op, p.token)
+	}
+	return p.advance()
+}
+
+func (p *parser) parseExpression() (node, error) {
+	cond, err := p.parseBinary(0)
+	if err != nil || !p.isOperator("?") {
+		return cond, err
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	then, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	return &conditionalNode{cond: cond, then: then, otherwise: otherwise}, nil
+}
+
+// binaryLevels lists binary operators from lowest to highest precedence.
+var binaryLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) parseBinary(level int) (node, error) {
+	if level == len(binaryLevels) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator(binaryLevels[level]...) {
+		op := p.token.text
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOperator("!", "-") {
+		op := p.token.text
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: op, operand: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.isOperator("."):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if p.token.kind != tokenIdent {
+				return nil, p.errorf("expected field name, found %s", p.token)
+			}
+			n = &indexNode{target: n, index: &literalNode{value: p.token.text}}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		case p.isOperator("["):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			index, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			n = &indexNode{target: n, index: index}
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.token
+	switch t.kind {
+	case tokenInt, tokenDouble, tokenString:
+		return &literalNode{value: t.value}, p.advance()
+	case tokenIdent:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		switch t.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+		if p.isOperator("(") {
+			return p.parseCall(t)
+		}
+		return &identNode{name: t.text}, nil
+	case tokenOperator:
+		if t.text == "(" {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			n, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		}
+	}
+	return nil, p.errorf("unexpected %s", t)
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	call := &callNode{name: name.text, pos: name.pos}
+	for !p.isOperator(")") {
+		if len(call.args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+	}
+	return call, p.advance()
+}
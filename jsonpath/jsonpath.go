@@ -0,0 +1,203 @@
+// Package jsonpath implements the field paths of fieldMappings sources,
+// filters, explode and keyspace placeholders. It is JSONPath without filter
+// expressions, evaluated against decoded JSON documents:
+//
+//	meta.version            $.meta.version
+//	items[0].sku            items[-1].sku        (last element)
+//	items[*].sku            items[1:3]           tags[0,2]
+//	['order.id']            order\.id            (keys with dots)
+//	..sku                   (every sku at any depth)
+//
+// The leading `$` or `$.` is optional. In dot notation `\` escapes the next
+// character; bracket keys are quoted with ' or " and use the escapes of JSON
+// strings. Negative indexes and slice bounds count from the end.
+//
+// A singular path, one of names and indexes only, yields one value. Any
+// other path yields the list of values it matches, in document order with
+// object members sorted by key.
+package jsonpath
+
+import "sort"
+
+// Path is a compiled path. It is safe for concurrent use.
+type Path struct {
+	source   string
+	segments []segment
+	singular bool
+}
+
+// segment selects children of every current value, or of every current
+// value and its descendants when descendant is set.
+type segment struct {
+	selectors  []selector
+	descendant bool
+}
+
+type selectorKind int
+
+const (
+	selectName selectorKind = iota
+	selectIndex
+	selectSlice
+	selectWildcard
+)
+
+type selector struct {
+	name  string
+	kind  selectorKind
+	index int
+	// start and end of a slice are nil when omitted.
+	start, end *int
+	step       int
+}
+
+// Compile parses source.
+func Compile(source string) (*Path, error) {
+	segments, err := (&parser{input: source}).parse()
+	if err != nil {
+		return nil, err
+	}
+	singular := true
+	for _, seg := range segments {
+		if seg.descendant || len(seg.selectors) != 1 ||
+			(seg.selectors[0].kind != selectName && seg.selectors[0].kind != selectIndex) {
+			singular = false
+		}
+	}
+	return &Path{source: source, segments: segments, singular: singular}, nil
+}
+
+// Singular reports whether the path yields a single value rather than a
+// list of matches.
+func (p *Path) Singular() bool {
+	return p.singular
+}
+
+func (p *Path) String() string {
+	return p.source
+}
+
+// Get evaluates the path against document. A singular path returns its
+// value and whether it exists; any other path returns the []interface{} of
+// its matches and whether there is at least one.
+func (p *Path) Get(document interface{}) (interface{}, bool) {
+	nodes := []interface{}{document}
+	for _, seg := range p.segments {
+		if seg.descendant {
+			nodes = descendants(nodes)
+		}
+		var next []interface{}
+		for _, node := range nodes {
+			for _, sel := range seg.selectors {
+				next = sel.apply(node, next)
+			}
+		}
+		nodes = next
+	}
+
+	if p.singular {
+		if len(nodes) == 0 {
+			return nil, false
+		}
+		return nodes[0], true
+	}
+	if nodes == nil {
+		nodes = []interface{}{}
+	}
+	return nodes, len(nodes) > 0
+}
+
+// apply appends the children of node that s selects to out.
+func (s selector) apply(node interface{}, out []interface{}) []interface{} {
+	switch container := node.(type) {
+	case map[string]interface{}:
+		switch s.kind {
+		case selectName:
+			if value, exists := container[s.name]; exists {
+				out = append(out, value)
+			}
+		case selectWildcard:
+			for _, key := range sortedKeys(container) {
+				out = append(out, container[key])
+			}
+		}
+	case []interface{}:
+		switch s.kind {
+		case selectIndex:
+			if i := normalizeIndex(s.index, len(container)); i >= 0 && i < len(container) {
+				out = append(out, container[i])
+			}
+		case selectSlice:
+			out = append(out, s.slice(container)...)
+		case selectWildcard:
+			out = append(out, container...)
+		}
+	}
+	return out
+}
+
+func (s selector) slice(list []interface{}) []interface{} {
+	n := len(list)
+	var out []interface{}
+	if s.step > 0 {
+		start, end := bound(s.start, 0, n), bound(s.end, n, n)
+		for i := max(start, 0); i < min(end, n); i += s.step {
+			out = append(out, list[i])
+		}
+		return out
+	}
+	start, end := bound(s.start, n-1, n), bound(s.end, -n-1, n)
+	for i := min(start, n-1); i > max(end, -1); i += s.step {
+		out = append(out, list[i])
+	}
+	return out
+}
+
+// bound returns the slice bound value, or fallback when it is omitted,
+// counting negative bounds from the end.
+func bound(value *int, fallback, n int) int {
+	if value == nil {
+		return fallback
+	}
+	return normalizeIndex(*value, n)
+}
+
+func normalizeIndex(index, n int) int {
+	if index < 0 {
+		return n + index
+	}
+	return index
+}
+
+// descendants returns every node and, depth first, all values nested in
+// it.
+func descendants(nodes []interface{}) []interface{} {
+	var out []interface{}
+	var walk func(node interface{})
+	walk = func(node interface{}) {
+		out = append(out, node)
+		switch container := node.(type) {
+		case map[string]interface{}:
+			for _, key := range sortedKeys(container) {
+				walk(container[key])
+			}
+		case []interface{}:
+			for _, item := range container {
+				walk(item)
+			}
+		}
+	}
+	for _, node := range nodes {
+		walk(node)
+	}
+	return out
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,111 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPath_Get(t *testing.T) {
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"id": "o1",
+		"$type": "order",
+		"meta": {"version": "1.0", "tags": ["a", "b", "c"]},
+		"order.id": "dotted",
+		"it's": "quoted",
+		"items": [
+			{"sku": "A", "qty": 1, "lines": [{"sku": "A1"}]},
+			{"sku": "B", "qty": 2},
+			{"sku": "C", "qty": 3}
+		],
+		"empty": []
+	}`), &doc))
+
+	tests := []struct {
+		expected interface{}
+		path     string
+		exists   bool
+	}{
+		{path: "id", expected: "o1", exists: true},
+		{path: "$", expected: doc, exists: true},
+		{path: "$.id", expected: "o1", exists: true},
+		{path: "$type", expected: "order", exists: true},
+		{path: "meta.version", expected: "1.0", exists: true},
+		{path: "$['meta']['version']", expected: "1.0", exists: true},
+		{path: "meta.tags[0]", expected: "a", exists: true},
+		{path: "meta.tags[-1]", expected: "c", exists: true},
+		{path: "meta.tags[3]", exists: false},
+		{path: "items[1].sku", expected: "B", exists: true},
+		{path: "items[-1].qty", expected: 3.0, exists: true},
+		{path: "['order.id']", expected: "dotted", exists: true},
+		{path: `order\.id`, expected: "dotted", exists: true},
+		{path: `["it's"]`, expected: "quoted", exists: true},
+		{path: `['it\'s']`, expected: "quoted", exists: true},
+		{path: `['id']`, expected: "o1", exists: true},
+		{path: "missing.deeper", exists: false},
+		{path: "id.deeper", exists: false},
+		{path: "items[*].sku", expected: []interface{}{"A", "B", "C"}, exists: true},
+		{path: "items.*.sku", expected: []interface{}{"A", "B", "C"}, exists: true},
+		{path: "items[0:2].sku", expected: []interface{}{"A", "B"}, exists: true},
+		{path: "items[-2:].sku", expected: []interface{}{"B", "C"}, exists: true},
+		{path: "items[::-1].sku", expected: []interface{}{"C", "B", "A"}, exists: true},
+		{path: "meta.tags[0,2]", expected: []interface{}{"a", "c"}, exists: true},
+		{path: "items[0]['sku','qty']", expected: []interface{}{"A", 1.0}, exists: true},
+		{path: "items..sku", expected: []interface{}{"A", "A1", "B", "C"}, exists: true},
+		{path: "$..lines[0].sku", expected: []interface{}{"A1"}, exists: true},
+		{path: "empty[*]", expected: []interface{}{}, exists: false},
+		{path: "meta.*", expected: []interface{}{[]interface{}{"a", "b", "c"}, "1.0"}, exists: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			path, err := Compile(tt.path)
+			require.NoError(t, err)
+
+			value, exists := path.Get(doc)
+			assert.Equal(t, tt.exists, exists)
+			assert.Equal(t, tt.expected, value)
+		})
+	}
+}
+
+func TestPath_Singular(t *testing.T) {
+	for path, singular := range map[string]bool{
+		"a.b[0]": true, "$['a'][-1]": true, "a[*]": false, "a..b": false, "a[0:1]": false, "a[0,1]": false,
+	} {
+		compiled, err := Compile(path)
+		require.NoError(t, err)
+		assert.Equal(t, singular, compiled.Singular(), path)
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		path   string
+		errMsg string
+	}{
+		{path: "", errMsg: "empty path"},
+		{path: "a..", errMsg: "expected a name at position 3"},
+		{path: "a.", errMsg: "expected a name at position 2"},
+		{path: "$.", errMsg: "expected a name"},
+		{path: "a[", errMsg: "expected a name, index, slice or *"},
+		{path: "a[0", errMsg: "expected , or ]"},
+		{path: "a['b", errMsg: "unterminated key"},
+		{path: `a['\x']`, errMsg: "invalid escape"},
+		{path: `a\`, errMsg: "dangling escape"},
+		{path: "a[::0]", errMsg: "slice step must not be 0"},
+		{path: "a[?(@.b)]", errMsg: "filter expressions are not supported"},
+		{path: "a[0]b", errMsg: `unexpected 'b' at position 4 of path "a[0]b"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			_, err := Compile(tt.path)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s at position %d of path %q", fmt.Sprintf(format, args...), p.pos, p.input)
+}
+
+func (p *parser) peek(s string) bool {
+	return strings.HasPrefix(p.input[p.pos:], s)
+}
+
+func (p *parser) parse() ([]segment, error) {
+	if strings.TrimSpace(p.input) == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+	switch {
+	case p.peek("$.") && !p.peek("$.."):
+		p.pos += 2
+	case p.input == "$" || p.peek("$[") || p.peek("$.."):
+		p.pos++
+		return p.segments(nil)
+	case p.peek(".") || p.peek("["):
+		return p.segments(nil)
+	}
+	// The first name needs no dot, as in meta.version, and may start with
+	// $, as in $type.
+	seg, err := p.dotSegment()
+	if err != nil {
+		return nil, err
+	}
+	return p.segments([]segment{seg})
+}
+
+func (p *parser) segments(segments []segment) ([]segment, error) {
+	for p.pos < len(p.input) {
+		var (
+			seg segment
+			err error
+		)
+		switch {
+		case p.peek(".."):
+			p.pos += 2
+			if p.peek("[") {
+				seg, err = p.bracketSegment()
+			} else {
+				seg, err = p.dotSegment()
+			}
+			seg.descendant = true
+		case p.peek("."):
+			p.pos++
+			seg, err = p.dotSegment()
+		case p.peek("["):
+			seg, err = p.bracketSegment()
+		default:
+			err = p.errorf("unexpected %q", p.input[p.pos])
+		}
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, seg)
+	}
+	return segments, nil
+}
+
+// dotSegment parses a name, with \ escaping the next character, or *. A
+// name ends at an unescaped . or [.
+func (p *parser) dotSegment() (segment, error) {
+	if p.peek("*") && (p.pos+1 == len(p.input) || p.input[p.pos+1] == '.' || p.input[p.pos+1] == '[') {
+		p.pos++
+		return segment{selectors: []selector{{kind: selectWildcard}}}, nil
+	}
+	var name strings.Builder
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if c == '.' || c == '[' {
+			break
+		}
+		if c == '\\' {
+			if p.pos+1 == len(p.input) {
+				return segment{}, p.errorf("dangling escape")
+			}
+			p.pos++
+			c = p.input[p.pos]
+		}
+		name.WriteByte(c)
+		p.pos++
+	}
+	if name.Len() == 0 {
+		return segment{}, p.errorf("expected a name")
+	}
+	return segment{selectors: []selector{{kind: selectName, name: name.String()}}}, nil
+}
+
+// bracketSegment parses [selector, ...].
+func (p *parser) bracketSegment() (segment, error) {
+	p.pos++
+	var seg segment
+	for {
+		p.skipSpaces()
+		sel, err := p.selector()
+		if err != nil {
+			return segment{}, err
+		}
+		seg.selectors = append(seg.selectors, sel)
+		p.skipSpaces()
+		switch {
+		case p.peek(","):
+			p.pos++
+		case p.peek("]"):
+			p.pos++
+			return seg, nil
+		default:
+			return segment{}, p.errorf("expected , or ]")
+		}
+	}
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *parser) selector() (selector, error) {
+	switch {
+	case p.peek("*"):
+		p.pos++
+		return selector{kind: selectWildcard}, nil
+	case p.peek("'"), p.peek(`"`):
+		name, err := p.quoted()
+		return selector{kind: selectName, name: name}, err
+	case p.peek("?"):
+		return selector{}, p.errorf("filter expressions are not supported")
+	}
+
+	start, err := p.optionalInt()
+	if err != nil {
+		return selector{}, err
+	}
+	if !p.peek(":") {
+		if start == nil {
+			return selector{}, p.errorf("expected a name, index, slice or *")
+		}
+		return selector{kind: selectIndex, index: *start}, nil
+	}
+
+	p.pos++
+	sel := selector{kind: selectSlice, start: start, step: 1}
+	if sel.end, err = p.optionalInt(); err != nil {
+		return selector{}, err
+	}
+	if p.peek(":") {
+		p.pos++
+		step, err := p.optionalInt()
+		if err != nil {
+			return selector{}, err
+		}
+		if step != nil {
+			sel.step = *step
+		}
+		if sel.step == 0 {
+			return selector{}, p.errorf("slice step must not be 0")
+		}
+	}
+	return sel, nil
+}
+
+func (p *parser) optionalInt() (*int, error) {
+	p.skipSpaces()
+	start := p.pos
+	if p.peek("-") {
+		p.pos++
+	}
+	for p.pos < len(p.input) && p.input[p.pos] >= '0' && p.input[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, nil
+	}
+	text := p.input[start:p.pos]
+	n, err := strconv.Atoi(text)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("invalid index %q", text)
+	}
+	p.skipSpaces()
+	return &n, nil
+}
+
+// escapes are the escape sequences of quoted keys besides \uXXXX.
+var escapes = map[byte]byte{
+	'\\': '\\', '\'': '\'', '"': '"', '/': '/', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t',
+}
+
+// quoted parses a ' or " quoted key with the escapes of JSON strings.
+func (p *parser) quoted() (string, error) {
+	quote := p.input[p.pos]
+	p.pos++
+	var name strings.Builder
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return name.String(), nil
+		case c != '\\':
+			name.WriteByte(c)
+			p.pos++
+			continue
+		}
+		if p.pos+1 == len(p.input) {
+			break
+		}
+		escaped := p.input[p.pos+1]
+		if escaped == 'u' && p.pos+6 <= len(p.input) {
+			r, err := strconv.ParseUint(p.input[p.pos+2:p.pos+6], 16, 32)
+			if err != nil {
+				return "", p.errorf("invalid escape")
+			}
+			name.WriteRune(rune(r))
+			p.pos += 6
+			continue
+		}
+		unescaped, ok := escapes[escaped]
+		if !ok {
+			return "", p.errorf("invalid escape")
+		}
+		name.WriteByte(unescaped)
+		p.pos += 2
+	}
+	return "", p.errorf("unterminated key")
+}
@@ -5,19 +5,55 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/Trendyol/go-dcp-cassandra/cassandra"
+	"github.com/Trendyol/go-dcp-cassandra/connector"
 )
 
+// InvalidDocumentReporter is implemented by mappers that count the invalid
+// documents they handled without failing, like connector.DefaultMapper.
+type InvalidDocumentReporter interface {
+	InvalidDocumentMetrics() []connector.InvalidDocumentMetric
+}
+
 type Collector struct {
-	bulk *cassandra.Bulk
+	bulk             *cassandra.Bulk
+	invalidDocuments InvalidDocumentReporter
 
 	processLatency            *prometheus.Desc
 	bulkRequestProcessLatency *prometheus.Desc
 	bulkRequestSize           *prometheus.Desc
 	bulkRequestByteSize       *prometheus.Desc
+	statementReprepares       *prometheus.Desc
+	clusterWriteLatency       *prometheus.Desc
+	clusterWriteErrors        *prometheus.Desc
+	clusterDroppedWrites      *prometheus.Desc
+	hostLatency               *prometheus.Desc
+	hostErrors                *prometheus.Desc
+	hostRetries               *prometheus.Desc
+	hostConnectionEvents      *prometheus.Desc
+	invalidDocumentCount      *prometheus.Desc
 }
 
+// Describe sends every descriptor up front, including those of the
+// per-cluster and per-host series that only appear once writes start, so
+// that the registry checks them all.
 func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
-	prometheus.DescribeByCollect(c, ch)
+	for _, desc := range []*prometheus.Desc{
+		c.processLatency,
+		c.bulkRequestProcessLatency,
+		c.bulkRequestSize,
+		c.bulkRequestByteSize,
+		c.statementReprepares,
+		c.clusterWriteLatency,
+		c.clusterWriteErrors,
+		c.clusterDroppedWrites,
+		c.hostLatency,
+		c.hostErrors,
+		c.hostRetries,
+		c.hostConnectionEvents,
+		c.invalidDocumentCount,
+	} {
+		ch <- desc
+	}
 }
 
 func (c *Collector) Collect(ch chan<- prometheus.Metric) {
@@ -50,6 +86,92 @@ func (c *Collector) Collect(ch chan<- prometheus.Metric) {
 		float64(bulkMetric.BulkRequestByteSize),
 		[]string{}...,
 	)
+
+	ch <- prometheus.MustNewConstMetric(
+		c.statementReprepares,
+		prometheus.CounterValue,
+		float64(bulkMetric.StatementReprepares),
+		[]string{}...,
+	)
+
+	for _, clusterMetric := range c.bulk.GetClusterMetrics() {
+		ch <- prometheus.MustNewConstMetric(
+			c.clusterWriteLatency,
+			prometheus.GaugeValue,
+			float64(clusterMetric.WriteLatencyMs),
+			clusterMetric.Cluster,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.clusterWriteErrors,
+			prometheus.CounterValue,
+			float64(clusterMetric.WriteErrors),
+			clusterMetric.Cluster,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.clusterDroppedWrites,
+			prometheus.CounterValue,
+			float64(clusterMetric.DroppedWrites),
+			clusterMetric.Cluster,
+		)
+	}
+
+	for _, hostMetric := range c.bulk.GetHostMetrics() {
+		ch <- prometheus.MustNewConstHistogram(
+			c.hostLatency,
+			hostMetric.LatencyCount,
+			hostMetric.LatencySumMs,
+			hostMetric.LatencyBuckets,
+			hostMetric.Cluster, hostMetric.Host,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.hostErrors,
+			prometheus.CounterValue,
+			float64(hostMetric.Errors),
+			hostMetric.Cluster, hostMetric.Host,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.hostRetries,
+			prometheus.CounterValue,
+			float64(hostMetric.Retries),
+			hostMetric.Cluster, hostMetric.Host,
+		)
+
+		for event, count := range map[string]uint64{
+			"connected":      hostMetric.Connects,
+			"connect_failed": hostMetric.ConnectErrors,
+			"up":             hostMetric.Ups,
+			"down":           hostMetric.Downs,
+		} {
+			ch <- prometheus.MustNewConstMetric(
+				c.hostConnectionEvents,
+				prometheus.CounterValue,
+				float64(count),
+				hostMetric.Cluster, hostMetric.Host, event,
+			)
+		}
+	}
+
+	if c.invalidDocuments == nil {
+		return
+	}
+	for _, invalidMetric := range c.invalidDocuments.InvalidDocumentMetrics() {
+		ch <- prometheus.MustNewConstMetric(
+			c.invalidDocumentCount,
+			prometheus.CounterValue,
+			float64(invalidMetric.Count),
+			invalidMetric.Table, invalidMetric.Reason, invalidMetric.Policy,
+		)
+	}
+}
+
+// SetInvalidDocumentReporter exports the invalid document counts of
+// reporter, the mapper of the connector.
+func (c *Collector) SetInvalidDocumentReporter(reporter InvalidDocumentReporter) {
+	c.invalidDocuments = reporter
 }
 
 func NewMetricCollector(bulk *cassandra.Bulk) *Collector {
@@ -83,6 +205,69 @@ func NewMetricCollector(bulk *cassandra.Bulk) *Collector {
 			[]string{},
 			nil,
 		),
+
+		statementReprepares: prometheus.NewDesc(
+			prometheus.BuildFQName(helpers.Name, "cassandra_connector_statement_reprepares", "total"),
+			"Cassandra connector prepared statements re-prepared after an unprepared or schema mismatch error",
+			[]string{},
+			nil,
+		),
+
+		clusterWriteLatency: prometheus.NewDesc(
+			prometheus.BuildFQName(helpers.Name, "cassandra_connector_cluster_write_latency_ms", "current"),
+			"Cassandra connector per-cluster write latency ms when dual-write is enabled",
+			[]string{"cluster"},
+			nil,
+		),
+
+		clusterWriteErrors: prometheus.NewDesc(
+			prometheus.BuildFQName(helpers.Name, "cassandra_connector_cluster_write_errors", "total"),
+			"Cassandra connector per-cluster write errors when dual-write is enabled",
+			[]string{"cluster"},
+			nil,
+		),
+
+		clusterDroppedWrites: prometheus.NewDesc(
+			prometheus.BuildFQName(helpers.Name, "cassandra_connector_cluster_dropped_writes", "total"),
+			"Cassandra connector writes a best-effort dual-write secondary dropped because its queue was full",
+			[]string{"cluster"},
+			nil,
+		),
+
+		hostLatency: prometheus.NewDesc(
+			prometheus.BuildFQName(helpers.Name, "cassandra_connector_host_request_latency_ms", "current"),
+			"Cassandra connector request latency ms per host, one observation per driver attempt",
+			[]string{"cluster", "host"},
+			nil,
+		),
+
+		hostErrors: prometheus.NewDesc(
+			prometheus.BuildFQName(helpers.Name, "cassandra_connector_host_request_errors", "total"),
+			"Cassandra connector failed request attempts per host",
+			[]string{"cluster", "host"},
+			nil,
+		),
+
+		hostRetries: prometheus.NewDesc(
+			prometheus.BuildFQName(helpers.Name, "cassandra_connector_host_request_retries", "total"),
+			"Cassandra connector retried request attempts per host",
+			[]string{"cluster", "host"},
+			nil,
+		),
+
+		hostConnectionEvents: prometheus.NewDesc(
+			prometheus.BuildFQName(helpers.Name, "cassandra_connector_host_connection_events", "total"),
+			"Cassandra connector connection events per host: connected, connect_failed, up and down",
+			[]string{"cluster", "host", "event"},
+			nil,
+		),
+
+		invalidDocumentCount: prometheus.NewDesc(
+			prometheus.BuildFQName(helpers.Name, "cassandra_connector_invalid_documents", "total"),
+			"Cassandra connector invalid documents handled without failing, by table, reason and the policy applied",
+			[]string{"table", "reason", "policy"},
+			nil,
+		),
 	}
 }
 
@@ -3,10 +3,13 @@ package metric
 import (
 	"testing"
 
+	"github.com/Trendyol/go-dcp/helpers"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/Trendyol/go-dcp-cassandra/cassandra"
+	"github.com/Trendyol/go-dcp-cassandra/connector"
 )
 
 func TestNewMetricCollector(t *testing.T) {
@@ -23,7 +26,7 @@ func TestCollector_Describe(t *testing.T) {
 	bulk := &cassandra.Bulk{}
 	collector := NewMetricCollector(bulk)
 
-	ch := make(chan *prometheus.Desc, 10)
+	ch := make(chan *prometheus.Desc, 20)
 	collector.Describe(ch)
 	close(ch)
 
@@ -32,7 +35,25 @@ func TestCollector_Describe(t *testing.T) {
 		descriptions = append(descriptions, desc)
 	}
 
-	assert.Len(t, descriptions, 4)
+	assert.Len(t, descriptions, 13, "series that have not been collected yet are described too")
+	assert.Contains(t, descriptions, collector.clusterWriteErrors)
+	assert.Contains(t, descriptions, collector.hostConnectionEvents)
+}
+
+func TestCollector_HostLatencyName(t *testing.T) {
+	collector := NewMetricCollector(&cassandra.Bulk{})
+
+	assert.Contains(t, collector.hostLatency.String(),
+		`fqName: "`+helpers.Name+`_cassandra_connector_host_request_latency_ms_current"`)
+}
+
+func TestCollector_RegisterBeforeWrites(t *testing.T) {
+	collector := NewMetricCollector(&cassandra.Bulk{})
+	registry := prometheus.NewPedanticRegistry()
+
+	assert.NoError(t, registry.Register(collector))
+	_, err := registry.Gather()
+	assert.NoError(t, err)
 }
 
 func TestCollector_Collect(t *testing.T) {
@@ -48,7 +69,36 @@ func TestCollector_Collect(t *testing.T) {
 		metrics = append(metrics, metric)
 	}
 
-	assert.Len(t, metrics, 4)
+	assert.Len(t, metrics, 5)
+}
+
+type fakeInvalidDocumentReporter []connector.InvalidDocumentMetric
+
+func (f fakeInvalidDocumentReporter) InvalidDocumentMetrics() []connector.InvalidDocumentMetric {
+	return f
+}
+
+func TestCollector_InvalidDocuments(t *testing.T) {
+	collector := NewMetricCollector(&cassandra.Bulk{})
+	collector.SetInvalidDocumentReporter(fakeInvalidDocumentReporter{
+		{Table: "orders", Reason: "binary", Policy: "skip", Count: 3},
+		{Table: "orders", Reason: "unmappable", Policy: "deadLetter", Count: 1},
+	})
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(collector))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	var counts []float64
+	for _, family := range families {
+		if family.GetName() != helpers.Name+"_cassandra_connector_invalid_documents_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			counts = append(counts, m.GetCounter().GetValue())
+		}
+	}
+	assert.ElementsMatch(t, []float64{3, 1}, counts)
 }
 
 func TestCollector_Unregister(t *testing.T) {
@@ -62,7 +112,7 @@ func TestCollector_Integration(t *testing.T) {
 	bulk := &cassandra.Bulk{}
 	collector := NewMetricCollector(bulk)
 
-	descCh := make(chan *prometheus.Desc, 10)
+	descCh := make(chan *prometheus.Desc, 20)
 	collector.Describe(descCh)
 	close(descCh)
 
@@ -71,7 +121,7 @@ func TestCollector_Integration(t *testing.T) {
 		descriptions = append(descriptions, desc)
 	}
 
-	assert.Len(t, descriptions, 4, "Should have 2 metric descriptions")
+	assert.Len(t, descriptions, 13, "Should have 13 metric descriptions")
 
 	metricCh := make(chan prometheus.Metric, 10)
 	collector.Collect(metricCh)
@@ -82,7 +132,7 @@ func TestCollector_Integration(t *testing.T) {
 		metrics = append(metrics, metric)
 	}
 
-	assert.Len(t, metrics, 4, "Should have 4 metric")
+	assert.Len(t, metrics, 5, "Should have 5 metrics")
 }
 
 func TestNewMetricCollector_WithNilBulk(t *testing.T) {